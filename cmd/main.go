@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
 	"time"
 
 	"strandnerd-crawler/internal/client"
 	"strandnerd-crawler/internal/config"
 	"strandnerd-crawler/internal/crawler"
+	"strandnerd-crawler/internal/llm"
 	"strandnerd-crawler/internal/models"
+	"strandnerd-crawler/internal/scheduler"
+	"strandnerd-crawler/internal/state"
 )
 
 func main() {
@@ -17,7 +25,10 @@ func main() {
 		runOnce   = flag.Bool("once", false, "Run crawl once and exit")
 		feedID    = flag.String("feed", "", "Crawl specific feed ID only")
 		tenantID  = flag.String("tenant", "", "Run only for specific tenant ID")
-		interval  = flag.Int("interval", 300, "Crawl interval in seconds (default: 5 minutes)")
+		interval  = flag.Int("interval", 300, "Minimum crawl interval per feed in seconds (default: 5 minutes)")
+		validate  = flag.Bool("validate-rules", false, "Check site extraction rules against their test pages and exit")
+		usage     = flag.Bool("usage", false, "Report LLM usage and cost per tenant, feed and day and exit")
+		month     = flag.String("month", "", "Month of the usage report as YYYY-MM (default: current month)")
 		help      = flag.Bool("help", false, "Show help message")
 	)
 	flag.Parse()
@@ -40,8 +51,27 @@ func main() {
 		log.Printf("  - Tenant: %s (%s)", tenant.ID, tenant.Name)
 	}
 
+	// The usage report only reads the usage files, so no services are built for it
+	if *usage {
+		reportMonth := time.Now()
+		if *month != "" {
+			if reportMonth, err = time.Parse("2006-01", *month); err != nil {
+				log.Fatalf("Invalid month %q, expected YYYY-MM", *month)
+			}
+		}
+		runUsageReport(cfg, *tenantID, reportMonth)
+		return
+	}
+
+	// Content analyses are cached once for all tenants, so syndicated stories are only analysed once
+	analysisCache, err := openAnalysisCache(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open analysis cache: %v", err)
+	}
+
 	// Initialize crawler services for each tenant
 	crawlerServices := make(map[string]*crawler.Service)
+	crawlIntervals := make(map[string]time.Duration)
 	for _, tenant := range cfg.Tenants {
 		if !tenant.Enabled {
 			log.Printf("Skipping disabled tenant: %s", tenant.ID)
@@ -51,9 +81,22 @@ func main() {
 		// Initialize CMS API client for this tenant
 		cmsClient := client.NewCMSClient(tenant.CMSBaseURL, tenant.AccessToken)
 
+		// Initialize local crawl state for this tenant
+		stateStore, err := openStateStore(cfg.StateDir, tenant.ID)
+		if err != nil {
+			log.Fatalf("Failed to open state store for tenant %s: %v", tenant.ID, err)
+		}
+
+		// Initialize LLM usage accounting for this tenant
+		usageLedger, err := openUsageLedger(cfg.StateDir, tenant.ID)
+		if err != nil {
+			log.Fatalf("Failed to open usage ledger for tenant %s: %v", tenant.ID, err)
+		}
+
 		// Initialize crawler service for this tenant
-		crawlerService := crawler.NewService(cmsClient, cfg)
+		crawlerService := crawler.NewService(cmsClient, cfg.ForTenant(tenant), stateStore, analysisCache, usageLedger)
 		crawlerServices[tenant.ID] = crawlerService
+		crawlIntervals[tenant.ID] = tenantCrawlInterval(tenant, *interval)
 
 		log.Printf("Initialized crawler service for tenant: %s", tenant.ID)
 	}
@@ -62,27 +105,94 @@ func main() {
 		log.Fatalf("No enabled tenants found")
 	}
 
+	// ctx is cancelled on SIGINT/SIGTERM and stops new work from being started.
+	// workCtx keeps in-flight crawls running until the shutdown deadline expires.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+
+	shutdownTimeout := time.Duration(cfg.ShutdownTimeout) * time.Second
+	context.AfterFunc(ctx, func() {
+		stop() // A second signal terminates immediately
+		log.Printf("Shutdown signal received, draining in-flight crawls (deadline: %v)", shutdownTimeout)
+		time.AfterFunc(shutdownTimeout, func() {
+			log.Printf("Shutdown deadline reached, cancelling remaining crawls")
+			cancelWork()
+		})
+	})
+
+	if *validate {
+		if !runValidateRules(workCtx, crawlerServices, *tenantID) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *runOnce {
 		// Run once and exit
-		runCrawlOnce(crawlerServices, *feedID, *tenantID)
+		runCrawlOnce(workCtx, crawlerServices, *feedID, *tenantID)
 		return
 	}
 
 	// Start a goroutine to process manual crawl requests more frequently (every 10 seconds)
+	var queueWG sync.WaitGroup
+	queueWG.Add(1)
 	go func() {
+		defer queueWG.Done()
 		ticker := time.NewTicker(10 * time.Second)
 		defer ticker.Stop()
-		for range ticker.C {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
 			for tenantID, crawlerService := range crawlerServices {
-				if err := crawlerService.ProcessQueueRequests(); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				if err := crawlerService.ProcessQueueRequests(workCtx); err != nil {
 					log.Printf("Failed to process queue requests for tenant %s: %v", tenantID, err)
 				}
 			}
 		}
 	}()
 
-	// Run continuously
-	runCrawlScheduler(crawlerServices, *feedID, *tenantID, *interval)
+	// Run continuously until a shutdown signal arrives
+	runCrawlScheduler(ctx, workCtx, crawlerServices, crawlIntervals, *feedID, *tenantID)
+
+	queueWG.Wait()
+	log.Println("Crawler stopped")
+}
+
+// openStateStore opens the tenant's state file, or an in-memory store when no state directory is configured
+func openStateStore(stateDir, tenantID string) (state.Store, error) {
+	if stateDir == "" {
+		log.Printf("No state directory configured for tenant %s, crawl state will not survive restarts", tenantID)
+		return state.NewMemoryStore(), nil
+	}
+	return state.NewFileStore(filepath.Join(stateDir, tenantID+".json"))
+}
+
+// openUsageLedger opens the tenant's LLM usage file, or an in-memory ledger when no state directory is configured
+func openUsageLedger(stateDir, tenantID string) (*llm.UsageLedger, error) {
+	if stateDir == "" {
+		return llm.NewUsageLedger("")
+	}
+	return llm.NewUsageLedger(filepath.Join(stateDir, tenantID+".usage.json"))
+}
+
+// openAnalysisCache opens the content analysis cache shared by all tenants, nil when it is disabled
+func openAnalysisCache(cfg *config.Config) (*llm.AnalysisCache, error) {
+	if !cfg.EnableContentAnalysis || cfg.AnalysisCacheTTL <= 0 {
+		return nil, nil
+	}
+	if cfg.AnalysisCacheDir == "" {
+		log.Printf("No state directory configured, cached content analyses will not survive restarts")
+	}
+	return llm.NewAnalysisCache(cfg.AnalysisCacheDir, time.Duration(cfg.AnalysisCacheTTL)*time.Hour)
 }
 
 func printHelp() {
@@ -95,7 +205,10 @@ func printHelp() {
 	log.Println("  -once             Run crawl once and exit")
 	log.Println("  -feed <id>        Crawl specific feed ID only")
 	log.Println("  -tenant <id>      Run only for specific tenant ID")
-	log.Println("  -interval <sec>   Crawl interval in seconds (default: 300)")
+	log.Println("  -interval <sec>   Minimum crawl interval per feed in seconds (default: 300, overridden by a tenant's crawl_interval)")
+	log.Println("  -validate-rules   Check site extraction rules against their test pages and exit")
+	log.Println("  -usage            Report LLM usage and cost per tenant, feed and day and exit")
+	log.Println("  -month <YYYY-MM>  Month of the usage report (default: current month)")
 	log.Println("  -help             Show this help message")
 	log.Println()
 	log.Println("Configuration:")
@@ -115,10 +228,20 @@ func printHelp() {
 	log.Println()
 	log.Println("  Optional Environment Variables:")
 	log.Println("  OPENAI_API_KEY            OpenAI API key for content analysis (optional)")
-	log.Println("  ENABLE_CONTENT_ANALYSIS   Enable GPT content analysis (default: true)")
+	log.Println("  ANTHROPIC_API_KEY         Anthropic API key for content analysis (optional)")
+	log.Println("  ENABLE_CONTENT_ANALYSIS   Enable LLM content analysis (default: true)")
+	log.Println("  LLM_PROVIDER              Content analysis provider: openai, anthropic or fake (default: openai)")
+	log.Println("  LLM_BASE_URL              OpenAI-compatible API base URL, e.g. a local server (optional)")
+	log.Println("  LLM_MODEL                 Content analysis model (default: provider's default)")
 	log.Println("  LOG_LEVEL                 Log level (debug, info, warn, error) (default: info)")
 	log.Println("  PROXY_HOST                Proxy host (required)")
 	log.Println("  PROXY_AUTH                Proxy authentication (required)")
+	log.Println("  STATE_DIR                 Directory for local crawl state (default: state)")
+	log.Println("  SHUTDOWN_TIMEOUT          Seconds to drain in-flight crawls on SIGTERM (default: 60)")
+	log.Println("  FEED_FAILURE_THRESHOLD    Consecutive failures before a feed is parked (default: 5)")
+	log.Println("  SITE_RULES_DIR            Directory of YAML/JSON site extraction rules (default: built-in rules only)")
+	log.Println("  ANALYSIS_CACHE_TTL        Hours a cached content analysis is reused, 0 disables the cache (default: 168)")
+	log.Println("  LLM_MONTHLY_BUDGET        USD per tenant and month before analysis falls back to rules only (default: unlimited)")
 	log.Println()
 	log.Println("Examples:")
 	log.Println("  # Run once and exit for all tenants")
@@ -132,9 +255,15 @@ func printHelp() {
 	log.Println()
 	log.Println("  # Run continuously for specific tenant only")
 	log.Println("  crawler -tenant dev")
+	log.Println()
+	log.Println("  # Report site rules that no longer match their test pages")
+	log.Println("  crawler -validate-rules -tenant main")
+	log.Println()
+	log.Println("  # Report last month's LLM cost for all tenants")
+	log.Println("  crawler -usage -month 2024-05")
 }
 
-func runCrawlOnce(crawlerServices map[string]*crawler.Service, feedID, tenantID string) {
+func runCrawlOnce(ctx context.Context, crawlerServices map[string]*crawler.Service, feedID, tenantID string) {
 	log.Println("Running crawl once...")
 
 	var servicesToRun map[string]*crawler.Service
@@ -160,7 +289,7 @@ func runCrawlOnce(crawlerServices map[string]*crawler.Service, feedID, tenantID
 		log.Printf("\n--- Processing Tenant: %s ---", currentTenantID)
 
 		// First, check for any queue requests (higher priority)
-		if err := crawlerService.ProcessQueueRequests(); err != nil {
+		if err := crawlerService.ProcessQueueRequests(ctx); err != nil {
 			log.Printf("Failed to process queue requests for tenant %s: %v", currentTenantID, err)
 		}
 
@@ -169,7 +298,7 @@ func runCrawlOnce(crawlerServices map[string]*crawler.Service, feedID, tenantID
 
 		if feedID != "" {
 			// Crawl specific feed
-			result, crawlErr := crawlerService.CrawlFeed(feedID)
+			result, crawlErr := crawlerService.CrawlFeed(ctx, feedID)
 			if crawlErr != nil {
 				log.Printf("Failed to crawl feed %s for tenant %s: %v", feedID, currentTenantID, crawlErr)
 				allSuccessful = false
@@ -178,7 +307,7 @@ func runCrawlOnce(crawlerServices map[string]*crawler.Service, feedID, tenantID
 			results = []models.CrawlResult{*result}
 		} else {
 			// Crawl all due feeds
-			results, err = crawlerService.CrawlAllDueFeeds()
+			results, err = crawlerService.CrawlAllDueFeeds(ctx)
 			if err != nil {
 				log.Printf("Failed to crawl feeds for tenant %s: %v", currentTenantID, err)
 				allSuccessful = false
@@ -232,92 +361,135 @@ func runCrawlOnce(crawlerServices map[string]*crawler.Service, feedID, tenantID
 	log.Println("Crawl completed successfully")
 }
 
-func runCrawlScheduler(crawlerServices map[string]*crawler.Service, feedID, tenantID string, intervalSec int) {
-	log.Printf("Starting crawler scheduler (interval: %d seconds)", intervalSec)
+// runValidateRules validates the site extraction rules in effect for each tenant and reports
+// whether all of them passed
+func runValidateRules(ctx context.Context, crawlerServices map[string]*crawler.Service, tenantID string) bool {
+	if tenantID != "" {
+		service, exists := crawlerServices[tenantID]
+		if !exists {
+			log.Fatalf("Tenant %s not found or not enabled", tenantID)
+		}
+		crawlerServices = map[string]*crawler.Service{tenantID: service}
+	}
 
-	ticker := time.NewTicker(time.Duration(intervalSec) * time.Second)
-	defer ticker.Stop()
+	allValid := true
+	for currentTenantID, crawlerService := range crawlerServices {
+		log.Printf("\n--- Validating site rules for tenant: %s ---", currentTenantID)
 
-	// Run initial crawl
-	runScheduledCrawl(crawlerServices, feedID, tenantID)
+		failed := 0
+		reports := crawlerService.ValidateSiteRules(ctx)
+		for _, report := range reports {
+			if report.OK() {
+				if report.PagesChecked > 0 {
+					log.Printf("✅ %s (%s): matches %d test page(s)", report.Name, report.Source, report.PagesChecked)
+				}
+				continue
+			}
+			failed++
+			log.Printf("❌ %s (%s):", report.Name, report.Source)
+			for _, problem := range report.Problems {
+				log.Printf("   - %s", problem)
+			}
+		}
 
-	// Run on schedule
-	for range ticker.C {
-		runScheduledCrawl(crawlerServices, feedID, tenantID)
+		log.Printf("Tenant %s: %d site rules checked, %d with problems", currentTenantID, len(reports), failed)
+		if failed > 0 {
+			allValid = false
+		}
 	}
+
+	return allValid
 }
 
-func runScheduledCrawl(crawlerServices map[string]*crawler.Service, feedID, tenantID string) {
-	log.Println("Running scheduled crawl...")
+// runUsageReport logs each enabled tenant's LLM usage and cost in the month by feed and by day,
+// read straight from the tenants' usage files
+func runUsageReport(cfg *config.Config, tenantID string, month time.Time) {
+	if cfg.StateDir == "" {
+		log.Fatalf("No state directory configured, LLM usage is not recorded")
+	}
 
-	var servicesToRun map[string]*crawler.Service
+	found := false
+	for _, tenant := range cfg.Tenants {
+		if !tenant.Enabled || (tenantID != "" && tenant.ID != tenantID) {
+			continue
+		}
+		found = true
 
-	if tenantID != "" {
-		// Run for specific tenant only
-		if service, exists := crawlerServices[tenantID]; exists {
-			servicesToRun = map[string]*crawler.Service{tenantID: service}
-		} else {
-			log.Printf("Tenant %s not found or not enabled", tenantID)
-			return
+		usageLedger, err := openUsageLedger(cfg.StateDir, tenant.ID)
+		if err != nil {
+			log.Fatalf("Failed to open usage ledger for tenant %s: %v", tenant.ID, err)
 		}
-	} else {
-		// Run for all tenants
-		servicesToRun = crawlerServices
-	}
+		report := usageLedger.Report(month)
 
-	for currentTenantID, crawlerService := range servicesToRun {
-		log.Printf("\n--- Processing Tenant: %s ---", currentTenantID)
+		log.Printf("\n--- LLM usage for tenant %s in %s ---", tenant.ID, report.Month)
+		log.Printf("Total: %d calls, %d prompt + %d completion tokens, $%.4f",
+			report.Total.Calls, report.Total.PromptTokens, report.Total.CompletionTokens, report.Total.CostUSD)
 
-		// First, check for any queue requests (higher priority)
-		if err := crawlerService.ProcessQueueRequests(); err != nil {
-			log.Printf("Failed to process queue requests for tenant %s: %v", currentTenantID, err)
+		log.Printf("By feed:")
+		for _, feed := range report.Feeds() {
+			totals := report.ByFeed[feed]
+			log.Printf("  %-36s %6d calls %10d tokens  $%.4f", feed, totals.Calls, totals.PromptTokens+totals.CompletionTokens, totals.CostUSD)
 		}
 
-		if feedID != "" {
-			// Crawl specific feed
-			result, err := crawlerService.CrawlFeed(feedID)
-			if err != nil {
-				log.Printf("Failed to crawl feed %s for tenant %s: %v", feedID, currentTenantID, err)
-				continue
-			}
-			printCrawlResult(result, currentTenantID)
-		} else {
-			// Crawl all due feeds
-			results, err := crawlerService.CrawlAllDueFeeds()
-			if err != nil {
-				log.Printf("Failed to crawl feeds for tenant %s: %v", currentTenantID, err)
-				continue
-			}
+		log.Printf("By day:")
+		for _, day := range report.Days() {
+			totals := report.ByDay[day]
+			log.Printf("  %-36s %6d calls %10d tokens  $%.4f", day, totals.Calls, totals.PromptTokens+totals.CompletionTokens, totals.CostUSD)
+		}
+	}
 
-			if len(results) == 0 {
-				log.Printf("No feeds due for crawling for tenant %s", currentTenantID)
-				continue
-			}
+	if !found {
+		if tenantID != "" {
+			log.Fatalf("Tenant %s not found or not enabled", tenantID)
+		}
+		log.Fatalf("No enabled tenants found")
+	}
+}
 
-			successCount := 0
-			errorCount := 0
-			totalPosts := 0
-
-			for _, result := range results {
-				printCrawlResult(&result, currentTenantID)
-				if result.Success {
-					successCount++
-					totalPosts += result.PostsAdded
-				} else {
-					errorCount++
-				}
-			}
+func runCrawlScheduler(ctx, workCtx context.Context, crawlerServices map[string]*crawler.Service, crawlIntervals map[string]time.Duration, feedID, tenantID string) {
+	var tenants []scheduler.Tenant
 
-			log.Printf("Tenant %s crawl summary: %d feeds processed, %d successful, %d errors, %d total posts added",
-				currentTenantID, len(results), successCount, errorCount, totalPosts)
+	for currentTenantID, crawlerService := range crawlerServices {
+		// Run for specific tenant only when requested
+		if tenantID != "" && currentTenantID != tenantID {
+			continue
 		}
+		tenants = append(tenants, scheduler.Tenant{
+			ID:          currentTenantID,
+			Crawler:     crawlerService,
+			MinInterval: crawlIntervals[currentTenantID],
+		})
+	}
+
+	if len(tenants) == 0 {
+		log.Fatalf("Tenant %s not found or not enabled", tenantID)
+	}
+
+	// One scheduler covers every feed of every tenant, each feed runs on its own jittered schedule
+	feedScheduler := scheduler.New(tenants, feedID)
+	feedScheduler.OnResult = func(tenantID string, result *models.CrawlResult) {
+		printCrawlResult(result, tenantID)
+	}
+	feedScheduler.Run(ctx, workCtx)
+}
+
+// tenantCrawlInterval returns the tenant's crawl_interval (minutes) or the global interval (seconds).
+// It is the minimum time between two crawls of any feed of the tenant.
+func tenantCrawlInterval(tenant config.TenantConfig, defaultIntervalSec int) time.Duration {
+	if tenant.CrawlInterval != nil && *tenant.CrawlInterval > 0 {
+		return time.Duration(*tenant.CrawlInterval) * time.Minute
 	}
+	return time.Duration(defaultIntervalSec) * time.Second
 }
 
 func printCrawlResult(result *models.CrawlResult, tenantID string) {
-	if result.Success {
-		log.Printf("✓ [%s] Feed %s: %d found, %d added, %d skipped",
-			tenantID, result.FeedID, result.PostsFound, result.PostsAdded, result.PostsSkipped)
+	if result.BackedOff {
+		log.Printf("⏸ [%s] Feed %s: backing off until %s", tenantID, result.FeedID, result.RetryAfter.Format(time.RFC3339))
+	} else if result.NotModified {
+		log.Printf("✓ [%s] Feed %s: not modified", tenantID, result.FeedID)
+	} else if result.Success {
+		log.Printf("✓ [%s] Feed %s: %d found, %d added, %d skipped, %d deferred",
+			tenantID, result.FeedID, result.PostsFound, result.PostsAdded, result.PostsSkipped, result.PostsDeferred)
 	} else {
 		log.Printf("✗ [%s] Feed %s failed: %v", tenantID, result.FeedID, result.Error)
 	}
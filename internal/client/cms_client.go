@@ -2,9 +2,12 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"time"
 
@@ -20,11 +23,35 @@ type CrawlRequest struct {
 	Priority  int       `json:"priority"`
 }
 
+// RetryPolicy controls how failed CMS requests are retried
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first one
+	BaseDelay   time.Duration // delay before the first retry, doubled for each further one
+	MaxDelay    time.Duration // longest delay between attempts, longer Retry-After values are not waited for
+}
+
+// DefaultRetryPolicy is used by NewCMSClient
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// delay returns the backoff before the given retry (1 for the first retry)
+func (p RetryPolicy) delay(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
 // CMSClient handles communication with the CMS API
 type CMSClient struct {
 	baseURL     string
 	accessToken string
 	httpClient  *http.Client
+	retry       RetryPolicy
 }
 
 // NewCMSClient creates a new CMS API client
@@ -35,32 +62,20 @@ func NewCMSClient(baseURL, accessToken string) *CMSClient {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		retry: DefaultRetryPolicy,
 	}
 }
 
 // GetInspirationFeeds fetches all inspiration feeds from the CMS
-func (c *CMSClient) GetInspirationFeeds() ([]models.InspirationFeed, error) {
+func (c *CMSClient) GetInspirationFeeds(ctx context.Context) ([]models.InspirationFeed, error) {
 	url := fmt.Sprintf("%s/api/v1/crawler/inspiration_feeds", c.baseURL)
 
-	req, err := http.NewRequest("GET", url, nil)
+	resp, err := c.do(ctx, "GET", url, nil, http.StatusOK)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
 	var feeds []models.InspirationFeed
 	if err := json.NewDecoder(resp.Body).Decode(&feeds); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
@@ -70,32 +85,15 @@ func (c *CMSClient) GetInspirationFeeds() ([]models.InspirationFeed, error) {
 }
 
 // GetInspirationFeedByID fetches a specific inspiration feed by ID
-func (c *CMSClient) GetInspirationFeedByID(feedID string) (*models.InspirationFeed, error) {
+func (c *CMSClient) GetInspirationFeedByID(ctx context.Context, feedID string) (*models.InspirationFeed, error) {
 	url := fmt.Sprintf("%s/api/v1/crawler/inspiration_feeds/%s", c.baseURL, feedID)
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(ctx, "GET", url, nil, http.StatusOK)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("feed not found")
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
 	var feed models.InspirationFeed
 	if err := json.NewDecoder(resp.Body).Decode(&feed); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
@@ -105,33 +103,15 @@ func (c *CMSClient) GetInspirationFeedByID(feedID string) (*models.InspirationFe
 }
 
 // CreateInspirationFeedPost creates a new inspiration feed post in the CMS
-func (c *CMSClient) CreateInspirationFeedPost(post *models.CreateInspirationFeedPostRequest) (*models.InspirationFeedPost, error) {
+func (c *CMSClient) CreateInspirationFeedPost(ctx context.Context, post *models.CreateInspirationFeedPostRequest) (*models.InspirationFeedPost, error) {
 	url := fmt.Sprintf("%s/api/v1/crawler/inspiration_feed_posts", c.baseURL)
 
-	jsonData, err := json.Marshal(post)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(ctx, "POST", url, post, http.StatusCreated)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
 	var createdPost models.InspirationFeedPost
 	if err := json.NewDecoder(resp.Body).Decode(&createdPost); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
@@ -141,28 +121,15 @@ func (c *CMSClient) CreateInspirationFeedPost(post *models.CreateInspirationFeed
 }
 
 // GetInspirationPosts fetches existing inspiration posts to check for duplicates
-func (c *CMSClient) GetInspirationPosts(feedID string, limit int) ([]models.InspirationFeedPost, error) {
+func (c *CMSClient) GetInspirationPosts(ctx context.Context, feedID string, limit int) ([]models.InspirationFeedPost, error) {
 	url := fmt.Sprintf("%s/api/v1/crawler/inspiration_feed_posts?feed_id=%s&limit=%d", c.baseURL, feedID, limit)
 
-	req, err := http.NewRequest("GET", url, nil)
+	resp, err := c.do(ctx, "GET", url, nil, http.StatusOK)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
 	var posts []models.InspirationFeedPost
 	if err := json.NewDecoder(resp.Body).Decode(&posts); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
@@ -172,46 +139,69 @@ func (c *CMSClient) GetInspirationPosts(feedID string, limit int) ([]models.Insp
 }
 
 // UpdateFeedLastCrawledAt updates the last crawled timestamp for a feed
-func (c *CMSClient) UpdateFeedLastCrawledAt(feedID string) error {
+func (c *CMSClient) UpdateFeedLastCrawledAt(ctx context.Context, feedID string) error {
 	url := fmt.Sprintf("%s/api/v1/crawler/inspiration_feeds/%s/last-crawled", c.baseURL, feedID)
 
-	req, err := http.NewRequest("PUT", url, nil)
+	resp, err := c.do(ctx, "PUT", url, nil, http.StatusOK)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return err
 	}
+	resp.Body.Close()
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
-	req.Header.Set("Content-Type", "application/json")
+	return nil
+}
 
-	resp, err := c.httpClient.Do(req)
+// UpdateFeedCrawlStatus reports a feed's crawl health so editors can see broken feeds
+func (c *CMSClient) UpdateFeedCrawlStatus(ctx context.Context, feedID string, status *models.FeedCrawlStatus) error {
+	url := fmt.Sprintf("%s/api/v1/crawler/inspiration_feeds/%s/crawl-status", c.baseURL, feedID)
+
+	resp, err := c.do(ctx, "PUT", url, status, http.StatusOK)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
+	resp.Body.Close()
+
+	return nil
+}
+
+// UpdateFeedURL reports the feed discovered on the page configured as a feed's URL so the record can be corrected
+func (c *CMSClient) UpdateFeedURL(ctx context.Context, feedID string, update *models.UpdateFeedURLRequest) error {
+	url := fmt.Sprintf("%s/api/v1/crawler/inspiration_feeds/%s/url", c.baseURL, feedID)
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	resp, err := c.do(ctx, "PUT", url, update, http.StatusOK)
+	if err != nil {
+		return err
 	}
+	resp.Body.Close()
 
 	return nil
 }
 
-// PollCrawlRequest polls for crawl requests from the CMS queue
-func (c *CMSClient) PollCrawlRequest() (*CrawlRequest, error) {
-	url := fmt.Sprintf("%s/api/v1/crawler/requests/poll", c.baseURL)
+// GetSiteRules fetches the tenant's site extraction rules
+func (c *CMSClient) GetSiteRules(ctx context.Context) ([]models.SiteRule, error) {
+	url := fmt.Sprintf("%s/api/v1/crawler/extraction_rules", c.baseURL)
 
-	req, err := http.NewRequest("GET", url, nil)
+	resp, err := c.do(ctx, "GET", url, nil, http.StatusOK)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
-	req.Header.Set("Content-Type", "application/json")
+	var rules []models.SiteRule
+	if err := json.NewDecoder(resp.Body).Decode(&rules); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
 
-	resp, err := c.httpClient.Do(req)
+	return rules, nil
+}
+
+// PollCrawlRequest polls for crawl requests from the CMS queue
+func (c *CMSClient) PollCrawlRequest(ctx context.Context) (*CrawlRequest, error) {
+	url := fmt.Sprintf("%s/api/v1/crawler/requests/poll", c.baseURL)
+
+	resp, err := c.do(ctx, "GET", url, nil, http.StatusOK, http.StatusNoContent)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -220,11 +210,6 @@ func (c *CMSClient) PollCrawlRequest() (*CrawlRequest, error) {
 		return nil, nil
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
 	var request CrawlRequest
 	if err := json.NewDecoder(resp.Body).Decode(&request); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
@@ -234,12 +219,76 @@ func (c *CMSClient) PollCrawlRequest() (*CrawlRequest, error) {
 }
 
 // AcknowledgeRequest acknowledges completion of a crawl request
-func (c *CMSClient) AcknowledgeRequest(requestID string) error {
+func (c *CMSClient) AcknowledgeRequest(ctx context.Context, requestID string) error {
 	url := fmt.Sprintf("%s/api/v1/crawler/requests/%s", c.baseURL, requestID)
 
-	req, err := http.NewRequest("DELETE", url, nil)
+	resp, err := c.do(ctx, "DELETE", url, nil, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// do sends an authenticated request, retrying transport failures, rate limits and server errors
+// with exponential backoff or the CMS's Retry-After delay. POST requests are only retried when the
+// CMS refused them (429/503) so posts are never created twice. Any status other than the expected
+// ones is returned as an *APIError; the caller must close the returned response's body.
+func (c *CMSClient) do(ctx context.Context, method, url string, payload any, expected ...int) (*http.Response, error) {
+	var body []byte
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = jsonData
+	}
+
+	idempotent := method != "POST"
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, method, url, body, expected)
+		if err == nil {
+			return resp, nil
+		}
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || !apiErr.retryable(idempotent) || attempt >= c.retry.MaxAttempts || ctx.Err() != nil {
+			return nil, err
+		}
+
+		delay := c.retry.delay(attempt)
+		if apiErr.RetryAfter > 0 {
+			if apiErr.RetryAfter > c.retry.MaxDelay {
+				return nil, err
+			}
+			delay = apiErr.RetryAfter
+		}
+
+		log.Printf("CMS request %s %s failed (%v), retrying in %s (attempt %d/%d)",
+			method, url, err, delay, attempt+1, c.retry.MaxAttempts)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+	}
+}
+
+// send makes a single attempt of a request
+func (c *CMSClient) send(ctx context.Context, method, url string, body []byte, expected []int) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken))
@@ -247,14 +296,16 @@ func (c *CMSClient) AcknowledgeRequest(requestID string) error {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return nil, &APIError{Kind: ErrorKindTransport, Err: err}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	for _, status := range expected {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
 	}
 
-	return nil
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	return nil, newStatusError(resp, respBody)
 }
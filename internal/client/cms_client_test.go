@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"strandnerd-crawler/internal/models"
+)
+
+func newTestClient(serverURL string) *CMSClient {
+	c := NewCMSClient(serverURL, "token")
+	c.retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	return c
+}
+
+func TestRetriesServerErrors(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[{"id": "feed-1"}]`))
+	}))
+	defer server.Close()
+
+	feeds, err := newTestClient(server.URL).GetInspirationFeeds(context.Background())
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if attempts != 3 || len(feeds) != 1 {
+		t.Errorf("expected 3 attempts and 1 feed, got %d attempts and %d feeds", attempts, len(feeds))
+	}
+}
+
+func TestClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		status   int
+		kind     ErrorKind
+		attempts int
+	}{
+		{http.StatusUnauthorized, ErrorKindAuth, 1},
+		{http.StatusNotFound, ErrorKindNotFound, 1},
+		{http.StatusTooManyRequests, ErrorKindRateLimited, 3},
+		{http.StatusInternalServerError, ErrorKindServer, 3},
+		{http.StatusBadRequest, ErrorKindClient, 1},
+	}
+
+	for _, tt := range tests {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(tt.status)
+		}))
+
+		err := newTestClient(server.URL).UpdateFeedLastCrawledAt(context.Background(), "feed-1")
+		server.Close()
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("status %d: expected *APIError, got %v", tt.status, err)
+		}
+		if apiErr.Kind != tt.kind || apiErr.StatusCode != tt.status {
+			t.Errorf("status %d: expected kind %s, got %s", tt.status, tt.kind, apiErr.Kind)
+		}
+		if attempts != tt.attempts {
+			t.Errorf("status %d: expected %d attempts, got %d", tt.status, tt.attempts, attempts)
+		}
+	}
+}
+
+func TestDoesNotRetryPostOnServerError(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	post := &models.CreateInspirationFeedPostRequest{InspirationFeedID: "feed-1", Title: "Title", URL: "https://example.com"}
+	if _, err := newTestClient(server.URL).CreateInspirationFeedPost(context.Background(), post); err == nil {
+		t.Fatal("expected an error")
+	}
+	if attempts != 1 {
+		t.Errorf("expected a single attempt for a non-idempotent request, got %d", attempts)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if got := parseRetryAfter("120", now); got != 2*time.Minute {
+		t.Errorf("expected 2m for seconds, got %v", got)
+	}
+	if got := parseRetryAfter("Mon, 01 Jan 2024 12:00:30 GMT", now); got != 30*time.Second {
+		t.Errorf("expected 30s for an HTTP date, got %v", got)
+	}
+	if got := parseRetryAfter("soon", now); got != 0 {
+		t.Errorf("expected 0 for an invalid value, got %v", got)
+	}
+}
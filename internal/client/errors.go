@@ -0,0 +1,129 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorKind classifies why a CMS request failed
+type ErrorKind int
+
+const (
+	ErrorKindTransport   ErrorKind = iota // request never got an HTTP response
+	ErrorKindAuth                         // 401 or 403, the access token is missing, invalid or lacks permissions
+	ErrorKindNotFound                     // 404
+	ErrorKindRateLimited                  // 429
+	ErrorKindServer                       // 5xx
+	ErrorKindClient                       // any other unexpected status
+)
+
+// String returns a short name for the error kind
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindTransport:
+		return "transport"
+	case ErrorKindAuth:
+		return "auth"
+	case ErrorKindNotFound:
+		return "not found"
+	case ErrorKindRateLimited:
+		return "rate limited"
+	case ErrorKindServer:
+		return "server"
+	default:
+		return "client"
+	}
+}
+
+// APIError is returned by every CMSClient method when the CMS could not be reached or answered
+// with an unexpected status. Use errors.As to inspect it.
+type APIError struct {
+	Kind       ErrorKind
+	StatusCode int           // 0 for transport errors
+	Body       string        // response body, truncated
+	RetryAfter time.Duration // delay requested by the CMS via Retry-After, 0 if none
+	Err        error         // underlying transport error
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	if e.Kind == ErrorKindTransport {
+		return fmt.Sprintf("failed to send request: %v", e.Err)
+	}
+	return fmt.Sprintf("API request failed with status %d (%s): %s", e.StatusCode, e.Kind, e.Body)
+}
+
+// Unwrap returns the underlying transport error
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether the request may succeed when tried again later
+func (e *APIError) Temporary() bool {
+	return e.Kind == ErrorKindTransport || e.Kind == ErrorKindRateLimited || e.Kind == ErrorKindServer
+}
+
+// retryable reports whether the client should retry the request itself. Requests that are not
+// idempotent are only retried when the CMS explicitly refused to process them.
+func (e *APIError) retryable(idempotent bool) bool {
+	switch e.Kind {
+	case ErrorKindTransport:
+		return idempotent
+	case ErrorKindRateLimited:
+		return true
+	case ErrorKindServer:
+		return idempotent || e.StatusCode == http.StatusServiceUnavailable
+	default:
+		return false
+	}
+}
+
+// maxErrorBody bounds how much of a response body is kept in an APIError
+const maxErrorBody = 512
+
+// newStatusError classifies an unexpected response status
+func newStatusError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		Kind:       ErrorKindClient,
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+	if len(apiErr.Body) > maxErrorBody {
+		apiErr.Body = apiErr.Body[:maxErrorBody] + "..."
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		apiErr.Kind = ErrorKindAuth
+	case resp.StatusCode == http.StatusNotFound:
+		apiErr.Kind = ErrorKindNotFound
+	case resp.StatusCode == http.StatusTooManyRequests:
+		apiErr.Kind = ErrorKindRateLimited
+	case resp.StatusCode >= 500:
+		apiErr.Kind = ErrorKindServer
+	}
+
+	return apiErr
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+
+	return 0
+}
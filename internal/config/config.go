@@ -3,9 +3,12 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 
 	"gopkg.in/yaml.v3"
+
+	"strandnerd-crawler/internal/llm"
 )
 
 // TenantConfig holds configuration for a single tenant
@@ -17,6 +20,33 @@ type TenantConfig struct {
 	Enabled           bool   `yaml:"enabled"`
 	CrawlInterval     *int   `yaml:"crawl_interval,omitempty"`     // Optional: tenant-specific crawl interval
 	MaxPostsPerCrawl  *int   `yaml:"max_posts_per_crawl,omitempty"` // Optional: tenant-specific limit
+
+	// Optional: tenant-specific overrides of the global settings
+	FeedRefreshInterval *int   `yaml:"feed_refresh_interval,omitempty"`
+	RequestTimeout      *int   `yaml:"request_timeout,omitempty"`
+	MaxConcurrentCrawls *int   `yaml:"max_concurrent_crawls,omitempty"`
+	UserAgent           string `yaml:"user_agent,omitempty"`
+
+	// Optional: tenant-specific language model for content analysis
+	LLM *LLMConfig `yaml:"llm,omitempty"`
+}
+
+// LLMConfig selects the language model used for content analysis
+type LLMConfig struct {
+	Provider    string   `yaml:"provider,omitempty"` // openai (default, any OpenAI-compatible API), anthropic or fake
+	BaseURL     string   `yaml:"base_url,omitempty"` // e.g. http://localhost:11434/v1 for a local Ollama server
+	APIKey      string   `yaml:"api_key,omitempty"`
+	Model       string   `yaml:"model,omitempty"`
+	Temperature *float64 `yaml:"temperature,omitempty"`
+	MaxTokens   *int     `yaml:"max_tokens,omitempty"`
+	// StructuredOutput requests schema-constrained answers, disable it for OpenAI-compatible servers without support
+	StructuredOutput *bool `yaml:"structured_output,omitempty"`
+	// Budgets of the API account, shared by all tenants using the same API key
+	RequestsPerMinute *int `yaml:"requests_per_minute,omitempty"`
+	TokensPerMinute   *int `yaml:"tokens_per_minute,omitempty"`
+	MaxRetries        *int `yaml:"max_retries,omitempty"` // retries of rate limited or temporarily failed requests
+	// MonthlyBudget caps the tenant's analysis spending in USD, after which only rule-based analysis runs
+	MonthlyBudget *float64 `yaml:"monthly_budget,omitempty"`
 }
 
 // GlobalConfig holds global configuration settings
@@ -27,9 +57,24 @@ type GlobalConfig struct {
 	MaxConcurrentCrawls   *int   `yaml:"max_concurrent_crawls,omitempty"`
 	UserAgent             string `yaml:"user_agent,omitempty"`
 	OpenAIAPIKey          string `yaml:"openai_api_key,omitempty"`
+	AnthropicAPIKey       string `yaml:"anthropic_api_key,omitempty"`
 	EnableContentAnalysis *bool  `yaml:"enable_content_analysis,omitempty"`
 	ProxyHost             string `yaml:"proxy_host,omitempty"`
 	ProxyAuth             string `yaml:"proxy_auth,omitempty"`
+	StateDir              string `yaml:"state_dir,omitempty"`
+	ExtractConcurrency    *int   `yaml:"extract_concurrency,omitempty"`
+	AnalysisConcurrency   *int   `yaml:"analysis_concurrency,omitempty"`
+	MaxPostsPerCrawl      *int   `yaml:"max_posts_per_crawl,omitempty"`
+	ShutdownTimeout       *int   `yaml:"shutdown_timeout,omitempty"`
+	FeedBackoffBase       *int   `yaml:"feed_backoff_base,omitempty"`
+	FeedBackoffMax        *int   `yaml:"feed_backoff_max,omitempty"`
+	FeedFailureThreshold  *int   `yaml:"feed_failure_threshold,omitempty"`
+	FeedParkDuration      *int   `yaml:"feed_park_duration,omitempty"`
+	SiteRulesDir          string `yaml:"site_rules_dir,omitempty"`
+	AnalysisCacheDir      string `yaml:"analysis_cache_dir,omitempty"`
+	AnalysisCacheTTL      *int   `yaml:"analysis_cache_ttl,omitempty"`
+	LLMPrices             map[string]llm.ModelPrice `yaml:"llm_prices,omitempty"` // by model name prefix, extends the built-in prices
+	LLM                   LLMConfig `yaml:"llm,omitempty"`
 }
 
 // YAMLConfig represents the YAML configuration file structure
@@ -47,9 +92,34 @@ type Config struct {
 	MaxConcurrentCrawls   int
 	UserAgent             string
 	OpenAIAPIKey          string
+	AnthropicAPIKey       string
 	EnableContentAnalysis bool
+	LLMProvider           string  // openai, anthropic or fake
+	LLMBaseURL            string  // empty uses the provider's public API
+	LLMAPIKey             string  // empty uses the provider's API key setting
+	LLMModel              string  // empty uses the provider's default model
+	LLMTemperature        float64
+	LLMMaxTokens          int
+	LLMStructuredOutput   bool    // request schema-constrained answers where the provider supports them
+	LLMRequestsPerMinute  int     // request budget of the API account, 0 is unlimited
+	LLMTokensPerMinute    int     // token budget of the API account, 0 is unlimited
+	LLMMaxRetries         int     // retries of rate limited or temporarily failed requests
+	LLMMonthlyBudget      float64 // USD per tenant and month, 0 is unlimited
+	LLMPrices             map[string]llm.ModelPrice
 	ProxyHost             string
 	ProxyAuth             string
+	StateDir              string // directory for per-tenant crawl state files, empty keeps state in memory only
+	ExtractConcurrency    int    // article pages fetched concurrently per feed
+	AnalysisConcurrency   int    // content analyses run concurrently per feed
+	MaxPostsPerCrawl      int    // new posts created per feed crawl, 0 means unlimited
+	ShutdownTimeout       int    // seconds to drain in-flight crawls after SIGTERM
+	FeedBackoffBase       int    // minutes to wait after a feed's first failed crawl, doubled per further failure
+	FeedBackoffMax        int    // upper bound in minutes for a failing feed's backoff
+	FeedFailureThreshold  int    // consecutive failures after which a feed is parked, 0 never parks
+	FeedParkDuration      int    // minutes between probe crawls of a parked feed
+	SiteRulesDir          string // directory of YAML/JSON site extraction rules, empty uses the built-in rules only
+	AnalysisCacheDir      string // directory of cached content analyses shared by all tenants, empty uses STATE_DIR/analysis-cache
+	AnalysisCacheTTL      int    // hours a cached content analysis is reused, 0 disables the cache
 }
 
 // Load loads configuration from YAML file or environment variables
@@ -68,9 +138,38 @@ func Load() (*Config, error) {
 		MaxConcurrentCrawls:   getConfigIntValue(yamlConfig.Global.MaxConcurrentCrawls, "MAX_CONCURRENT_CRAWLS", 3),
 		UserAgent:             getConfigValue(yamlConfig.Global.UserAgent, "USER_AGENT", "StrandNerd-Crawler/1.0"),
 		OpenAIAPIKey:          getConfigValue(yamlConfig.Global.OpenAIAPIKey, "OPENAI_API_KEY", ""),
+		AnthropicAPIKey:       getConfigValue(yamlConfig.Global.AnthropicAPIKey, "ANTHROPIC_API_KEY", ""),
 		EnableContentAnalysis: getConfigBoolValue(yamlConfig.Global.EnableContentAnalysis, "ENABLE_CONTENT_ANALYSIS", true),
+		LLMProvider:           getConfigValue(yamlConfig.Global.LLM.Provider, "LLM_PROVIDER", "openai"),
+		LLMBaseURL:            getConfigValue(yamlConfig.Global.LLM.BaseURL, "LLM_BASE_URL", ""),
+		LLMAPIKey:             getConfigValue(yamlConfig.Global.LLM.APIKey, "LLM_API_KEY", ""),
+		LLMModel:              getConfigValue(yamlConfig.Global.LLM.Model, "LLM_MODEL", ""),
+		LLMTemperature:        getConfigFloatValue(yamlConfig.Global.LLM.Temperature, "LLM_TEMPERATURE", 0.3),
+		LLMMaxTokens:          getConfigIntValue(yamlConfig.Global.LLM.MaxTokens, "LLM_MAX_TOKENS", 200),
+		LLMStructuredOutput:   getConfigBoolValue(yamlConfig.Global.LLM.StructuredOutput, "LLM_STRUCTURED_OUTPUT", true),
+		LLMRequestsPerMinute:  getConfigIntValue(yamlConfig.Global.LLM.RequestsPerMinute, "LLM_REQUESTS_PER_MINUTE", 60),
+		LLMTokensPerMinute:    getConfigIntValue(yamlConfig.Global.LLM.TokensPerMinute, "LLM_TOKENS_PER_MINUTE", 0),
+		LLMMaxRetries:         getConfigIntValue(yamlConfig.Global.LLM.MaxRetries, "LLM_MAX_RETRIES", 3),
+		LLMMonthlyBudget:      getConfigFloatValue(yamlConfig.Global.LLM.MonthlyBudget, "LLM_MONTHLY_BUDGET", 0),
+		LLMPrices:             yamlConfig.Global.LLMPrices,
 		ProxyHost:             getConfigValue(yamlConfig.Global.ProxyHost, "PROXY_HOST", ""),
 		ProxyAuth:             getConfigValue(yamlConfig.Global.ProxyAuth, "PROXY_AUTH", ""),
+		StateDir:              getConfigValue(yamlConfig.Global.StateDir, "STATE_DIR", "state"),
+		ExtractConcurrency:    getConfigIntValue(yamlConfig.Global.ExtractConcurrency, "EXTRACT_CONCURRENCY", 4),
+		AnalysisConcurrency:   getConfigIntValue(yamlConfig.Global.AnalysisConcurrency, "ANALYSIS_CONCURRENCY", 2),
+		MaxPostsPerCrawl:      getConfigIntValue(yamlConfig.Global.MaxPostsPerCrawl, "MAX_POSTS_PER_CRAWL", 0),
+		ShutdownTimeout:       getConfigIntValue(yamlConfig.Global.ShutdownTimeout, "SHUTDOWN_TIMEOUT", 60),
+		FeedBackoffBase:       getConfigIntValue(yamlConfig.Global.FeedBackoffBase, "FEED_BACKOFF_BASE", 5),
+		FeedBackoffMax:        getConfigIntValue(yamlConfig.Global.FeedBackoffMax, "FEED_BACKOFF_MAX", 360),
+		FeedFailureThreshold:  getConfigIntValue(yamlConfig.Global.FeedFailureThreshold, "FEED_FAILURE_THRESHOLD", 5),
+		FeedParkDuration:      getConfigIntValue(yamlConfig.Global.FeedParkDuration, "FEED_PARK_DURATION", 1440),
+		SiteRulesDir:          getConfigValue(yamlConfig.Global.SiteRulesDir, "SITE_RULES_DIR", ""),
+		AnalysisCacheDir:      getConfigValue(yamlConfig.Global.AnalysisCacheDir, "ANALYSIS_CACHE_DIR", ""),
+		AnalysisCacheTTL:      getConfigIntValue(yamlConfig.Global.AnalysisCacheTTL, "ANALYSIS_CACHE_TTL", 168),
+	}
+
+	if cfg.AnalysisCacheDir == "" && cfg.StateDir != "" {
+		cfg.AnalysisCacheDir = filepath.Join(cfg.StateDir, "analysis-cache")
 	}
 
 	// Load tenant configurations
@@ -84,13 +183,101 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("no tenants configured - at least one tenant is required")
 	}
 
-	// OpenAI API key is optional - if not provided, content analysis will be skipped
-	if cfg.EnableContentAnalysis && cfg.OpenAIAPIKey == "" {
-		fmt.Println("Warning: OPENAI_API_KEY not provided, content analysis will be disabled")
-		cfg.EnableContentAnalysis = false
+	return cfg, nil
+}
+
+// LLMProviderAPIKey returns the API key for the configured language model provider: the explicit
+// LLM API key, otherwise the provider's own key setting. The OpenAI key is never sent to an
+// OpenAI-compatible server at a custom base URL.
+func (c *Config) LLMProviderAPIKey() string {
+	if c.LLMAPIKey != "" {
+		return c.LLMAPIKey
+	}
+	switch c.LLMProvider {
+	case "anthropic":
+		return c.AnthropicAPIKey
+	case "openai":
+		if c.LLMBaseURL == "" {
+			return c.OpenAIAPIKey
+		}
 	}
+	return ""
+}
 
-	return cfg, nil
+// LLMAPIKeyMissing reports whether the configured language model provider needs an API key that is
+// not set. The fake provider and OpenAI-compatible servers at a custom base URL need none.
+func (c *Config) LLMAPIKeyMissing() bool {
+	requiresKey := c.LLMProvider == "anthropic" || (c.LLMProvider == "openai" && c.LLMBaseURL == "")
+	return requiresKey && c.LLMProviderAPIKey() == ""
+}
+
+// ForTenant returns a copy of the configuration with the tenant's overrides applied. Content
+// analysis is disabled for tenants whose provider has no API key.
+func (c *Config) ForTenant(tenant TenantConfig) *Config {
+	tenantCfg := *c
+
+	if tenant.FeedRefreshInterval != nil {
+		tenantCfg.FeedRefreshInterval = *tenant.FeedRefreshInterval
+	}
+	if tenant.RequestTimeout != nil {
+		tenantCfg.RequestTimeout = *tenant.RequestTimeout
+	}
+	if tenant.MaxConcurrentCrawls != nil {
+		tenantCfg.MaxConcurrentCrawls = *tenant.MaxConcurrentCrawls
+	}
+	if tenant.UserAgent != "" {
+		tenantCfg.UserAgent = tenant.UserAgent
+	}
+	if tenant.MaxPostsPerCrawl != nil {
+		tenantCfg.MaxPostsPerCrawl = *tenant.MaxPostsPerCrawl
+	}
+	if tenant.LLM != nil {
+		if tenant.LLM.Provider != "" && tenant.LLM.Provider != tenantCfg.LLMProvider {
+			// A different provider does not share the global endpoint, key or model
+			tenantCfg.LLMProvider = tenant.LLM.Provider
+			tenantCfg.LLMBaseURL = ""
+			tenantCfg.LLMAPIKey = ""
+			tenantCfg.LLMModel = ""
+		}
+		if tenant.LLM.BaseURL != "" {
+			tenantCfg.LLMBaseURL = tenant.LLM.BaseURL
+		}
+		if tenant.LLM.APIKey != "" {
+			tenantCfg.LLMAPIKey = tenant.LLM.APIKey
+		}
+		if tenant.LLM.Model != "" {
+			tenantCfg.LLMModel = tenant.LLM.Model
+		}
+		if tenant.LLM.Temperature != nil {
+			tenantCfg.LLMTemperature = *tenant.LLM.Temperature
+		}
+		if tenant.LLM.MaxTokens != nil {
+			tenantCfg.LLMMaxTokens = *tenant.LLM.MaxTokens
+		}
+		if tenant.LLM.StructuredOutput != nil {
+			tenantCfg.LLMStructuredOutput = *tenant.LLM.StructuredOutput
+		}
+		if tenant.LLM.RequestsPerMinute != nil {
+			tenantCfg.LLMRequestsPerMinute = *tenant.LLM.RequestsPerMinute
+		}
+		if tenant.LLM.TokensPerMinute != nil {
+			tenantCfg.LLMTokensPerMinute = *tenant.LLM.TokensPerMinute
+		}
+		if tenant.LLM.MaxRetries != nil {
+			tenantCfg.LLMMaxRetries = *tenant.LLM.MaxRetries
+		}
+		if tenant.LLM.MonthlyBudget != nil {
+			tenantCfg.LLMMonthlyBudget = *tenant.LLM.MonthlyBudget
+		}
+	}
+
+	// The API key is optional - if the provider needs one and it is not set, content analysis is skipped
+	if tenantCfg.EnableContentAnalysis && tenantCfg.LLMAPIKeyMissing() {
+		fmt.Printf("Warning: no API key for LLM provider %s in tenant %s, content analysis will be disabled\n", tenantCfg.LLMProvider, tenant.ID)
+		tenantCfg.EnableContentAnalysis = false
+	}
+
+	return &tenantCfg
 }
 
 // loadYAMLConfig loads configuration from tenants.yml file
@@ -208,6 +395,19 @@ func getConfigIntValue(yamlValue *int, envKey string, defaultValue int) int {
 	return getEnvInt(envKey, defaultValue)
 }
 
+// getConfigFloatValue returns YAML value if not nil, otherwise environment variable, otherwise default
+func getConfigFloatValue(yamlValue *float64, envKey string, defaultValue float64) float64 {
+	if yamlValue != nil {
+		return *yamlValue
+	}
+	if value := os.Getenv(envKey); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 // getConfigBoolValue returns YAML value if not nil, otherwise environment variable, otherwise default
 func getConfigBoolValue(yamlValue *bool, envKey string, defaultValue bool) bool {
 	if yamlValue != nil {
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestForTenant(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	floatPtr := func(v float64) *float64 { return &v }
+	boolPtr := func(v bool) *bool { return &v }
+
+	base := &Config{
+		FeedRefreshInterval:   300,
+		RequestTimeout:        30,
+		MaxConcurrentCrawls:   5,
+		UserAgent:             "base-agent",
+		MaxPostsPerCrawl:      20,
+		EnableContentAnalysis: true,
+		LLMProvider:           "openai",
+		LLMBaseURL:            "https://api.openai.example/v1",
+		LLMAPIKey:             "base-key",
+		LLMModel:              "gpt-4o-mini",
+		LLMTemperature:        0.1,
+		LLMMaxTokens:          500,
+		LLMStructuredOutput:   true,
+		LLMRequestsPerMinute:  60,
+		LLMTokensPerMinute:    90000,
+		LLMMaxRetries:         3,
+		LLMMonthlyBudget:      10,
+	}
+
+	tests := []struct {
+		name   string
+		tenant TenantConfig
+		check  func(t *testing.T, cfg *Config)
+	}{
+		{
+			name:   "no overrides inherits everything",
+			tenant: TenantConfig{ID: "t1"},
+			check: func(t *testing.T, cfg *Config) {
+				if !reflect.DeepEqual(cfg, base) {
+					t.Errorf("Expected the base config, got %+v", cfg)
+				}
+			},
+		},
+		{
+			name: "intervals and concurrency",
+			tenant: TenantConfig{
+				FeedRefreshInterval: intPtr(60),
+				RequestTimeout:      intPtr(10),
+				MaxConcurrentCrawls: intPtr(1),
+				UserAgent:           "tenant-agent",
+				MaxPostsPerCrawl:    intPtr(0),
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.FeedRefreshInterval != 60 || cfg.RequestTimeout != 10 || cfg.MaxConcurrentCrawls != 1 ||
+					cfg.UserAgent != "tenant-agent" || cfg.MaxPostsPerCrawl != 0 {
+					t.Errorf("Expected the tenant's overrides, got %+v", cfg)
+				}
+				if cfg.LLMModel != base.LLMModel || cfg.LLMAPIKey != base.LLMAPIKey {
+					t.Errorf("Expected the LLM settings to be inherited, got %+v", cfg)
+				}
+			},
+		},
+		{
+			name: "LLM settings of the same provider",
+			tenant: TenantConfig{LLM: &LLMConfig{
+				Provider:          "openai",
+				Model:             "gpt-4o",
+				Temperature:       floatPtr(0),
+				MaxTokens:         intPtr(200),
+				StructuredOutput:  boolPtr(false),
+				RequestsPerMinute: intPtr(10),
+				TokensPerMinute:   intPtr(1000),
+				MaxRetries:        intPtr(0),
+				MonthlyBudget:     floatPtr(2.5),
+			}},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.LLMModel != "gpt-4o" || cfg.LLMTemperature != 0 || cfg.LLMMaxTokens != 200 || cfg.LLMStructuredOutput ||
+					cfg.LLMRequestsPerMinute != 10 || cfg.LLMTokensPerMinute != 1000 || cfg.LLMMaxRetries != 0 || cfg.LLMMonthlyBudget != 2.5 {
+					t.Errorf("Expected the tenant's LLM overrides, got %+v", cfg)
+				}
+				if cfg.LLMBaseURL != base.LLMBaseURL || cfg.LLMAPIKey != base.LLMAPIKey {
+					t.Errorf("Expected the provider's endpoint and key to be inherited, got %+v", cfg)
+				}
+				if cfg.FeedRefreshInterval != base.FeedRefreshInterval || cfg.UserAgent != base.UserAgent {
+					t.Errorf("Expected the crawl settings to be inherited, got %+v", cfg)
+				}
+			},
+		},
+		{
+			name:   "different provider drops the base endpoint, key and model",
+			tenant: TenantConfig{LLM: &LLMConfig{Provider: "anthropic", APIKey: "tenant-key"}},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.LLMProvider != "anthropic" || cfg.LLMAPIKey != "tenant-key" || cfg.LLMBaseURL != "" || cfg.LLMModel != "" {
+					t.Errorf("Expected only the tenant's provider settings, got %+v", cfg)
+				}
+				if cfg.LLMMaxTokens != base.LLMMaxTokens || cfg.LLMMonthlyBudget != base.LLMMonthlyBudget {
+					t.Errorf("Expected provider-independent settings to be inherited, got %+v", cfg)
+				}
+				if !cfg.EnableContentAnalysis {
+					t.Error("Expected content analysis to stay enabled with the tenant's API key")
+				}
+			},
+		},
+		{
+			name:   "provider without an API key disables content analysis",
+			tenant: TenantConfig{ID: "t1", LLM: &LLMConfig{Provider: "anthropic"}},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.EnableContentAnalysis {
+					t.Error("Expected content analysis to be disabled without an Anthropic API key")
+				}
+			},
+		},
+		{
+			name:   "provider without API keys keeps content analysis",
+			tenant: TenantConfig{ID: "t1", LLM: &LLMConfig{Provider: "fake"}},
+			check: func(t *testing.T, cfg *Config) {
+				if !cfg.EnableContentAnalysis {
+					t.Error("Expected content analysis to stay enabled for a provider without API keys")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := *base
+			tt.check(t, base.ForTenant(tt.tenant))
+			if !reflect.DeepEqual(*base, before) {
+				t.Error("Expected ForTenant to leave the base config unchanged")
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"strandnerd-crawler/internal/models"
+	"strandnerd-crawler/internal/state"
+)
+
+// BackoffConfig controls how feeds that keep failing are retried
+type BackoffConfig struct {
+	BaseDelay        time.Duration // wait after the first failure, doubled for each further one
+	MaxDelay         time.Duration
+	FailureThreshold int           // consecutive failures that park a feed, 0 never parks
+	ParkDuration     time.Duration // wait between probe crawls of a parked feed
+}
+
+// delay returns how long to wait after the given number of consecutive failures
+func (b BackoffConfig) delay(failures int) time.Duration {
+	delay := b.BaseDelay
+	for i := 1; i < failures && delay > 0 && (b.MaxDelay <= 0 || delay < b.MaxDelay); i++ {
+		delay *= 2
+	}
+	if b.MaxDelay > 0 && delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+	return delay
+}
+
+// recordFailure returns the feed's failure tracking after another failed crawl.
+// Failures of a previous feed URL are discarded since editors fix broken feeds by changing the URL.
+func (b BackoffConfig) recordFailure(failures state.FailureState, feedURL string, crawlErr error, now time.Time) state.FailureState {
+	if failures.FeedURL != feedURL {
+		failures = state.FailureState{FeedURL: feedURL}
+	}
+
+	failures.ConsecutiveFailures++
+	failures.LastError = crawlErr.Error()
+	failures.LastFailureAt = now
+	failures.Parked = b.FailureThreshold > 0 && failures.ConsecutiveFailures >= b.FailureThreshold
+
+	delay := b.delay(failures.ConsecutiveFailures)
+	if failures.Parked {
+		delay = max(delay, b.ParkDuration)
+	}
+	failures.RetryAfter = now.Add(delay)
+
+	return failures
+}
+
+// backoffUntil returns when a failing feed may be crawled again, zero when it is not backing off
+func (s *Service) backoffUntil(feed *models.InspirationFeed) time.Time {
+	failures := s.state.GetFailureState(feed.ID)
+	if failures.ConsecutiveFailures == 0 || failures.FeedURL != feed.URL {
+		return time.Time{}
+	}
+	return failures.RetryAfter
+}
+
+// updateFailureState tracks consecutive failures after a crawl and reports changes to the CMS.
+// Crawls interrupted by shutdown or failed by CMS errors are not held against the feed.
+func (s *Service) updateFailureState(ctx context.Context, feed *models.InspirationFeed, result *models.CrawlResult) {
+	previous := s.state.GetFailureState(feed.ID)
+
+	var current state.FailureState
+	switch {
+	case result.Success:
+		if previous.ConsecutiveFailures == 0 {
+			return
+		}
+		log.Printf("✅ Feed %s recovered after %d failed crawl(s)", feed.Name, previous.ConsecutiveFailures)
+	case result.Error == nil || ctx.Err() != nil || isCMSError(result.Error):
+		return
+	default:
+		current = s.backoff.recordFailure(previous, feed.URL, result.Error, time.Now())
+		result.RetryAfter = current.RetryAfter
+
+		if current.Parked && !previous.Parked {
+			log.Printf("⛔ Feed %s parked after %d consecutive failures, probing again at %s",
+				feed.Name, current.ConsecutiveFailures, current.RetryAfter.Format(time.RFC3339))
+		} else {
+			log.Printf("⚠️ Feed %s failed %d time(s) in a row, backing off until %s",
+				feed.Name, current.ConsecutiveFailures, current.RetryAfter.Format(time.RFC3339))
+		}
+	}
+
+	if err := s.state.SetFailureState(feed.ID, current); err != nil {
+		log.Printf("Warning: failed to store failure state for feed %s: %v", feed.ID, err)
+	}
+
+	if err := s.cmsClient.UpdateFeedCrawlStatus(ctx, feed.ID, crawlStatus(current)); err != nil {
+		log.Printf("Warning: failed to report crawl status for feed %s: %v", feed.ID, err)
+	}
+}
+
+// crawlStatus converts the failure tracking into the status reported to the CMS
+func crawlStatus(failures state.FailureState) *models.FeedCrawlStatus {
+	status := &models.FeedCrawlStatus{
+		Status:              models.FeedStatusOK,
+		ConsecutiveFailures: failures.ConsecutiveFailures,
+	}
+	if failures.ConsecutiveFailures == 0 {
+		return status
+	}
+
+	status.Status = models.FeedStatusFailing
+	if failures.Parked {
+		status.Status = models.FeedStatusParked
+	}
+
+	lastFailureAt := failures.LastFailureAt.UTC().Format(time.RFC3339)
+	nextRetryAt := failures.RetryAfter.UTC().Format(time.RFC3339)
+	status.LastError = &failures.LastError
+	status.LastFailureAt = &lastFailureAt
+	status.NextRetryAt = &nextRetryAt
+
+	return status
+}
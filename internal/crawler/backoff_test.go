@@ -0,0 +1,50 @@
+package crawler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"strandnerd-crawler/internal/state"
+)
+
+func TestBackoffParksFeedAfterThreshold(t *testing.T) {
+	backoff := BackoffConfig{
+		BaseDelay:        5 * time.Minute,
+		MaxDelay:         time.Hour,
+		FailureThreshold: 5,
+		ParkDuration:     24 * time.Hour,
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	crawlErr := errors.New("connection refused")
+
+	expected := []time.Duration{5 * time.Minute, 10 * time.Minute, 20 * time.Minute, 40 * time.Minute}
+
+	var failures state.FailureState
+	for i, delay := range expected {
+		failures = backoff.recordFailure(failures, "https://example.com/feed", crawlErr, now)
+		if failures.ConsecutiveFailures != i+1 {
+			t.Fatalf("expected %d failures, got %d", i+1, failures.ConsecutiveFailures)
+		}
+		if got := failures.RetryAfter.Sub(now); got != delay {
+			t.Errorf("failure %d: expected backoff %v, got %v", i+1, delay, got)
+		}
+		if failures.Parked {
+			t.Errorf("failure %d: feed parked before reaching the threshold", i+1)
+		}
+	}
+
+	failures = backoff.recordFailure(failures, "https://example.com/feed", crawlErr, now)
+	if !failures.Parked {
+		t.Fatal("expected feed to be parked after 5 failures")
+	}
+	if got := failures.RetryAfter.Sub(now); got != 24*time.Hour {
+		t.Errorf("expected parked feed to wait the park duration, got %v", got)
+	}
+
+	// A new URL starts counting from scratch
+	failures = backoff.recordFailure(failures, "https://example.com/new-feed", crawlErr, now)
+	if failures.ConsecutiveFailures != 1 || failures.Parked {
+		t.Errorf("expected failures to reset for a new URL, got %+v", failures)
+	}
+}
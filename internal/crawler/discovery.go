@@ -0,0 +1,63 @@
+package crawler
+
+import (
+	"context"
+	"log"
+
+	"strandnerd-crawler/internal/client"
+	"strandnerd-crawler/internal/models"
+)
+
+// fetchURL returns the URL a feed is fetched from: the feed discovered on the web page configured
+// as its URL, which is restored from the state store after a restart, or the configured URL
+func (s *Service) fetchURL(feed *models.InspirationFeed) string {
+	if discovered, ok := s.rssParser.GetDiscoveredFeedURL(feed.URL); ok {
+		return discovered
+	}
+	if discovered, ok := s.state.GetDiscoveredFeed(feed.ID, feed.URL); ok {
+		s.rssParser.SetDiscoveredFeedURL(feed.URL, discovered)
+		return discovered
+	}
+	return feed.URL
+}
+
+// storeDiscoveredFeedURL keeps the parser's discovery for a feed in the state store, so the web page
+// is not downloaded again after a restart. A discovery the parser dropped is removed as well.
+func (s *Service) storeDiscoveredFeedURL(feed *models.InspirationFeed) {
+	discovered, _ := s.rssParser.GetDiscoveredFeedURL(feed.URL)
+	if err := s.state.SetDiscoveredFeed(feed.ID, feed.URL, discovered); err != nil {
+		log.Printf("Warning: failed to store discovered feed URL for feed %s: %v", feed.ID, err)
+	}
+}
+
+// reportDiscoveredFeedURL tells the CMS about the feed discovered on the web page configured as
+// a feed's URL so editors can correct the record. Each discovery is reported once per run.
+func (s *Service) reportDiscoveredFeedURL(ctx context.Context, feed *models.InspirationFeed) {
+	discovered, ok := s.rssParser.GetDiscoveredFeedURL(feed.URL)
+	if !ok {
+		return
+	}
+
+	s.reportedMutex.Lock()
+	if s.reportedFeedURLs[feed.ID] == discovered {
+		s.reportedMutex.Unlock()
+		return
+	}
+	s.reportedFeedURLs[feed.ID] = discovered
+	s.reportedMutex.Unlock()
+
+	log.Printf("🔗 Feed %s is configured with web page %s, reporting its feed URL %s to the CMS",
+		feed.Name, feed.URL, discovered)
+
+	update := &models.UpdateFeedURLRequest{URL: discovered, PreviousURL: feed.URL}
+	if err := s.cmsClient.UpdateFeedURL(ctx, feed.ID, update); err != nil {
+		log.Printf("Warning: failed to report discovered feed URL for feed %s: %v", feed.ID, err)
+
+		// Try again on the next crawl unless the CMS rejected the update outright
+		if !isCMSError(err, client.ErrorKindNotFound, client.ErrorKindClient) {
+			s.reportedMutex.Lock()
+			delete(s.reportedFeedURLs, feed.ID)
+			s.reportedMutex.Unlock()
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"strandnerd-crawler/internal/client"
+	"strandnerd-crawler/internal/models"
+	"strandnerd-crawler/internal/parser"
+)
+
+// PipelineConfig bounds how many posts of a single feed are extracted and analysed concurrently.
+// Posts are always created one at a time.
+type PipelineConfig struct {
+	ExtractWorkers  int
+	AnalysisWorkers int
+}
+
+// postJob tracks a single post as it moves through the pipeline
+type postJob struct {
+	post *models.CreateInspirationFeedPostRequest
+	err  error
+}
+
+// processPosts runs new posts through page extraction, content analysis and CMS creation.
+// Extraction and analysis have their own worker pools, creation follows the order of posts so the
+// CMS receives them in the order the caller sorted them. The returned jobs are in the same order
+// as posts. Once ctx is cancelled, posts that have not been created yet fail with the context error.
+func (s *Service) processPosts(ctx context.Context, feed *models.InspirationFeed, posts []*models.CreateInspirationFeedPostRequest) []*postJob {
+	jobs := make([]*postJob, len(posts))
+	for i, post := range posts {
+		jobs[i] = &postJob{post: post}
+	}
+
+	input := make(chan *postJob)
+	go func() {
+		defer close(input)
+		for _, job := range jobs {
+			input <- job
+		}
+	}()
+
+	extracted := runStage(ctx, s.pipeline.ExtractWorkers, input, s.extractPost)
+	analyzed := runStage(ctx, s.pipeline.AnalysisWorkers, extracted, s.analyzePost)
+	created := runStage(ctx, 1, inOrder(jobs, analyzed), func(ctx context.Context, job *postJob) {
+		s.createPost(ctx, feed, job)
+	})
+
+	// Drain the last stage; results are already stored in jobs
+	for range created {
+	}
+
+	return jobs
+}
+
+// runStage starts workers that apply fn to every job from in and forward it to the returned channel,
+// which is closed once in is exhausted and all workers are done
+func runStage(ctx context.Context, workers int, in <-chan *postJob, fn func(context.Context, *postJob)) <-chan *postJob {
+	out := make(chan *postJob)
+	var wg sync.WaitGroup
+
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range in {
+				if job.err == nil && ctx.Err() != nil {
+					job.err = ctx.Err()
+				}
+				if job.err == nil {
+					fn(ctx, job)
+				}
+				out <- job
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// inOrder forwards the jobs from in in the order of jobs, holding back jobs that arrive early
+// until every job before them has arrived
+func inOrder(jobs []*postJob, in <-chan *postJob) <-chan *postJob {
+	out := make(chan *postJob)
+
+	go func() {
+		defer close(out)
+		arrived := make(map[*postJob]bool, len(jobs))
+		next := 0
+		for job := range in {
+			arrived[job] = true
+			for next < len(jobs) && arrived[jobs[next]] {
+				out <- jobs[next]
+				next++
+			}
+		}
+	}()
+
+	return out
+}
+
+// pageExtractor fills posts from their article pages with the parser's content extractor
+func pageExtractor(rssParser *parser.RSSParser) func(context.Context, *models.CreateInspirationFeedPostRequest) error {
+	return func(ctx context.Context, post *models.CreateInspirationFeedPostRequest) error {
+		return parser.ExtractPostContent(ctx, post, rssParser.GetContentExtractor())
+	}
+}
+
+// extractPost fetches the article page for a new post
+func (s *Service) extractPost(ctx context.Context, job *postJob) {
+	post := job.post
+	if err := s.extractContent(ctx, post); err != nil {
+		log.Printf("Warning: failed to extract page content for '%s': %v", post.Title, err)
+	}
+}
+
+// analyzePost classifies the post as primary or referenced reporting
+func (s *Service) analyzePost(ctx context.Context, job *postJob) {
+	post := job.post
+
+	// Analyze content with GPT if enabled
+	if s.enableContentAnalysis && s.analyzer != nil {
+		log.Printf("🔍 Analyzing content for post: %s", post.Title)
+
+		analysisReq := &models.ContentAnalysisRequest{
+			Title:       post.Title,
+			Description: post.Description,
+			Content:     post.Content,
+			FullContent: post.FullContent,
+			URL:         post.URL,
+			FeedID:      post.InspirationFeedID,
+		}
+
+		analysis, err := s.analyzer.AnalyzeContent(ctx, analysisReq)
+		if ctx.Err() != nil {
+			job.err = ctx.Err()
+			return
+		}
+		if err != nil {
+			log.Printf("Warning: content analysis failed for post '%s': %v", post.Title, err)
+			// Set defaults for failed analysis - assume referenced reporting to be conservative
+			falseVal := false
+			post.IsPrimaryReporting = &falseVal
+			post.OriginalSourceName = nil
+		} else {
+			// Apply analysis results
+			post.IsPrimaryReporting = &analysis.IsPrimaryReporting
+			post.OriginalSourceName = analysis.OriginalSourceName
+
+			log.Printf("✅ Content analysis for '%s': primary=%v, source=%v, confidence=%.2f, reasoning=%s",
+				post.Title, analysis.IsPrimaryReporting,
+				func() string {
+					if analysis.OriginalSourceName != nil {
+						return *analysis.OriginalSourceName
+					}
+					return "none"
+				}(), analysis.Confidence, analysis.Reasoning)
+
+			// Debug: Log what we're about to send to CMS
+			log.Printf("Post after analysis - Title: %s, IsPrimaryReporting: %v, OriginalSourceName: %v",
+				post.Title,
+				func() string {
+					if post.IsPrimaryReporting != nil {
+						return fmt.Sprintf("%v", *post.IsPrimaryReporting)
+					}
+					return "nil"
+				}(),
+				func() string {
+					if post.OriginalSourceName != nil {
+						return *post.OriginalSourceName
+					}
+					return "nil"
+				}())
+		}
+	} else {
+		log.Printf("❌ Content analysis disabled - enabled=%v, client_available=%v for post: %s",
+			s.enableContentAnalysis, s.analyzer != nil, post.Title)
+
+		// When LLM analysis is disabled, assume most articles are primary reporting unless proven otherwise
+		// This is more balanced than always assuming referenced reporting
+		trueVal := true
+		post.IsPrimaryReporting = &trueVal
+		post.OriginalSourceName = nil
+		log.Printf("🔧 LLM analysis disabled - defaulting to primary reporting (can be overridden if obvious references found)")
+	}
+}
+
+// createPost creates the post in the CMS and records it in the local state store
+func (s *Service) createPost(ctx context.Context, feed *models.InspirationFeed, job *postJob) {
+	_, err := s.cmsClient.CreateInspirationFeedPost(ctx, job.post)
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+		// The CMS already has the post, don't try it again on the next crawl
+		s.markPostSeen(feed.ID, job.post)
+	}
+	if err != nil {
+		job.err = err
+		return
+	}
+
+	s.markPostSeen(feed.ID, job.post)
+}
@@ -0,0 +1,131 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"strandnerd-crawler/internal/models"
+	"strandnerd-crawler/internal/state"
+)
+
+func TestRunStageProcessesEveryJobOnce(t *testing.T) {
+	jobs := make([]*postJob, 50)
+	for i := range jobs {
+		jobs[i] = &postJob{post: &models.CreateInspirationFeedPostRequest{}}
+	}
+	jobs[7].err = errors.New("failed in an earlier stage")
+
+	input := make(chan *postJob)
+	go func() {
+		defer close(input)
+		for _, job := range jobs {
+			input <- job
+		}
+	}()
+
+	var calls int32
+	out := runStage(context.Background(), 4, input, func(ctx context.Context, job *postJob) {
+		atomic.AddInt32(&calls, 1)
+		job.post.Title = "processed"
+	})
+
+	received := 0
+	for range out {
+		received++
+	}
+
+	if received != len(jobs) {
+		t.Errorf("Expected %d jobs forwarded, got %d", len(jobs), received)
+	}
+	if calls != int32(len(jobs)-1) {
+		t.Errorf("Expected %d calls (failed job skipped), got %d", len(jobs)-1, calls)
+	}
+	if jobs[7].post.Title != "" {
+		t.Errorf("Job that already failed must not be processed again")
+	}
+}
+
+func TestInOrderRestoresPostOrder(t *testing.T) {
+	jobs := make([]*postJob, 5)
+	for i := range jobs {
+		jobs[i] = &postJob{post: &models.CreateInspirationFeedPostRequest{Title: string(rune('a' + i))}}
+	}
+
+	// Later posts finish the earlier stages first
+	input := make(chan *postJob)
+	go func() {
+		defer close(input)
+		for _, i := range []int{3, 1, 4, 0, 2} {
+			input <- jobs[i]
+		}
+	}()
+
+	var order string
+	for job := range inOrder(jobs, input) {
+		order += job.post.Title
+	}
+
+	if order != "abcde" {
+		t.Errorf("Expected posts in their original order, got %s", order)
+	}
+}
+
+func TestProcessPostsExtractsOnlyNewPostsAndCreatesThemInFeedOrder(t *testing.T) {
+	feedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Test</title>
+<item><title>Fourth</title><link>%[1]s/fourth</link><guid>fourth</guid><pubDate>Thu, 07 Mar 2024 10:00:00 GMT</pubDate></item>
+<item><title>Third</title><link>%[1]s/third</link><guid>third</guid><pubDate>Wed, 06 Mar 2024 10:00:00 GMT</pubDate></item>
+<item><title>Second</title><link>%[1]s/second</link><guid>second</guid><pubDate>Tue, 05 Mar 2024 10:00:00 GMT</pubDate></item>
+<item><title>First</title><link>%[1]s/first</link><guid>first</guid><pubDate>Mon, 04 Mar 2024 10:00:00 GMT</pubDate></item>
+</channel></rss>`, "http://"+r.Host)
+	}))
+	defer feedServer.Close()
+
+	var mutex sync.Mutex
+	var created []string
+	stateStore := state.NewMemoryStore()
+	if err := stateStore.MarkSeen("feed-1", "third"); err != nil {
+		t.Fatalf("Failed to mark post seen: %v", err)
+	}
+	service := newTestServiceWithState(t, func(post models.CreateInspirationFeedPostRequest) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		created = append(created, post.Title)
+	}, stateStore)
+	service.pipeline = PipelineConfig{ExtractWorkers: 3, AnalysisWorkers: 3}
+
+	// Newer posts take longer to extract, so they finish after the older ones
+	extracted := make(map[string]int)
+	delays := map[string]time.Duration{"Fourth": 40 * time.Millisecond, "Third": 30 * time.Millisecond, "Second": 20 * time.Millisecond}
+	service.extractContent = func(ctx context.Context, post *models.CreateInspirationFeedPostRequest) error {
+		mutex.Lock()
+		extracted[post.Title]++
+		mutex.Unlock()
+		time.Sleep(delays[post.Title])
+		return nil
+	}
+
+	feed := &models.InspirationFeed{ID: "feed-1", Name: "Test", URL: feedServer.URL}
+	result := service.crawlSingleFeed(context.Background(), feed)
+	if !result.Success || result.PostsAdded != 3 || result.PostsSkipped != 1 {
+		t.Fatalf("Expected three posts added and one skipped, got %+v", result)
+	}
+
+	expected := map[string]int{"Fourth": 1, "Second": 1, "First": 1}
+	if !reflect.DeepEqual(extracted, expected) {
+		t.Errorf("Expected each new post extracted once and the seen post not at all, got %v", extracted)
+	}
+	if order := strings.Join(created, ","); order != "Fourth,Second,First" {
+		t.Errorf("Expected posts created newest first, got %s", order)
+	}
+}
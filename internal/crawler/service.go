@@ -1,11 +1,14 @@
 package crawler
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -15,6 +18,7 @@ import (
 	"strandnerd-crawler/internal/llm"
 	"strandnerd-crawler/internal/models"
 	"strandnerd-crawler/internal/parser"
+	"strandnerd-crawler/internal/state"
 )
 
 // Service handles the crawling logic
@@ -22,7 +26,18 @@ type Service struct {
 	cmsClient             *client.CMSClient
 	rssParser             *parser.RSSParser
 	cache                 *FeedCache
-	llmClient             *llm.Client
+	state                 state.Store
+	analyzer              llm.Analyzer
+	extractContent        func(context.Context, *models.CreateInspirationFeedPostRequest) error // fills a post from its article page
+	usage                 *llm.UsageLedger
+	pipeline              PipelineConfig
+	backoff               BackoffConfig
+	crawlSlots            chan struct{}     // bounds concurrent feed crawls across all entry points
+	reportedFeedURLs      map[string]string // feed ID -> discovered feed URL already reported to the CMS
+	reportedMutex         sync.Mutex
+	siteRulesFetchedAt    time.Time // last successful fetch of the tenant's site rules from the CMS
+	siteRulesMutex        sync.Mutex
+	maxPostsPerCrawl      int
 	enableContentAnalysis bool
 	enableHTMLCleanup     bool
 }
@@ -58,28 +73,54 @@ func getAndDisplayPublicIP(client *http.Client) {
 	log.Printf("IP Details: https://whatismyipaddress.com/ip/%s", ipAddress)
 }
 
-// NewService creates a new crawler service
-func NewService(cmsClient *client.CMSClient, cfg *config.Config) *Service {
-	log.Printf("🔧 Service Config - EnableContentAnalysis: %v, OpenAIAPIKey: %s",
-		cfg.EnableContentAnalysis,
-		func() string {
-			if cfg.OpenAIAPIKey != "" {
-				return "***provided***"
+// NewService creates a new crawler service. analysisCache is shared by the services of all
+// tenants, nil disables caching of content analyses. usage records the tenant's language model
+// calls and their cost, nil disables accounting and the monthly budget.
+func NewService(cmsClient *client.CMSClient, cfg *config.Config, stateStore state.Store, analysisCache *llm.AnalysisCache, usage *llm.UsageLedger) *Service {
+	log.Printf("🔧 Service Config - EnableContentAnalysis: %v, LLM provider: %s, model: %s",
+		cfg.EnableContentAnalysis, cfg.LLMProvider, func() string {
+			if cfg.LLMModel != "" {
+				return cfg.LLMModel
 			}
-			return "empty"
+			return "default"
 		}())
 
-	var llmClient *llm.Client
-	if cfg.EnableContentAnalysis && cfg.OpenAIAPIKey != "" {
-		llmClient = llm.NewClient(cfg.OpenAIAPIKey)
-		log.Printf("✅ LLM client created successfully with rate limiting and 5-minute timeout")
+	var analyzer llm.Analyzer
+	if cfg.EnableContentAnalysis {
+		llmClient, err := llm.NewClient(llm.Config{
+			Provider:    cfg.LLMProvider,
+			BaseURL:     cfg.LLMBaseURL,
+			APIKey:      cfg.LLMProviderAPIKey(),
+			Model:       cfg.LLMModel,
+			Temperature: cfg.LLMTemperature,
+			MaxTokens:   cfg.LLMMaxTokens,
+
+			DisableStructuredOutput: !cfg.LLMStructuredOutput,
+			Cache:                   analysisCache,
+			RequestsPerMinute:       cfg.LLMRequestsPerMinute,
+			TokensPerMinute:         cfg.LLMTokensPerMinute,
+			MaxRetries:              cfg.LLMMaxRetries,
+			Usage:                   usage,
+			Prices:                  cfg.LLMPrices,
+			MonthlyBudget:           cfg.LLMMonthlyBudget,
+		})
+		if err != nil {
+			log.Printf("❌ LLM client not created, content analysis disabled: %v", err)
+		} else {
+			analyzer = llmClient
+			log.Printf("✅ LLM client created successfully for %s (%s) with rate limiting", llmClient.Provider(), llmClient.Model())
+		}
 	} else {
-		log.Printf("❌ LLM client not created - enabled: %v, key_provided: %v",
-			cfg.EnableContentAnalysis, cfg.OpenAIAPIKey != "")
+		log.Printf("❌ LLM client not created - content analysis disabled")
+	}
+
+	requestTimeout := time.Duration(cfg.RequestTimeout) * time.Second
+	if requestTimeout <= 0 {
+		requestTimeout = 30 * time.Second
 	}
 
 	cralwerClient := &http.Client{
-		Timeout: 30 * time.Second,
+		Timeout: requestTimeout,
 	}
 
 	// Panic if cfg.ProxyAuth or cfg.ProxyHost are not set. Crawler MUST have proxy set
@@ -98,30 +139,57 @@ func NewService(cmsClient *client.CMSClient, cfg *config.Config) *Service {
 
 	getAndDisplayPublicIP(cralwerClient)
 
+	rssParser := parser.NewRSSParser(cralwerClient, cfg)
 	return &Service{
-		cmsClient:             cmsClient,
-		rssParser:             parser.NewRSSParser(cralwerClient, cfg),
-		cache:                 NewFeedCache(5 * time.Minute), // Cache for 5 minutes
-		llmClient:             llmClient,
+		cmsClient:      cmsClient,
+		rssParser:      rssParser,
+		cache:          NewFeedCache(time.Duration(cfg.FeedRefreshInterval) * time.Minute),
+		state:          stateStore,
+		analyzer:       analyzer,
+		extractContent: pageExtractor(rssParser),
+		usage:          usage,
+		pipeline: PipelineConfig{
+			ExtractWorkers:  cfg.ExtractConcurrency,
+			AnalysisWorkers: cfg.AnalysisConcurrency,
+		},
+		backoff: BackoffConfig{
+			BaseDelay:        time.Duration(cfg.FeedBackoffBase) * time.Minute,
+			MaxDelay:         time.Duration(cfg.FeedBackoffMax) * time.Minute,
+			FailureThreshold: cfg.FeedFailureThreshold,
+			ParkDuration:     time.Duration(cfg.FeedParkDuration) * time.Minute,
+		},
+		crawlSlots:            make(chan struct{}, max(cfg.MaxConcurrentCrawls, 1)),
+		reportedFeedURLs:      make(map[string]string),
+		maxPostsPerCrawl:      cfg.MaxPostsPerCrawl,
 		enableContentAnalysis: cfg.EnableContentAnalysis,
 		enableHTMLCleanup:     false, // Removed config field, set to false
 	}
 }
 
 // CrawlAllDueFeeds crawls all feeds that are due for crawling
-func (s *Service) CrawlAllDueFeeds() ([]models.CrawlResult, error) {
+func (s *Service) CrawlAllDueFeeds(ctx context.Context) ([]models.CrawlResult, error) {
 	// Get all feeds from CMS (this will be cached)
-	feeds, err := s.cache.GetFeeds(s.cmsClient)
+	feeds, err := s.cache.GetFeeds(ctx, s.cmsClient)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get feeds: %w", err)
 	}
 
-	// Filter feeds that are due for crawling
+	// Filter feeds that are due for crawling and not backing off after failures
 	var dueFeeds []models.InspirationFeed
+	backingOff := 0
 	for _, feed := range feeds {
-		if feed.IsDue() {
-			dueFeeds = append(dueFeeds, feed)
+		if !feed.IsDue() {
+			continue
+		}
+		if time.Now().Before(s.backoffUntil(&feed)) {
+			backingOff++
+			continue
 		}
+		dueFeeds = append(dueFeeds, feed)
+	}
+
+	if backingOff > 0 {
+		log.Printf("Skipping %d failing feeds that are backing off", backingOff)
 	}
 
 	if len(dueFeeds) == 0 {
@@ -132,19 +200,13 @@ func (s *Service) CrawlAllDueFeeds() ([]models.CrawlResult, error) {
 
 	// Crawl feeds with limited concurrency
 	results := make([]models.CrawlResult, len(dueFeeds))
-	semaphore := make(chan struct{}, 3) // Max 3 concurrent crawls
 	var wg sync.WaitGroup
 
 	for i, feed := range dueFeeds {
 		wg.Add(1)
 		go func(index int, f models.InspirationFeed) {
 			defer wg.Done()
-
-			semaphore <- struct{}{} // Acquire
-			result := s.crawlSingleFeed(&f)
-			<-semaphore // Release
-
-			results[index] = *result
+			results[index] = *s.CrawlScheduledFeed(ctx, &f)
 		}(i, feed)
 	}
 
@@ -152,19 +214,66 @@ func (s *Service) CrawlAllDueFeeds() ([]models.CrawlResult, error) {
 	return results, nil
 }
 
-// CrawlFeed crawls a specific feed by ID
-func (s *Service) CrawlFeed(feedID string) (*models.CrawlResult, error) {
+// Feeds returns the tenant's feeds from the cache, refreshing it from the CMS when expired
+func (s *Service) Feeds(ctx context.Context) ([]models.InspirationFeed, error) {
+	return s.cache.GetFeeds(ctx, s.cmsClient)
+}
+
+// CrawlScheduledFeed crawls an already loaded feed, waiting for a free crawl slot first.
+// Each feed additionally bounds its own extraction, analysis and creation stages (see PipelineConfig).
+// Feeds backing off after failures are skipped with RetryAfter set to their next allowed crawl.
+func (s *Service) CrawlScheduledFeed(ctx context.Context, feed *models.InspirationFeed) *models.CrawlResult {
+	if retryAfter := s.backoffUntil(feed); time.Now().Before(retryAfter) {
+		return &models.CrawlResult{FeedID: feed.ID, BackedOff: true, RetryAfter: retryAfter}
+	}
+
+	if !s.acquireCrawlSlot(ctx) {
+		return &models.CrawlResult{FeedID: feed.ID, Error: fmt.Errorf("crawl not started: %w", ctx.Err())}
+	}
+	defer s.releaseCrawlSlot()
+
+	return s.crawlSingleFeed(ctx, feed)
+}
+
+// acquireCrawlSlot waits for a free crawl slot, false when ctx ended first
+func (s *Service) acquireCrawlSlot(ctx context.Context) bool {
+	select {
+	case s.crawlSlots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// releaseCrawlSlot frees a slot taken by acquireCrawlSlot
+func (s *Service) releaseCrawlSlot() {
+	<-s.crawlSlots
+}
+
+// CrawlFeed crawls a specific feed by ID, regardless of any failure backoff, waiting for a free
+// crawl slot like scheduled crawls
+func (s *Service) CrawlFeed(ctx context.Context, feedID string) (*models.CrawlResult, error) {
 	// Get the specific feed from CMS
-	feed, err := s.cmsClient.GetInspirationFeedByID(feedID)
+	feed, err := s.cmsClient.GetInspirationFeedByID(ctx, feedID)
+	if isCMSError(err, client.ErrorKindNotFound) {
+		return nil, fmt.Errorf("feed %s does not exist in the CMS: %w", feedID, err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get feed %s: %w", feedID, err)
 	}
 
-	return s.crawlSingleFeed(feed), nil
+	if !s.acquireCrawlSlot(ctx) {
+		return nil, fmt.Errorf("crawl of feed %s not started: %w", feedID, ctx.Err())
+	}
+	defer s.releaseCrawlSlot()
+
+	return s.crawlSingleFeed(ctx, feed), nil
 }
 
 // crawlSingleFeed crawls a single feed and returns the result
-func (s *Service) crawlSingleFeed(feed *models.InspirationFeed) *models.CrawlResult {
+// A cancelled context stops the crawl before further posts are created and leaves the feed's
+// last crawled timestamp untouched so it is picked up again after a restart.
+func (s *Service) crawlSingleFeed(ctx context.Context, feed *models.InspirationFeed) *models.CrawlResult {
 	result := &models.CrawlResult{
 		FeedID:  feed.ID,
 		Success: false,
@@ -172,13 +281,43 @@ func (s *Service) crawlSingleFeed(feed *models.InspirationFeed) *models.CrawlRes
 
 	log.Printf("Crawling feed: %s (%s)", feed.Name, feed.URL)
 
-	// Parse the RSS feed
-	rssFeed, err := s.rssParser.ParseFeed(feed.URL)
+	defer s.flushState()
+	defer s.recordCrawlOutcome(result)
+	defer s.updateFailureState(ctx, feed, result)
+
+	// Restore the discovered feed and cache validators from local state so conditional requests survive restarts
+	fetchURL := s.fetchURL(feed)
+	if _, ok := s.rssParser.GetValidators(fetchURL); !ok {
+		if v, ok := s.state.GetValidators(feed.ID, fetchURL); ok {
+			s.rssParser.SetValidators(fetchURL, parser.FeedValidators{ETag: v.ETag, LastModified: v.LastModified})
+		}
+	}
+
+	// Pick up extraction rules edited in the CMS before any article page is fetched
+	s.refreshSiteRules(ctx)
+
+	// Read the feed's items, whatever kind of source it is
+	rssFeed, err := s.fetchFeedItems(ctx, feed)
+	if errors.Is(err, parser.ErrNotModified) {
+		log.Printf("Feed %s not modified since last crawl", feed.Name)
+		if err := s.cmsClient.UpdateFeedLastCrawledAt(ctx, feed.ID); err != nil {
+			log.Printf("Warning: failed to update last crawled timestamp for feed %s: %v", feed.ID, err)
+		}
+		result.NotModified = true
+		result.Success = true
+		return result
+	}
 	if err != nil {
 		result.Error = fmt.Errorf("failed to parse feed: %w", err)
 		return result
 	}
 
+	// Keep the response's validators only if all of its posts get handled in this crawl
+	failed := 0
+	defer func() {
+		s.commitValidators(feed, result.Success && result.PostsDeferred == 0 && failed == 0)
+	}()
+
 	result.PostsFound = len(rssFeed.Items)
 	log.Printf("Found %d items in feed %s", result.PostsFound, feed.Name)
 
@@ -187,117 +326,225 @@ func (s *Service) crawlSingleFeed(feed *models.InspirationFeed) *models.CrawlRes
 		return result
 	}
 
-	// Convert RSS items to inspiration posts
-	posts := parser.ConvertToInspirationPosts(feed.ID, rssFeed.Items, s.rssParser.GetContentExtractor())
+	// Convert RSS items to inspiration posts (feed data only, pages are fetched after deduplication)
+	posts := parser.ConvertToInspirationPosts(feed.ID, rssFeed.Items)
 
-	// Get existing posts to check for duplicates
-	existingPosts, err := s.cmsClient.GetInspirationPosts(feed.ID, 100)
-	if err != nil {
-		log.Printf("Warning: failed to get existing posts for feed %s: %v", feed.ID, err)
-		existingPosts = []models.InspirationFeedPost{} // Continue with empty list
+	// Drop posts the local state store has already seen before asking the CMS
+	var unseenPosts []*models.CreateInspirationFeedPostRequest
+	for _, post := range posts {
+		if s.hasSeenPost(feed.ID, post) {
+			s.markPostSeen(feed.ID, post) // Refresh so entries still in the feed are not pruned
+			result.PostsSkipped++
+			continue
+		}
+		unseenPosts = append(unseenPosts, post)
 	}
 
 	// Create a map of existing GUIDs for faster lookup
 	existingGUIDs := make(map[string]bool)
-	for _, post := range existingPosts {
-		if post.GUID != nil {
-			existingGUIDs[*post.GUID] = true
+	if len(unseenPosts) > 0 {
+		// Get existing posts to check for duplicates
+		existingPosts, err := s.cmsClient.GetInspirationPosts(ctx, feed.ID, 100)
+		if isCMSError(err, client.ErrorKindAuth) {
+			// Creating posts would fail the same way, so give up before fetching any pages
+			result.Error = fmt.Errorf("failed to get existing posts: %w", err)
+			return result
+		}
+		if err != nil {
+			log.Printf("Warning: failed to get existing posts for feed %s: %v", feed.ID, err)
+			existingPosts = []models.InspirationFeedPost{} // Continue with empty list
+		}
+
+		for _, post := range existingPosts {
+			if post.GUID != nil {
+				existingGUIDs[*post.GUID] = true
+			}
 		}
 	}
 
-	// Create new posts, skipping duplicates
-	for _, post := range posts {
-		// Skip if duplicate
+	// Skip posts the CMS already has
+	var newPosts []*models.CreateInspirationFeedPostRequest
+	for _, post := range unseenPosts {
 		if post.GUID != nil && existingGUIDs[*post.GUID] {
+			s.markPostSeen(feed.ID, post)
 			result.PostsSkipped++
 			continue
 		}
+		newPosts = append(newPosts, post)
+	}
 
-		// Analyze content with GPT if enabled
-		if s.enableContentAnalysis && s.llmClient != nil {
-			log.Printf("🔍 Analyzing content for post: %s", post.Title)
-
-			analysisReq := &models.ContentAnalysisRequest{
-				Title:       post.Title,
-				Description: post.Description,
-				Content:     post.Content,
-				FullContent: post.FullContent,
-				URL:         post.URL,
-			}
-
-			analysis, err := s.llmClient.AnalyzeContent(analysisReq)
-			if err != nil {
-				log.Printf("Warning: content analysis failed for post '%s': %v", post.Title, err)
-				// Set defaults for failed analysis - assume referenced reporting to be conservative
-				falseVal := false
-				post.IsPrimaryReporting = &falseVal
-				post.OriginalSourceName = nil
-			} else {
-				// Apply analysis results
-				post.IsPrimaryReporting = &analysis.IsPrimaryReporting
-				post.OriginalSourceName = analysis.OriginalSourceName
-
-				log.Printf("✅ Content analysis for '%s': primary=%v, source=%v, confidence=%.2f, reasoning=%s",
-					post.Title, analysis.IsPrimaryReporting,
-					func() string {
-						if analysis.OriginalSourceName != nil {
-							return *analysis.OriginalSourceName
-						}
-						return "none"
-					}(), analysis.Confidence, analysis.Reasoning)
-
-				// Debug: Log what we're about to send to CMS
-				log.Printf("Post after analysis - Title: %s, IsPrimaryReporting: %v, OriginalSourceName: %v",
-					post.Title,
-					func() string {
-						if post.IsPrimaryReporting != nil {
-							return fmt.Sprintf("%v", *post.IsPrimaryReporting)
-						}
-						return "nil"
-					}(),
-					func() string {
-						if post.OriginalSourceName != nil {
-							return *post.OriginalSourceName
-						}
-						return "nil"
-					}())
-			}
-		} else {
-			log.Printf("❌ Content analysis disabled - enabled=%v, client_available=%v for post: %s",
-				s.enableContentAnalysis, s.llmClient != nil, post.Title)
-
-			// When LLM analysis is disabled, assume most articles are primary reporting unless proven otherwise
-			// This is more balanced than always assuming referenced reporting
-			trueVal := true
-			post.IsPrimaryReporting = &trueVal
-			post.OriginalSourceName = nil
-			log.Printf("🔧 LLM analysis disabled - defaulting to primary reporting (can be overridden if obvious references found)")
-		}
+	// Process the newest posts first and leave the rest for the next crawl once the cap is reached
+	sortNewestFirst(newPosts)
+	if s.maxPostsPerCrawl > 0 && len(newPosts) > s.maxPostsPerCrawl {
+		result.PostsDeferred = len(newPosts) - s.maxPostsPerCrawl
+		newPosts = newPosts[:s.maxPostsPerCrawl]
+		log.Printf("Feed %s has more new posts than the per-crawl cap of %d, deferring %d",
+			feed.Name, s.maxPostsPerCrawl, result.PostsDeferred)
+	}
 
-		// Create the post in CMS
-		_, err := s.cmsClient.CreateInspirationFeedPost(post)
-		if err != nil {
-			log.Printf("Failed to create post '%s' for feed %s: %v", post.Title, feed.Name, err)
+	// Extract, analyze and create the new posts, results come back in feed order
+	for _, job := range s.processPosts(ctx, feed, newPosts) {
+		if job.err != nil {
+			log.Printf("Failed to create post '%s' for feed %s: %v", job.post.Title, feed.Name, job.err)
 			result.PostsSkipped++
+			failed++
 			continue
 		}
 
 		result.PostsAdded++
-		log.Printf("Added post: %s", post.Title)
+		log.Printf("Added post: %s", job.post.Title)
+	}
+
+	if ctx.Err() != nil {
+		result.Error = fmt.Errorf("crawl interrupted after %d posts: %w", result.PostsAdded, ctx.Err())
+		log.Printf("Crawl of feed %s interrupted: %d added, remaining posts will be retried", feed.Name, result.PostsAdded)
+		return result
 	}
 
 	// Update the feed's last crawled timestamp
-	if err := s.cmsClient.UpdateFeedLastCrawledAt(feed.ID); err != nil {
+	if err := s.cmsClient.UpdateFeedLastCrawledAt(ctx, feed.ID); isCMSError(err, client.ErrorKindNotFound) {
+		log.Printf("Warning: feed %s was deleted from the CMS during the crawl", feed.ID)
+	} else if err != nil {
 		log.Printf("Warning: failed to update last crawled timestamp for feed %s: %v", feed.ID, err)
 	}
 
 	result.Success = true
-	log.Printf("Completed crawling feed %s: %d found, %d added, %d skipped",
-		feed.Name, result.PostsFound, result.PostsAdded, result.PostsSkipped)
+	log.Printf("Completed crawling feed %s: %d found, %d added, %d skipped, %d deferred",
+		feed.Name, result.PostsFound, result.PostsAdded, result.PostsSkipped, result.PostsDeferred)
 
 	return result
 }
 
+// commitValidators stores the cache validators of the fetched feed response after a complete crawl.
+// After a crawl that deferred posts, failed to create some or was interrupted they are dropped
+// instead, so the next request is unconditional and picks up the remaining posts rather than
+// getting a 304 until the feed changes.
+func (s *Service) commitValidators(feed *models.InspirationFeed, complete bool) {
+	fetchURL := s.fetchURL(feed)
+	v, _ := s.rssParser.GetValidators(fetchURL)
+	if !complete {
+		s.rssParser.SetValidators(fetchURL, parser.FeedValidators{})
+		v = parser.FeedValidators{}
+	}
+
+	if err := s.state.SetValidators(feed.ID, fetchURL, state.Validators{ETag: v.ETag, LastModified: v.LastModified}); err != nil {
+		log.Printf("Warning: failed to store validators for feed %s: %v", feed.ID, err)
+	}
+}
+
+// fetchFeedItems reads a feed's items according to its feed type. HTML listing pages and sitemaps
+// are normalised into the same shape as feeds so their links take the same extraction path.
+func (s *Service) fetchFeedItems(ctx context.Context, feed *models.InspirationFeed) (*models.RSSFeed, error) {
+	switch feed.FeedType {
+	case "", models.FeedTypeRSS:
+		rssFeed, err := s.rssParser.ParseFeed(ctx, feed.URL)
+		s.storeDiscoveredFeedURL(feed)
+		if err == nil || errors.Is(err, parser.ErrNotModified) {
+			s.reportDiscoveredFeedURL(ctx, feed)
+		}
+		return rssFeed, err
+
+	case models.FeedTypeHTML:
+		if feed.LinkSelector == nil || strings.TrimSpace(*feed.LinkSelector) == "" {
+			return nil, fmt.Errorf("HTML listing feed has no link selector")
+		}
+		return s.rssParser.ParseListingPage(ctx, feed.URL, *feed.LinkSelector)
+
+	case models.FeedTypeSitemap:
+		return s.rssParser.ParseSitemap(ctx, feed.URL)
+
+	default:
+		return nil, fmt.Errorf("unsupported feed type %q", feed.FeedType)
+	}
+}
+
+// sortNewestFirst orders posts by publish date, newest first. Posts without a date keep their
+// feed order after the dated ones.
+func sortNewestFirst(posts []*models.CreateInspirationFeedPostRequest) {
+	publishedAt := func(post *models.CreateInspirationFeedPostRequest) (time.Time, bool) {
+		if post.PublishedAt == nil {
+			return time.Time{}, false
+		}
+		t, err := time.Parse(time.RFC3339, *post.PublishedAt)
+		return t, err == nil
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		ti, okI := publishedAt(posts[i])
+		tj, okJ := publishedAt(posts[j])
+		if okI && okJ {
+			return ti.After(tj)
+		}
+		return okI && !okJ
+	})
+}
+
+// hasSeenPost checks the local state store for the post's GUID or URL
+func (s *Service) hasSeenPost(feedID string, post *models.CreateInspirationFeedPostRequest) bool {
+	if post.GUID != nil && s.state.HasSeen(feedID, *post.GUID) {
+		return true
+	}
+	return s.state.HasSeen(feedID, post.URL)
+}
+
+// markPostSeen records the post's GUID and URL in the local state store
+func (s *Service) markPostSeen(feedID string, post *models.CreateInspirationFeedPostRequest) {
+	keys := []string{post.URL}
+	if post.GUID != nil {
+		keys = append(keys, *post.GUID)
+	}
+	if err := s.state.MarkSeen(feedID, keys...); err != nil {
+		log.Printf("Warning: failed to store seen post for feed %s: %v", feedID, err)
+	}
+}
+
+// isCMSError reports whether err is a CMS API error of one of the given kinds, or of any kind if none are given
+func isCMSError(err error, kinds ...client.ErrorKind) bool {
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if len(kinds) == 0 {
+		return true
+	}
+	for _, kind := range kinds {
+		if apiErr.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// recordCrawlOutcome stores the crawl result in the local state store
+func (s *Service) recordCrawlOutcome(result *models.CrawlResult) {
+	outcome := state.CrawlOutcome{
+		At:           time.Now(),
+		Success:      result.Success,
+		NotModified:  result.NotModified,
+		PostsFound:   result.PostsFound,
+		PostsAdded:   result.PostsAdded,
+		PostsSkipped: result.PostsSkipped,
+	}
+	if result.Error != nil {
+		outcome.Error = result.Error.Error()
+	}
+	if err := s.state.RecordCrawl(result.FeedID, outcome); err != nil {
+		log.Printf("Warning: failed to record crawl outcome for feed %s: %v", result.FeedID, err)
+	}
+}
+
+// flushState writes the state changes and LLM usage of a crawl to disk in one go
+func (s *Service) flushState() {
+	if err := s.state.Flush(); err != nil {
+		log.Printf("Warning: failed to save crawl state: %v", err)
+	}
+	if s.usage != nil {
+		if err := s.usage.Flush(); err != nil {
+			log.Printf("Warning: failed to save LLM usage: %v", err)
+		}
+	}
+}
+
 // FeedCache caches feeds to avoid hitting the CMS API too frequently
 type FeedCache struct {
 	feeds      []models.InspirationFeed
@@ -314,7 +561,7 @@ func NewFeedCache(ttl time.Duration) *FeedCache {
 }
 
 // GetFeeds returns cached feeds or fetches them if cache is expired
-func (c *FeedCache) GetFeeds(cmsClient *client.CMSClient) ([]models.InspirationFeed, error) {
+func (c *FeedCache) GetFeeds(ctx context.Context, cmsClient *client.CMSClient) ([]models.InspirationFeed, error) {
 	c.mutex.RLock()
 	if time.Since(c.lastUpdate) < c.ttl && len(c.feeds) > 0 {
 		feeds := c.feeds
@@ -333,8 +580,14 @@ func (c *FeedCache) GetFeeds(cmsClient *client.CMSClient) ([]models.InspirationF
 	}
 
 	log.Println("Refreshing feeds cache...")
-	feeds, err := cmsClient.GetInspirationFeeds()
+	feeds, err := cmsClient.GetInspirationFeeds(ctx)
 	if err != nil {
+		// Keep crawling the known feeds while the CMS is temporarily unavailable
+		var apiErr *client.APIError
+		if len(c.feeds) > 0 && errors.As(err, &apiErr) && apiErr.Temporary() {
+			log.Printf("Warning: failed to refresh feeds cache, using %d cached feeds: %v", len(c.feeds), err)
+			return c.feeds, nil
+		}
 		return nil, err
 	}
 
@@ -345,11 +598,15 @@ func (c *FeedCache) GetFeeds(cmsClient *client.CMSClient) ([]models.InspirationF
 	return feeds, nil
 }
 
-// ProcessQueueRequests polls the CMS queue and processes any pending requests
-func (s *Service) ProcessQueueRequests() error {
+// ProcessQueueRequests polls the CMS queue and processes any pending requests.
+// A request interrupted by a cancelled context is left unacknowledged so the CMS hands it out again.
+func (s *Service) ProcessQueueRequests(ctx context.Context) error {
 	log.Println("Checking for queue requests...")
 
-	request, err := s.cmsClient.PollCrawlRequest()
+	request, err := s.cmsClient.PollCrawlRequest(ctx)
+	if isCMSError(err, client.ErrorKindAuth) {
+		return fmt.Errorf("failed to poll for requests, check the tenant's access token: %w", err)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to poll for requests: %w", err)
 	}
@@ -369,7 +626,7 @@ func (s *Service) ProcessQueueRequests() error {
 			log.Printf("Invalid single crawl request: missing feed ID")
 			break
 		}
-		result, err := s.CrawlFeed(*request.FeedID)
+		result, err := s.CrawlFeed(ctx, *request.FeedID)
 		if err != nil {
 			log.Printf("Failed to crawl feed %s: %v", *request.FeedID, err)
 		} else {
@@ -377,7 +634,7 @@ func (s *Service) ProcessQueueRequests() error {
 		}
 
 	case "all":
-		allResults, err := s.CrawlAllDueFeeds()
+		allResults, err := s.CrawlAllDueFeeds(ctx)
 		if err != nil {
 			log.Printf("Failed to crawl all due feeds: %v", err)
 		} else {
@@ -388,8 +645,13 @@ func (s *Service) ProcessQueueRequests() error {
 		log.Printf("Unknown request type: %s", request.Type)
 	}
 
+	if ctx.Err() != nil {
+		log.Printf("Queue request %s interrupted, leaving it unacknowledged for retry", request.ID)
+		return ctx.Err()
+	}
+
 	// Acknowledge the request (whether successful or not)
-	if err := s.cmsClient.AcknowledgeRequest(request.ID); err != nil {
+	if err := s.cmsClient.AcknowledgeRequest(ctx, request.ID); err != nil {
 		log.Printf("Warning: failed to acknowledge request %s: %v", request.ID, err)
 	}
 
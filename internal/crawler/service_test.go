@@ -0,0 +1,222 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"strandnerd-crawler/internal/client"
+	"strandnerd-crawler/internal/config"
+	"strandnerd-crawler/internal/models"
+	"strandnerd-crawler/internal/parser"
+	"strandnerd-crawler/internal/state"
+)
+
+func TestSortNewestFirst(t *testing.T) {
+	post := func(title, publishedAt string) *models.CreateInspirationFeedPostRequest {
+		p := &models.CreateInspirationFeedPostRequest{Title: title}
+		if publishedAt != "" {
+			p.PublishedAt = &publishedAt
+		}
+		return p
+	}
+
+	posts := []*models.CreateInspirationFeedPostRequest{
+		post("undated-1", ""),
+		post("old", "2024-03-01T10:00:00Z"),
+		post("newest", "2024-03-05T10:00:00Z"),
+		post("undated-2", ""),
+		post("middle", "2024-03-03T10:00:00+02:00"),
+	}
+
+	sortNewestFirst(posts)
+
+	expected := []string{"newest", "middle", "old", "undated-1", "undated-2"}
+	for i, title := range expected {
+		if posts[i].Title != title {
+			t.Errorf("Position %d: expected %s, got %s", i, title, posts[i].Title)
+		}
+	}
+}
+
+// testFeedServer serves an RSS feed with an ETag, answering matching conditional requests with 304,
+// and a web page linking to it. It records the If-None-Match header of every feed request.
+type testFeedServer struct {
+	*httptest.Server
+	mutex        sync.Mutex
+	conditional  []string
+	pageRequests int
+}
+
+func newTestFeedServer(t *testing.T) *testFeedServer {
+	fs := &testFeedServer{}
+	fs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" {
+			fs.mutex.Lock()
+			fs.pageRequests++
+			fs.mutex.Unlock()
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><link rel="alternate" type="application/rss+xml" href="/feed"></head><body></body></html>`))
+			return
+		}
+		if r.URL.Path != "/feed" {
+			w.Write([]byte("<html><body><article><p>Article text.</p></article></body></html>"))
+			return
+		}
+
+		fs.mutex.Lock()
+		fs.conditional = append(fs.conditional, r.Header.Get("If-None-Match"))
+		fs.mutex.Unlock()
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Test</title>
+<item><title>First</title><link>%[1]s/first</link><guid>first</guid><pubDate>Mon, 04 Mar 2024 10:00:00 GMT</pubDate></item>
+<item><title>Second</title><link>%[1]s/second</link><guid>second</guid><pubDate>Tue, 05 Mar 2024 10:00:00 GMT</pubDate></item>
+</channel></rss>`, fs.URL)
+	}))
+	t.Cleanup(fs.Close)
+	return fs
+}
+
+// lastConditional returns the If-None-Match header of the latest feed request
+func (fs *testFeedServer) lastConditional() string {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return fs.conditional[len(fs.conditional)-1]
+}
+
+// newTestService creates a service crawling through a plain HTTP client against a CMS that
+// accepts every post. onCreate is called with each created post when set.
+func newTestService(t *testing.T, onCreate func(post models.CreateInspirationFeedPostRequest)) *Service {
+	return newTestServiceWithState(t, onCreate, state.NewMemoryStore())
+}
+
+// newTestServiceWithState is newTestService with the given state store, e.g. to simulate a restart
+func newTestServiceWithState(t *testing.T, onCreate func(post models.CreateInspirationFeedPostRequest), stateStore state.Store) *Service {
+	cms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/extraction_rules"):
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/inspiration_feed_posts"):
+			w.Write([]byte("[]"))
+		case r.Method == http.MethodPost:
+			if onCreate != nil {
+				var post models.CreateInspirationFeedPostRequest
+				if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
+					t.Errorf("Failed to decode created post: %v", err)
+				}
+				onCreate(post)
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("{}"))
+		default:
+			w.Write([]byte("{}"))
+		}
+	}))
+	t.Cleanup(cms.Close)
+
+	cfg := &config.Config{UserAgent: "test"}
+	rssParser := parser.NewRSSParser(http.DefaultClient, cfg)
+	return &Service{
+		cmsClient:        client.NewCMSClient(cms.URL, "token"),
+		rssParser:        rssParser,
+		cache:            NewFeedCache(time.Minute),
+		state:            stateStore,
+		extractContent:   pageExtractor(rssParser),
+		crawlSlots:       make(chan struct{}, 1),
+		reportedFeedURLs: make(map[string]string),
+	}
+}
+
+func TestDeferredPostsAreFetchedUnconditionally(t *testing.T) {
+	feedServer := newTestFeedServer(t)
+	service := newTestService(t, nil)
+	service.maxPostsPerCrawl = 1
+	feed := &models.InspirationFeed{ID: "feed-1", Name: "Test", URL: feedServer.URL + "/feed"}
+
+	result := service.crawlSingleFeed(context.Background(), feed)
+	if !result.Success || result.PostsAdded != 1 || result.PostsDeferred != 1 {
+		t.Fatalf("Expected one post added and one deferred, got %+v", result)
+	}
+
+	// The deferred post is only in the unchanged feed, so it must not be hidden behind a 304
+	result = service.crawlSingleFeed(context.Background(), feed)
+	if header := feedServer.lastConditional(); header != "" {
+		t.Errorf("Expected an unconditional request after deferring posts, got If-None-Match %s", header)
+	}
+	if !result.Success || result.PostsAdded != 1 || result.PostsDeferred != 0 {
+		t.Fatalf("Expected the deferred post to be added, got %+v", result)
+	}
+
+	// Once every post was handled the validators are used again
+	result = service.crawlSingleFeed(context.Background(), feed)
+	if header := feedServer.lastConditional(); header != `"v1"` || !result.NotModified {
+		t.Errorf("Expected a conditional request answered with 304, got If-None-Match %q and %+v", header, result)
+	}
+}
+
+func TestInterruptedCrawlIsFetchedUnconditionally(t *testing.T) {
+	feedServer := newTestFeedServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Shutdown arrives while the first post is being created
+	service := newTestService(t, func(models.CreateInspirationFeedPostRequest) { cancel() })
+	feed := &models.InspirationFeed{ID: "feed-1", Name: "Test", URL: feedServer.URL + "/feed"}
+
+	result := service.crawlSingleFeed(ctx, feed)
+	if result.Success || result.Error == nil {
+		t.Fatalf("Expected the crawl to be interrupted, got %+v", result)
+	}
+
+	result = service.crawlSingleFeed(context.Background(), feed)
+	if header := feedServer.lastConditional(); header != "" {
+		t.Errorf("Expected an unconditional request after an interrupted crawl, got If-None-Match %s", header)
+	}
+	if !result.Success || result.PostsFound != 2 || result.PostsAdded == 0 {
+		t.Errorf("Expected the remaining posts to be retried, got %+v", result)
+	}
+}
+
+func TestDiscoveredFeedSurvivesRestart(t *testing.T) {
+	feedServer := newTestFeedServer(t)
+	stateStore := state.NewMemoryStore()
+	feed := &models.InspirationFeed{ID: "feed-1", Name: "Test", URL: feedServer.URL + "/page"}
+
+	result := newTestServiceWithState(t, nil, stateStore).crawlSingleFeed(context.Background(), feed)
+	if !result.Success || result.PostsAdded != 2 {
+		t.Fatalf("Expected the discovered feed to be crawled, got %+v", result)
+	}
+
+	// A restarted service knows the discovered feed and its validators
+	result = newTestServiceWithState(t, nil, stateStore).crawlSingleFeed(context.Background(), feed)
+	if feedServer.pageRequests != 1 {
+		t.Errorf("Expected the web page to be fetched once, got %d requests", feedServer.pageRequests)
+	}
+	if header := feedServer.lastConditional(); header != `"v1"` || !result.NotModified {
+		t.Errorf("Expected a conditional request for the discovered feed, got If-None-Match %q and %+v", header, result)
+	}
+}
+
+func TestCrawlFeedWaitsForACrawlSlot(t *testing.T) {
+	service := newTestService(t, nil)
+	service.crawlSlots <- struct{}{} // a scheduled crawl holds the only slot
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := service.CrawlFeed(ctx, "feed-1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the manual crawl to wait for the busy slot, got %v", err)
+	}
+}
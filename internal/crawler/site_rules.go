@@ -0,0 +1,79 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"strandnerd-crawler/internal/client"
+	"strandnerd-crawler/internal/parser"
+)
+
+// recentPostsPerFeed is how many of each feed's latest posts are offered as test pages to site
+// rules without test URLs
+const recentPostsPerFeed = 5
+
+// refreshSiteRules fetches the tenant's site extraction rules from the CMS once they are older
+// than the feed cache. A CMS without extraction rules answers 404, which drops any rules fetched
+// earlier and leaves the built-in and rules directory rules in effect; other failures keep the
+// previously fetched rules.
+func (s *Service) refreshSiteRules(ctx context.Context) {
+	s.siteRulesMutex.Lock()
+	defer s.siteRulesMutex.Unlock()
+
+	if !s.siteRulesFetchedAt.IsZero() && time.Since(s.siteRulesFetchedAt) < s.cache.ttl {
+		return
+	}
+
+	rules, err := s.cmsClient.GetSiteRules(ctx)
+	if isCMSError(err, client.ErrorKindNotFound) {
+		s.siteRulesFetchedAt = time.Now()
+		s.rssParser.GetContentExtractor().SiteRules().SetTenantRules(nil)
+		return
+	}
+	if err != nil {
+		log.Printf("Warning: failed to fetch site extraction rules, keeping the loaded rules: %v", err)
+		return
+	}
+
+	s.siteRulesFetchedAt = time.Now()
+	s.rssParser.GetContentExtractor().SiteRules().SetTenantRules(rules)
+	if len(rules) > 0 {
+		log.Printf("📐 Loaded %d site rules from the CMS", len(rules))
+	}
+}
+
+// ValidateSiteRules checks the built-in, rules directory and CMS site rules in effect for the
+// tenant against their test pages. Rules without test pages are checked against the latest posts
+// of the tenant's feeds on their domains.
+func (s *Service) ValidateSiteRules(ctx context.Context) []parser.SiteRuleReport {
+	s.refreshSiteRules(ctx)
+	return s.rssParser.GetContentExtractor().ValidateSiteRules(ctx, s.recentPostURLs(ctx))
+}
+
+// recentPostURLs returns the URLs of the latest posts created from each active feed
+func (s *Service) recentPostURLs(ctx context.Context) []string {
+	feeds, err := s.Feeds(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to get feeds, site rules without test URLs are only checked for syntax: %v", err)
+		return nil
+	}
+
+	var urls []string
+	for _, feed := range feeds {
+		if !feed.IsActive {
+			continue
+		}
+		posts, err := s.cmsClient.GetInspirationPosts(ctx, feed.ID, recentPostsPerFeed)
+		if err != nil {
+			log.Printf("Warning: failed to get recent posts for feed %s: %v", feed.ID, err)
+			continue
+		}
+		for _, post := range posts {
+			if post.URL != "" {
+				urls = append(urls, post.URL)
+			}
+		}
+	}
+	return urls
+}
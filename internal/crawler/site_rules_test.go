@@ -0,0 +1,46 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"strandnerd-crawler/internal/client"
+	"strandnerd-crawler/internal/config"
+	"strandnerd-crawler/internal/parser"
+)
+
+func TestSiteRulesRemovedFromCMSAreDropped(t *testing.T) {
+	var removed atomic.Bool
+	cms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if removed.Load() {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`[{"name": "cms rule", "domains": ["cms.test"], "content": [".story"]}]`))
+	}))
+	defer cms.Close()
+
+	service := &Service{
+		cmsClient: client.NewCMSClient(cms.URL, "token"),
+		rssParser: parser.NewRSSParser(http.DefaultClient, &config.Config{UserAgent: "test"}),
+		cache:     NewFeedCache(time.Minute),
+	}
+	siteRules := service.rssParser.GetContentExtractor().SiteRules()
+
+	service.refreshSiteRules(context.Background())
+	if rule := siteRules.Lookup("https://cms.test/a"); rule == nil || rule.Name != "cms rule" {
+		t.Fatalf("Expected the CMS rule to be loaded, got %+v", rule)
+	}
+
+	// The CMS no longer has extraction rules once the cached ones expire
+	removed.Store(true)
+	service.siteRulesFetchedAt = time.Time{}
+	service.refreshSiteRules(context.Background())
+	if rule := siteRules.Lookup("https://cms.test/a"); rule != nil {
+		t.Errorf("Expected the CMS rule to be dropped after a 404, got %+v", rule)
+	}
+}
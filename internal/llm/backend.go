@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Providers a content analysis backend can be selected by
+const (
+	ProviderOpenAI    = "openai"    // OpenAI or any OpenAI-compatible API, such as a local llama.cpp or Ollama server
+	ProviderAnthropic = "anthropic" // Anthropic Messages API
+	ProviderFake      = "fake"      // deterministic answers without any API calls, for tests and local development
+)
+
+// Config selects and tunes the language model used for content analysis
+type Config struct {
+	Provider    string  // see Provider constants, defaults to ProviderOpenAI
+	BaseURL     string  // API base URL, defaults to the provider's public API
+	APIKey      string  // optional for OpenAI-compatible servers with a custom base URL
+	Model       string  // defaults to the provider's default model
+	Temperature float64 // sampling temperature
+	MaxTokens   int     // upper bound for the response length
+	// DisableStructuredOutput asks for plain JSON instead of schema-constrained output, for
+	// OpenAI-compatible servers that reject response formats
+	DisableStructuredOutput bool
+	// Cache shares analysis results between clients, nil disables caching
+	Cache *AnalysisCache
+
+	RequestsPerMinute int // request budget of the API account, 0 is unlimited
+	TokensPerMinute   int // token budget of the API account, 0 is unlimited
+	MaxRetries        int // retries of rate limited or temporarily failed requests
+
+	// Usage records the tenant's calls and their cost, nil disables accounting
+	Usage *UsageLedger
+	// Prices extends and overrides the built-in USD prices per million tokens by model name prefix
+	Prices map[string]ModelPrice
+	// MonthlyBudget caps the tenant's spending in USD per month. Once reached, only the free
+	// rule-based analysis and cached results are used until the month ends. 0 is unlimited.
+	MonthlyBudget float64
+}
+
+// Backend sends a prompt to a language model and returns its answer
+type Backend interface {
+	Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error)
+}
+
+// CompletionRequest is a provider-agnostic prompt. Messages alternate between user and
+// assistant turns and end with a user turn.
+type CompletionRequest struct {
+	Model       string
+	System      string
+	Messages    []Message
+	Schema      *ResponseSchema // constrains the answer to JSON matching the schema when set
+	Temperature float64
+	MaxTokens   int
+}
+
+// Message is one turn of a conversation with a language model
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// CompletionResponse is a language model's answer to a CompletionRequest
+type CompletionResponse struct {
+	Content      string
+	FinishReason string
+	Usage        Usage
+	RateLimits   *RateLimitInfo // nil when the API sent no rate limit headers
+}
+
+// Usage counts the tokens of a completion
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// defaultModels are used when a tenant does not configure a model
+var defaultModels = map[string]string{
+	ProviderOpenAI:    "gpt-4o-mini",
+	ProviderAnthropic: "claude-3-5-haiku-latest",
+	ProviderFake:      "fake",
+}
+
+// newBackend creates the backend for a configuration with the provider defaults filled in
+func newBackend(cfg *Config) (Backend, error) {
+	httpClient := &http.Client{
+		Timeout: 5 * time.Minute, // Set timeout to 5 minutes
+	}
+
+	switch cfg.Provider {
+	case ProviderOpenAI:
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = "https://api.openai.com/v1"
+			if cfg.APIKey == "" {
+				return nil, fmt.Errorf("an API key is required for the OpenAI API")
+			}
+		}
+		return &openAIBackend{baseURL: cfg.BaseURL, apiKey: cfg.APIKey, httpClient: httpClient}, nil
+
+	case ProviderAnthropic:
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = "https://api.anthropic.com/v1"
+		}
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("an API key is required for the Anthropic API")
+		}
+		return &anthropicBackend{baseURL: cfg.BaseURL, apiKey: cfg.APIKey, httpClient: httpClient}, nil
+
+	case ProviderFake:
+		return &FakeBackend{}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.Provider)
+	}
+}
+
+// statusError describes a failed API response, including the start of its error message and the
+// rate limit headers
+func statusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+		RateLimits: parseRateLimitHeaders(resp.Header),
+	}
+}
@@ -0,0 +1,267 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"strandnerd-crawler/internal/models"
+)
+
+// analysisPromptVersion is part of every cache key. Bump it whenever the prompt, the schema or
+// the response parsing changes so answers to the old prompt are no longer reused.
+const analysisPromptVersion = "2"
+
+// analysisCacheSweepInterval is how often expired entries are dropped from memory and disk
+const analysisCacheSweepInterval = time.Hour
+
+// AnalysisCache stores content analysis results by a hash of the analysed article, so a story
+// syndicated across many feeds and tenants is only sent to the model once. It is safe for
+// concurrent use and meant to be shared by all tenants of a process. Entries are written to one
+// file each below dir, an empty dir keeps them in memory only. Concurrent analyses of the same
+// story wait for the first one instead of calling the model again.
+type AnalysisCache struct {
+	dir      string
+	ttl      time.Duration
+	entries  map[string]*cachedAnalysis
+	inFlight map[string]chan struct{} // key -> closed when its running analysis finishes
+	sweptAt  time.Time
+	mutex    sync.Mutex
+}
+
+// cachedAnalysis is a cached result together with what produced it
+type cachedAnalysis struct {
+	Provider string                         `json:"provider"`
+	Model    string                         `json:"model"`
+	CachedAt time.Time                      `json:"cached_at"`
+	Result   models.ContentAnalysisResponse `json:"result"`
+}
+
+// NewAnalysisCache opens the cache in dir and removes entries older than ttl
+func NewAnalysisCache(dir string, ttl time.Duration) (*AnalysisCache, error) {
+	c := &AnalysisCache{
+		dir:      dir,
+		ttl:      ttl,
+		entries:  make(map[string]*cachedAnalysis),
+		inFlight: make(map[string]chan struct{}),
+		sweptAt:  time.Now(),
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create analysis cache directory: %w", err)
+		}
+		if removed, err := c.prune(time.Now()); err != nil {
+			return nil, fmt.Errorf("failed to prune analysis cache: %w", err)
+		} else if removed > 0 {
+			log.Printf("🧹 Removed %d expired analysis cache entries from %s", removed, dir)
+		}
+	}
+
+	return c, nil
+}
+
+// Get returns the cached result for a key if it has not expired. Expired entry files are left
+// for the periodic prune.
+func (c *AnalysisCache) Get(key string) (*models.ContentAnalysisResponse, bool) {
+	c.mutex.Lock()
+	entry, ok := c.entries[key]
+	c.mutex.Unlock()
+
+	// Read the entry file without the lock so lookups of other keys do not wait for the disk
+	if !ok && c.dir != "" {
+		entry, ok = c.load(key)
+	}
+	if !ok {
+		return nil, false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if time.Since(entry.CachedAt) > c.ttl {
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+		return nil, false
+	}
+	if _, cached := c.entries[key]; !cached {
+		c.entries[key] = entry
+	}
+
+	result := entry.Result
+	return &result, true
+}
+
+// Put stores the result for a key
+func (c *AnalysisCache) Put(key, provider, model string, result *models.ContentAnalysisResponse) error {
+	entry := &cachedAnalysis{
+		Provider: provider,
+		Model:    model,
+		CachedAt: time.Now(),
+		Result:   *result,
+	}
+
+	c.mutex.Lock()
+	c.entries[key] = entry
+	swept := c.sweep(entry.CachedAt)
+	c.mutex.Unlock()
+
+	if c.dir == "" {
+		return nil
+	}
+	if swept {
+		if removed, err := c.prune(entry.CachedAt); err != nil {
+			log.Printf("⚠️ Failed to prune analysis cache: %v", err)
+		} else if removed > 0 {
+			log.Printf("🧹 Removed %d expired analysis cache entries from %s", removed, c.dir)
+		}
+	}
+	return c.save(key, entry)
+}
+
+// start claims the analysis of a key. It returns nil when the caller claimed it and must call
+// finish once the result is cached or given up on, otherwise a channel that is closed when the
+// analysis already running for the key finishes.
+func (c *AnalysisCache) start(key string) <-chan struct{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if done, running := c.inFlight[key]; running {
+		return done
+	}
+	c.inFlight[key] = make(chan struct{})
+	return nil
+}
+
+// finish releases a key claimed by start and wakes the callers waiting for it
+func (c *AnalysisCache) finish(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	close(c.inFlight[key])
+	delete(c.inFlight, key)
+}
+
+// load reads a key's entry file
+func (c *AnalysisCache) load(key string) (*cachedAnalysis, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cachedAnalysis
+	if err := json.Unmarshal(data, &entry); err != nil {
+		log.Printf("⚠️ Ignoring unreadable analysis cache entry %s: %v", key, err)
+		return nil, false
+	}
+	return &entry, true
+}
+
+// save writes a key's entry file atomically
+func (c *AnalysisCache) save(key string, entry *cachedAnalysis) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal analysis cache entry: %w", err)
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create analysis cache directory: %w", err)
+	}
+
+	// Write to a temporary file and rename so readers never see a partial entry
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary analysis cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write analysis cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write analysis cache entry: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace analysis cache entry: %w", err)
+	}
+	return nil
+}
+
+// sweep drops expired entries from memory at most once per sweep interval and reports whether
+// it ran, so the caller prunes the entry files too. Caller must hold the lock.
+func (c *AnalysisCache) sweep(now time.Time) bool {
+	if now.Sub(c.sweptAt) < analysisCacheSweepInterval {
+		return false
+	}
+	c.sweptAt = now
+
+	for key, entry := range c.entries {
+		if now.Sub(entry.CachedAt) > c.ttl {
+			delete(c.entries, key)
+		}
+	}
+	return true
+}
+
+// prune removes entry files written more than ttl ago
+func (c *AnalysisCache) prune(now time.Time) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if now.Sub(info.ModTime()) > c.ttl {
+			if err := os.Remove(path); err == nil {
+				removed++
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// path returns the entry file of a key, spread over subdirectories by the key's first byte
+func (c *AnalysisCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// analysisCacheKey hashes the normalised title and body together with the prompt version,
+// provider and model, so only answers to the same question from the same model are reused
+func analysisCacheKey(provider, model, title, body string) string {
+	hash := sha256.New()
+	for _, part := range []string{analysisPromptVersion, provider, model, normalizeForHash(title), normalizeForHash(body)} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// normalizeForHash reduces text to its lower-cased words so copies of a story that differ only
+// in case, punctuation, whitespace or markup hash the same
+func normalizeForHash(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(words, " ")
+}
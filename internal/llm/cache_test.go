@@ -0,0 +1,189 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"strandnerd-crawler/internal/models"
+)
+
+func TestAnalysisCachePersists(t *testing.T) {
+	dir := t.TempDir()
+	source := "Reuters"
+	result := &models.ContentAnalysisResponse{OriginalSourceName: &source, Confidence: 0.8, Reasoning: "Cites Reuters"}
+
+	cache, err := NewAnalysisCache(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("NewAnalysisCache failed: %v", err)
+	}
+	key := analysisCacheKey(ProviderOpenAI, "gpt-4o-mini", "Title", "Body")
+	if err := cache.Put(key, ProviderOpenAI, "gpt-4o-mini", result); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	reopened, err := NewAnalysisCache(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("NewAnalysisCache failed: %v", err)
+	}
+	cached, ok := reopened.Get(key)
+	if !ok || cached.OriginalSourceName == nil || *cached.OriginalSourceName != "Reuters" || cached.Confidence != 0.8 {
+		t.Fatalf("Expected the cached result after reopening, got %+v, %v", cached, ok)
+	}
+
+	// Entries older than the TTL are pruned when the cache is opened
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(reopened.path(key), old, old); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	pruned, err := NewAnalysisCache(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("NewAnalysisCache failed: %v", err)
+	}
+	if _, ok := pruned.Get(key); ok {
+		t.Error("Expected an expired entry to be pruned")
+	}
+	if _, err := os.Stat(reopened.path(key)); !os.IsNotExist(err) {
+		t.Errorf("Expected the expired entry file to be removed, got %v", err)
+	}
+}
+
+func TestAnalysisCacheExpires(t *testing.T) {
+	cache, err := NewAnalysisCache("", time.Hour)
+	if err != nil {
+		t.Fatalf("NewAnalysisCache failed: %v", err)
+	}
+
+	cache.Put("a1", ProviderFake, "fake", &models.ContentAnalysisResponse{IsPrimaryReporting: true})
+	cache.entries["a1"].CachedAt = time.Now().Add(-2 * time.Hour)
+
+	if _, ok := cache.Get("a1"); ok {
+		t.Error("Expected an expired entry to be ignored")
+	}
+}
+
+func TestAnalysisCacheKey(t *testing.T) {
+	key := analysisCacheKey(ProviderOpenAI, "gpt-4o-mini", "Council approves budget", "The council approved the budget on Tuesday.")
+
+	if syndicated := analysisCacheKey(ProviderOpenAI, "gpt-4o-mini", "  COUNCIL approves budget!", "The council  approved the budget\non Tuesday"); syndicated != key {
+		t.Error("Expected copies differing in case, punctuation and whitespace to share a key")
+	}
+	if other := analysisCacheKey(ProviderOpenAI, "gpt-4o", "Council approves budget", "The council approved the budget on Tuesday."); other == key {
+		t.Error("Expected a different model to change the key")
+	}
+	if other := analysisCacheKey(ProviderOpenAI, "gpt-4o-mini", "Council approves budget", "The council rejected the budget on Tuesday."); other == key {
+		t.Error("Expected a different body to change the key")
+	}
+}
+
+func TestClientsShareAnalysisCache(t *testing.T) {
+	cache, err := NewAnalysisCache(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("NewAnalysisCache failed: %v", err)
+	}
+
+	first := &FakeBackend{}
+	second := &FakeBackend{}
+	NewClientWithBackend(first, Config{Provider: ProviderFake, Cache: cache}).AnalyzeContent(context.Background(), testAnalysisRequest())
+
+	// Another tenant's client sees the same story with different markup
+	syndicated := testAnalysisRequest()
+	html := "<p>" + *syndicated.Content + "</p>"
+	syndicated.Content = &html
+	result, err := NewClientWithBackend(second, Config{Provider: ProviderFake, Cache: cache}).AnalyzeContent(context.Background(), syndicated)
+	if err != nil {
+		t.Fatalf("AnalyzeContent failed: %v", err)
+	}
+
+	if len(first.Requests()) != 1 || len(second.Requests()) != 0 {
+		t.Errorf("Expected the second client to use the cache, got %d and %d requests", len(first.Requests()), len(second.Requests()))
+	}
+	if !result.IsPrimaryReporting || result.Confidence != 0.5 {
+		t.Errorf("Unexpected cached result: %+v", result)
+	}
+}
+
+func TestFallbackResultsAreNotCached(t *testing.T) {
+	cache, err := NewAnalysisCache("", time.Hour)
+	if err != nil {
+		t.Fatalf("NewAnalysisCache failed: %v", err)
+	}
+
+	failing := &FakeBackend{Respond: func(req *CompletionRequest) (string, error) {
+		return "", errors.New("unavailable")
+	}}
+	NewClientWithBackend(failing, Config{Provider: ProviderFake, Cache: cache}).AnalyzeContent(context.Background(), testAnalysisRequest())
+
+	working := &FakeBackend{}
+	NewClientWithBackend(working, Config{Provider: ProviderFake, Cache: cache}).AnalyzeContent(context.Background(), testAnalysisRequest())
+
+	if len(working.Requests()) != 1 {
+		t.Errorf("Expected a failed analysis to be retried instead of cached, got %d requests", len(working.Requests()))
+	}
+}
+
+func TestConcurrentAnalysesOfAStoryShareOneCall(t *testing.T) {
+	cache, err := NewAnalysisCache("", time.Hour)
+	if err != nil {
+		t.Fatalf("NewAnalysisCache failed: %v", err)
+	}
+
+	called := make(chan struct{}, 3)
+	release := make(chan struct{})
+	backend := &FakeBackend{Respond: func(req *CompletionRequest) (string, error) {
+		called <- struct{}{}
+		<-release
+		return fakeAnswer, nil
+	}}
+
+	// The same story arrives through three feeds at once
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client := NewClientWithBackend(backend, Config{Provider: ProviderFake, Cache: cache})
+			if _, err := client.AnalyzeContent(context.Background(), testAnalysisRequest()); err != nil {
+				t.Errorf("AnalyzeContent failed: %v", err)
+			}
+		}()
+	}
+
+	<-called
+	time.Sleep(50 * time.Millisecond) // let the other analyses reach the cache
+	close(release)
+	wg.Wait()
+
+	if requests := len(backend.Requests()); requests != 1 {
+		t.Errorf("Expected one model call for concurrent copies of a story, got %d", requests)
+	}
+}
+
+func TestAnalysisCachePrunesFilesPeriodically(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewAnalysisCache(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("NewAnalysisCache failed: %v", err)
+	}
+
+	expired := analysisCacheKey(ProviderFake, "fake", "Old story", "Old body")
+	if err := cache.Put(expired, ProviderFake, "fake", &models.ContentAnalysisResponse{}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(cache.path(expired), old, old); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+
+	// The next write after the sweep interval removes expired files of a long-running process
+	cache.sweptAt = time.Now().Add(-2 * analysisCacheSweepInterval)
+	if err := cache.Put(analysisCacheKey(ProviderFake, "fake", "New story", "New body"), ProviderFake, "fake", &models.ContentAnalysisResponse{}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, err := os.Stat(cache.path(expired)); !os.IsNotExist(err) {
+		t.Errorf("Expected the expired entry file to be pruned, got %v", err)
+	}
+}
@@ -1,102 +1,114 @@
 package llm
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"regexp"
 	"strings"
-	"sync"
 	"time"
 
 	"strandnerd-crawler/internal/models"
 	"golang.org/x/net/html"
 )
 
-// Client handles communication with OpenAI GPT API
+// Analyzer decides whether an article is primary reporting and which source it references
+type Analyzer interface {
+	AnalyzeContent(ctx context.Context, req *models.ContentAnalysisRequest) (*models.ContentAnalysisResponse, error)
+}
+
+// Client analyzes content with a language model behind a pluggable Backend
 type Client struct {
-	apiKey     string
-	baseURL    string
-	httpClient *http.Client
+	backend     Backend
+	provider    string
+	model       string
+	temperature float64
+	maxTokens   int
+	structured  bool
+	cache       *AnalysisCache
+	maxRetries  int
 	rateLimiter *RateLimiter
-}
 
-// RateLimiter implements a simple token bucket rate limiter
-type RateLimiter struct {
-	mu           sync.Mutex
-	lastRequest  time.Time
-	minInterval  time.Duration
+	usage         *UsageLedger
+	price         ModelPrice
+	monthlyBudget float64
 }
 
-// NewRateLimiter creates a new rate limiter with minimum interval between requests
-func NewRateLimiter(minInterval time.Duration) *RateLimiter {
-	return &RateLimiter{
-		minInterval: minInterval,
-	}
-}
+// maxCorrections is how often an invalid answer is sent back to the model for correction
+// before falling back to the default result
+const maxCorrections = 1
 
-// Wait blocks until it's safe to make another request
-func (rl *RateLimiter) Wait() {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+// NewClient creates a content analysis client for the configured provider, rate limited together
+// with all other clients of the same API account
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.Provider == "" {
+		cfg.Provider = ProviderOpenAI
+	}
 
-	now := time.Now()
-	timeSinceLastRequest := now.Sub(rl.lastRequest)
-	
-	if timeSinceLastRequest < rl.minInterval {
-		sleepDuration := rl.minInterval - timeSinceLastRequest
-		log.Printf("🕐 Rate limiting: waiting %v before next LLM request", sleepDuration)
-		time.Sleep(sleepDuration)
+	backend, err := newBackend(&cfg)
+	if err != nil {
+		return nil, err
 	}
-	
-	rl.lastRequest = time.Now()
-}
 
-// NewClient creates a new LLM client with rate limiting and 5-minute timeout
-func NewClient(apiKey string) *Client {
-	return &Client{
-		apiKey:  apiKey,
-		baseURL: "https://api.openai.com/v1",
-		httpClient: &http.Client{
-			Timeout: 5 * time.Minute, // Set timeout to 5 minutes
-		},
-		rateLimiter: NewRateLimiter(1 * time.Second), // Minimum 1 second between requests
+	client := NewClientWithBackend(backend, cfg)
+	if cfg.Provider != ProviderFake {
+		client.rateLimiter = sharedRateLimiter(&cfg)
 	}
+	return client, nil
 }
 
-// OpenAI API structures
-type ChatCompletionRequest struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	Temperature float64   `json:"temperature"`
-	MaxTokens   int       `json:"max_tokens"`
-}
+// NewClientWithBackend creates a content analysis client for a backend without rate limiting,
+// e.g. for a FakeBackend in tests
+func NewClientWithBackend(backend Backend, cfg Config) *Client {
+	model := cfg.Model
+	if model == "" {
+		model = defaultModels[cfg.Provider]
+	}
 
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
+	prices := make(map[string]ModelPrice, len(defaultPrices)+len(cfg.Prices))
+	for name, price := range defaultPrices {
+		prices[name] = price
+	}
+	for name, price := range cfg.Prices {
+		prices[name] = price
+	}
+	price, priced := priceFor(prices, model)
+	if !priced && cfg.Usage != nil {
+		log.Printf("⚠️ No price configured for model %s, its usage is recorded without cost", model)
+	}
 
-type ChatCompletionResponse struct {
-	Choices []Choice `json:"choices"`
-	Usage   Usage    `json:"usage"`
+	return &Client{
+		backend:     backend,
+		provider:    cfg.Provider,
+		model:       model,
+		temperature: cfg.Temperature,
+		maxTokens:   cfg.MaxTokens,
+		structured:  !cfg.DisableStructuredOutput,
+		cache:       cfg.Cache,
+		maxRetries:  cfg.MaxRetries,
+
+		usage:         cfg.Usage,
+		price:         price,
+		monthlyBudget: cfg.MonthlyBudget,
+	}
 }
 
-type Choice struct {
-	Message      Message `json:"message"`
-	FinishReason string  `json:"finish_reason"`
+// Provider returns the name of the client's provider
+func (c *Client) Provider() string {
+	return c.provider
 }
 
-type Usage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+// Model returns the model the client sends its prompts to
+func (c *Client) Model() string {
+	return c.model
 }
 
-// AnalyzeContent analyzes content to determine if it's primary reporting and extract original source
-func (c *Client) AnalyzeContent(req *models.ContentAnalysisRequest) (*models.ContentAnalysisResponse, error) {
+// AnalyzeContent analyzes content to determine if it's primary reporting and extract original source.
+// It only returns an error when ctx is cancelled; other failures fall back to a default result.
+func (c *Client) AnalyzeContent(ctx context.Context, req *models.ContentAnalysisRequest) (*models.ContentAnalysisResponse, error) {
 	// Prepare content for analysis
 	content := c.prepareContentForAnalysis(req)
 
@@ -127,61 +139,187 @@ func (c *Client) AnalyzeContent(req *models.ContentAnalysisRequest) (*models.Con
 		return ruleBasedResult, nil
 	}
 
-	// Apply rate limiting before making API request
-	c.rateLimiter.Wait()
+	// Syndicated copies of a story analysed before reuse the earlier answer, and copies analysed
+	// concurrently wait for the first one
+	var cacheKey string
+	if c.cache != nil {
+		cacheKey = analysisCacheKey(c.provider, c.model, req.Title, c.articleText(req))
+		for {
+			running := c.cache.start(cacheKey)
+			if running == nil {
+				break
+			}
+			log.Printf("⏳ LLM Analysis - Waiting for the analysis of an identical story: %s", req.Title)
+			select {
+			case <-running:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		defer c.cache.finish(cacheKey)
 
-	// Make API request
-	chatReq := ChatCompletionRequest{
-		Model: "gpt-4o-mini", // Using cheap model as requested
-		Messages: []Message{
-			{
-				Role:    "system",
-				Content: "You are an expert journalist and content analyst. Analyze news articles to determine if they are primary reporting or reference other sources. Always respond with valid JSON only.",
-			},
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-		Temperature: 0.3, // Lower temperature for more consistent results
-		MaxTokens:   200,
+		if cached, ok := c.cache.Get(cacheKey); ok {
+			log.Printf("💾 LLM Analysis - Using cached analysis for: %s", req.Title)
+			return cached, nil
+		}
 	}
 
-	response, err := c.makeAPIRequest(&chatReq)
-	if err != nil {
-		log.Printf("❌ LLM Analysis - API request failed: %v, defaulting to primary reporting", err)
+	if spent, over := c.overBudget(); over {
+		log.Printf("💸 LLM Analysis - Monthly budget of $%.2f reached ($%.2f spent), using rule-based analysis only: %s", c.monthlyBudget, spent, req.Title)
 		return &models.ContentAnalysisResponse{
-			IsPrimaryReporting: true, // Default to primary when API fails
+			IsPrimaryReporting: true, // Default to primary when the budget is exhausted
 			OriginalSourceName: nil,
 			Confidence:         0.1,
-			Reasoning:          fmt.Sprintf("LLM API request failed (%v), defaulting to primary reporting", err),
+			Reasoning:          "Monthly LLM budget exceeded and no rule matched, defaulting to primary reporting",
 		}, nil
 	}
 
-	if len(response.Choices) == 0 {
-		log.Printf("❌ LLM Analysis - No response choices from GPT, defaulting to primary reporting")
-		return &models.ContentAnalysisResponse{
-			IsPrimaryReporting: true, // Default to primary when no response
-			OriginalSourceName: nil,
-			Confidence:         0.1,
-			Reasoning:          "No response from LLM, defaulting to primary reporting",
-		}, nil
+	messages := []Message{{Role: "user", Content: prompt}}
+	for attempt := 0; ; attempt++ {
+		response, err := c.complete(ctx, messages)
+		if response != nil {
+			c.recordUsage(req.FeedID, response.Usage)
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			log.Printf("❌ LLM Analysis - API request failed: %v, defaulting to primary reporting", err)
+			return &models.ContentAnalysisResponse{
+				IsPrimaryReporting: true, // Default to primary when API fails
+				OriginalSourceName: nil,
+				Confidence:         0.1,
+				Reasoning:          fmt.Sprintf("LLM API request failed (%v), defaulting to primary reporting", err),
+			}, nil
+		}
+
+		// Parse the response
+		log.Printf("🔍 LLM Analysis - Raw response: %s", response.Content)
+		result, err := c.parseAnalysisResponse(response.Content)
+		if err == nil {
+			if c.cache != nil {
+				if err := c.cache.Put(cacheKey, c.provider, c.model, result); err != nil {
+					log.Printf("⚠️ LLM Analysis - Failed to cache analysis: %v", err)
+				}
+			}
+			return result, nil
+		}
+
+		if attempt >= maxCorrections {
+			log.Printf("❌ LLM Analysis - Failed to parse response: %v, defaulting to primary reporting", err)
+			return &models.ContentAnalysisResponse{
+				IsPrimaryReporting: true, // Default to primary when parsing fails
+				OriginalSourceName: nil,
+				Confidence:         0.1,
+				Reasoning:          fmt.Sprintf("Failed to parse LLM response (%v), defaulting to primary reporting", err),
+			}, nil
+		}
+
+		// Show the model its answer and what is wrong with it, and ask once more
+		log.Printf("🔁 LLM Analysis - Invalid response (%v), asking for a corrected answer: %s", err, req.Title)
+		messages = append(messages,
+			Message{Role: "assistant", Content: response.Content},
+			Message{Role: "user", Content: correctionPrompt(err)},
+		)
 	}
+}
 
-	// Parse the response
-	log.Printf("🔍 LLM Analysis - Raw response: %s", response.Choices[0].Message.Content)
-	result, err := c.parseAnalysisResponse(response.Choices[0].Message.Content)
-	if err != nil {
-		log.Printf("❌ LLM Analysis - Failed to parse response: %v, defaulting to primary reporting", err)
-		return &models.ContentAnalysisResponse{
-			IsPrimaryReporting: true, // Default to primary when parsing fails
-			OriginalSourceName: nil,
-			Confidence:         0.1,
-			Reasoning:          fmt.Sprintf("Failed to parse LLM response (%v), defaulting to primary reporting", err),
-		}, nil
+// complete sends a conversation to the backend within the rate limits, retrying rate limited
+// and temporarily failed requests with backoff
+func (c *Client) complete(ctx context.Context, messages []Message) (*CompletionResponse, error) {
+	completionReq := CompletionRequest{
+		Model:       c.model,
+		System:      "You are an expert journalist and content analyst. Analyze news articles to determine if they are primary reporting or reference other sources. Always respond with valid JSON only.",
+		Messages:    messages,
+		Temperature: c.temperature,
+		MaxTokens:   c.maxTokens,
+	}
+	if c.structured {
+		completionReq.Schema = analysisSchema
+	}
+
+	// Reserve the prompt and the longest possible answer, settled with the reported usage
+	estimatedTokens := estimateTokens(completionReq.System) + c.maxTokens
+	for _, message := range messages {
+		estimatedTokens += estimateTokens(message.Content)
 	}
 
-	return result, nil
+	for attempt := 0; ; attempt++ {
+		reservedTokens := 0
+		if c.rateLimiter != nil {
+			var err error
+			if reservedTokens, err = c.rateLimiter.Wait(ctx, estimatedTokens); err != nil {
+				return nil, err
+			}
+		}
+
+		response, err := c.backend.Complete(ctx, &completionReq)
+
+		var apiErr *APIError
+		errors.As(err, &apiErr)
+		if c.rateLimiter != nil {
+			usedTokens := 0
+			if response != nil {
+				usedTokens = response.Usage.TotalTokens
+				if usedTokens == 0 {
+					usedTokens = estimatedTokens // usage not reported
+				}
+				c.rateLimiter.Observe(response.RateLimits)
+			} else if apiErr != nil {
+				c.rateLimiter.Observe(apiErr.RateLimits)
+			}
+			c.rateLimiter.Settle(reservedTokens, usedTokens)
+		}
+
+		if apiErr == nil || !apiErr.Retryable() || attempt >= c.maxRetries || ctx.Err() != nil {
+			return response, err
+		}
+
+		delay := retryDelay(apiErr, attempt)
+		if c.rateLimiter != nil && apiErr.StatusCode == http.StatusTooManyRequests {
+			// Hold back the other requests on this account as well
+			c.rateLimiter.Pause(delay)
+		}
+		log.Printf("🔁 LLM Analysis - %v, retrying in %v (retry %d of %d)", apiErr, delay.Round(time.Millisecond), attempt+1, c.maxRetries)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// overBudget reports whether the tenant's spending this month reached its budget
+func (c *Client) overBudget() (float64, bool) {
+	if c.usage == nil || c.monthlyBudget <= 0 {
+		return 0, false
+	}
+	spent := c.usage.MonthCost(time.Now())
+	return spent, spent >= c.monthlyBudget
+}
+
+// recordUsage adds a call's tokens and cost to the tenant's usage ledger
+func (c *Client) recordUsage(feedID string, usage Usage) {
+	if c.usage == nil {
+		return
+	}
+	c.usage.Record(time.Now(), feedID, usage, c.price.Cost(usage))
+}
+
+// correctionPrompt asks the model to fix an answer that failed to parse or validate
+func correctionPrompt(problem error) string {
+	return fmt.Sprintf(`Your previous answer could not be used: %v
+
+Respond again with ONLY a JSON object with exactly these fields and no extra text:
+{
+  "is_primary_reporting": true or false,
+  "original_source_name": a source name string or null,
+  "confidence": a number between 0 and 1,
+  "reasoning": "Brief explanation of your decision"
+}`, problem)
 }
 
 // prepareContentForAnalysis combines title, description, and content for analysis
@@ -196,18 +334,7 @@ func (c *Client) prepareContentForAnalysis(req *models.ContentAnalysisRequest) s
 		parts = append(parts, "Description: "+*req.Description)
 	}
 
-	// Use FullContent if available, otherwise use Content
-	var contentText string
-	if req.FullContent != nil && *req.FullContent != "" {
-		contentText = *req.FullContent
-	} else if req.Content != nil && *req.Content != "" {
-		contentText = *req.Content
-	}
-
-	if contentText != "" {
-		// Convert HTML to clean text for LLM analysis
-		cleanText := c.htmlToText(contentText)
-		
+	if cleanText := c.articleText(req); cleanText != "" {
 		// Limit content to first 2000 characters to avoid token limits but get enough context
 		if len(cleanText) > 2000 {
 			cleanText = cleanText[:2000] + "..."
@@ -219,6 +346,17 @@ func (c *Client) prepareContentForAnalysis(req *models.ContentAnalysisRequest) s
 	return result
 }
 
+// articleText returns the article body as clean text, preferring FullContent over Content
+func (c *Client) articleText(req *models.ContentAnalysisRequest) string {
+	if req.FullContent != nil && *req.FullContent != "" {
+		return c.htmlToText(*req.FullContent)
+	}
+	if req.Content != nil && *req.Content != "" {
+		return c.htmlToText(*req.Content)
+	}
+	return ""
+}
+
 // createAnalysisPrompt creates the prompt for GPT analysis
 func (c *Client) createAnalysisPrompt(content string) string {
 	return fmt.Sprintf(`You are a journalism expert. Analyze this news article and determine if it's PRIMARY REPORTING or REFERENCED REPORTING.
@@ -268,100 +406,56 @@ For referenced reporting, set "original_source_name" to the main source name (e.
 For primary reporting, set "original_source_name" to null.`, content)
 }
 
-// makeAPIRequest makes the HTTP request to OpenAI API
-func (c *Client) makeAPIRequest(req *ChatCompletionRequest) (*ChatCompletionResponse, error) {
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequest("POST", c.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
-
-	resp, err := c.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d", resp.StatusCode)
-	}
-
-	var response ChatCompletionResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if len(response.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in response")
-	}
-
-	return &response, nil
-}
-
-// parseAnalysisResponse parses the GPT response into our structured format
+// parseAnalysisResponse parses the model's answer into our structured format after validating it
+// against the analysis schema
 func (c *Client) parseAnalysisResponse(content string) (*models.ContentAnalysisResponse, error) {
-	// Try to extract JSON from the response (GPT might include extra text)
+	// Models without structured output may still wrap the JSON in a code fence or extra text
 	start := strings.Index(content, "{")
 	end := strings.LastIndex(content, "}") + 1
 
-	if start == -1 || end == 0 {
-		// Log the full response for debugging
-		fmt.Printf("LLM Analysis - No JSON found in response: %s\n", content)
-		return nil, fmt.Errorf("no JSON found in response")
+	if start == -1 || end <= start {
+		return nil, fmt.Errorf("no JSON object found in response")
 	}
 
 	jsonStr := content[start:end]
 
+	var decoded any
+	if err := json.Unmarshal([]byte(jsonStr), &decoded); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+	if err := analysisSchema.Schema.Validate(decoded); err != nil {
+		return nil, err
+	}
+
 	var response struct {
 		IsPrimaryReporting bool    `json:"is_primary_reporting"`
-		OriginalSourceName any     `json:"original_source_name"` // Use any to handle null properly
+		OriginalSourceName *string `json:"original_source_name"`
 		Confidence         float64 `json:"confidence"`
 		Reasoning          string  `json:"reasoning"`
 	}
 
 	if err := json.Unmarshal([]byte(jsonStr), &response); err != nil {
-		fmt.Printf("LLM Analysis - Failed to parse JSON: %s, Error: %v\n", jsonStr, err)
 		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
 	}
 
-	log.Printf("🔍 LLM Analysis - Parsed response: IsPrimaryReporting=%v, OriginalSourceName=%v, Confidence=%.2f",
-		response.IsPrimaryReporting, response.OriginalSourceName, response.Confidence)
-
-	// Handle the original_source_name field properly
-	var sourceName *string
-	switch v := response.OriginalSourceName.(type) {
-	case string:
-		if v != "" && v != "null" {
-			sourceName = &v
-		}
-	case nil:
-		sourceName = nil
-	default:
-		// Handle other types gracefully
+	// Treat an empty or literal "null" source name as no source
+	sourceName, sourceLabel := response.OriginalSourceName, "<nil>"
+	if sourceName != nil && (*sourceName == "" || *sourceName == "null") {
 		sourceName = nil
 	}
+	if sourceName != nil {
+		sourceLabel = *sourceName
+	}
+
+	log.Printf("🔍 LLM Analysis - Parsed response: IsPrimaryReporting=%v, OriginalSourceName=%v, Confidence=%.2f",
+		response.IsPrimaryReporting, sourceLabel, response.Confidence)
 
-	result := &models.ContentAnalysisResponse{
+	return &models.ContentAnalysisResponse{
 		IsPrimaryReporting: response.IsPrimaryReporting,
 		OriginalSourceName: sourceName,
 		Confidence:         response.Confidence,
 		Reasoning:          response.Reasoning,
-	}
-
-	// Validate confidence is reasonable (between 0 and 1)
-	if result.Confidence < 0 || result.Confidence > 1 {
-		log.Printf("🔍 LLM Analysis - Invalid confidence value: %.2f, setting to 0.5", result.Confidence)
-		result.Confidence = 0.5
-	}
-
-	return result, nil
+	}, nil
 }
 
 // ruleBasedAnalysis provides a simple rule-based fallback for obvious cases
@@ -0,0 +1,303 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"strandnerd-crawler/internal/models"
+)
+
+func testAnalysisRequest() *models.ContentAnalysisRequest {
+	content := "The mayor told our reporter on Tuesday that the new bridge will open in the spring after two years of construction."
+	return &models.ContentAnalysisRequest{Title: "Bridge to open in spring", Content: &content}
+}
+
+func TestOpenAICompatibleBackend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("Expected no Authorization header without an API key, got %q", auth)
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var req ChatCompletionRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		var raw struct {
+			ResponseFormat struct {
+				JSONSchema struct {
+					Schema map[string]any `json:"schema"`
+				} `json:"json_schema"`
+			} `json:"response_format"`
+		}
+		json.Unmarshal(body, &raw)
+		checkSerializedSchema(t, raw.ResponseFormat.JSONSchema.Schema)
+		if req.Model != "llama3" || len(req.Messages) != 2 || req.Messages[0].Role != "system" {
+			t.Errorf("Unexpected request: %+v", req)
+		}
+		if req.ResponseFormat == nil || req.ResponseFormat.Type != "json_schema" || !req.ResponseFormat.JSONSchema.Strict ||
+			len(req.ResponseFormat.JSONSchema.Schema.Required) != 4 {
+			t.Errorf("Expected a strict JSON schema response format, got %+v", req.ResponseFormat)
+		}
+
+		w.Write([]byte(`{
+			"choices": [{"message": {"role": "assistant", "content": "{\"is_primary_reporting\": false, \"original_source_name\": \"Reuters\", \"confidence\": 0.8, \"reasoning\": \"Cites Reuters\"}"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 120, "completion_tokens": 30, "total_tokens": 150}
+		}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{Provider: ProviderOpenAI, BaseURL: server.URL + "/v1", Model: "llama3"})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	result, err := client.AnalyzeContent(context.Background(), testAnalysisRequest())
+	if err != nil {
+		t.Fatalf("AnalyzeContent failed: %v", err)
+	}
+	if result.IsPrimaryReporting || result.OriginalSourceName == nil || *result.OriginalSourceName != "Reuters" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
+
+func TestAnthropicBackend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "secret" || r.Header.Get("anthropic-version") != anthropicVersion {
+			t.Errorf("Unexpected headers: %v", r.Header)
+		}
+
+		var req anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if req.System == "" || len(req.Messages) != 1 || req.Messages[0].Role != "user" || req.MaxTokens != 200 {
+			t.Errorf("Unexpected request: %+v", req)
+		}
+
+		w.Write([]byte(`{
+			"content": [{"type": "text", "text": "{\"is_primary_reporting\": true,"}, {"type": "text", "text": " \"original_source_name\": null, \"confidence\": 0.9}"}],
+			"stop_reason": "end_turn",
+			"usage": {"input_tokens": 100, "output_tokens": 20}
+		}`))
+	}))
+	defer server.Close()
+
+	backend, err := newBackend(&Config{Provider: ProviderAnthropic, BaseURL: server.URL, APIKey: "secret"})
+	if err != nil {
+		t.Fatalf("newBackend failed: %v", err)
+	}
+
+	response, err := backend.Complete(context.Background(), &CompletionRequest{Model: "claude", System: "system", Messages: []Message{{Role: "user", Content: "prompt"}}, MaxTokens: 200})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if !strings.HasPrefix(response.Content, `{"is_primary_reporting": true, "original_source_name"`) || response.FinishReason != "end_turn" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+	if response.Usage != (Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}) {
+		t.Errorf("Unexpected usage: %+v", response.Usage)
+	}
+}
+
+// checkSerializedSchema checks the analysis schema as a provider receives it: the root must be a
+// plain object type, and only the nullable field lists several types
+func checkSerializedSchema(t *testing.T, schema map[string]any) {
+	t.Helper()
+	if schema["type"] != "object" {
+		t.Errorf(`Expected the schema root to have "type":"object", got %#v`, schema["type"])
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	if confidence, _ := properties["confidence"].(map[string]any); confidence["type"] != "number" {
+		t.Errorf("Expected a single type as a string, got %#v", confidence["type"])
+	}
+	if source, _ := properties["original_source_name"].(map[string]any); len(source["type"].([]any)) != 2 {
+		t.Errorf("Expected the nullable field's types as an array, got %#v", source["type"])
+	}
+}
+
+func TestAnthropicStructuredOutput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req anthropicRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		var raw struct {
+			Tools []struct {
+				InputSchema map[string]any `json:"input_schema"`
+			} `json:"tools"`
+		}
+		json.Unmarshal(body, &raw)
+		if len(raw.Tools) == 1 {
+			checkSerializedSchema(t, raw.Tools[0].InputSchema)
+		}
+		if len(req.Tools) != 1 || req.ToolChoice == nil || req.ToolChoice.Name != req.Tools[0].Name || req.Tools[0].InputSchema == nil {
+			t.Errorf("Expected a forced tool call, got tools %+v and choice %+v", req.Tools, req.ToolChoice)
+		}
+
+		w.Write([]byte(`{
+			"content": [{"type": "tool_use", "id": "toolu_1", "name": "content_analysis", "input": {"is_primary_reporting": false, "original_source_name": "BBC News", "confidence": 0.85, "reasoning": "Cites the BBC"}}],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 100, "output_tokens": 20}
+		}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{Provider: ProviderAnthropic, BaseURL: server.URL, APIKey: "secret"})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	result, err := client.AnalyzeContent(context.Background(), testAnalysisRequest())
+	if err != nil {
+		t.Fatalf("AnalyzeContent failed: %v", err)
+	}
+	if result.IsPrimaryReporting || result.OriginalSourceName == nil || *result.OriginalSourceName != "BBC News" || result.Confidence != 0.85 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
+
+func TestInvalidResponseIsCorrected(t *testing.T) {
+	fake := &FakeBackend{Respond: func(req *CompletionRequest) (string, error) {
+		if len(req.Messages) == 1 {
+			return `{"is_primary_reporting": "yes", "confidence": 1.5}`, nil
+		}
+		return `{"is_primary_reporting": false, "original_source_name": "Reuters", "confidence": 0.7, "reasoning": "Cites Reuters"}`, nil
+	}}
+	client := NewClientWithBackend(fake, Config{Provider: ProviderFake})
+
+	result, err := client.AnalyzeContent(context.Background(), testAnalysisRequest())
+	if err != nil {
+		t.Fatalf("AnalyzeContent failed: %v", err)
+	}
+	if result.IsPrimaryReporting || result.OriginalSourceName == nil || *result.OriginalSourceName != "Reuters" {
+		t.Errorf("Expected the corrected answer, got %+v", result)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("Expected one corrective re-prompt, got %d requests", len(requests))
+	}
+	retry := requests[1].Messages
+	if len(retry) != 3 || retry[1].Role != "assistant" || retry[2].Role != "user" || !strings.Contains(retry[2].Content, "$.confidence") {
+		t.Errorf("Unexpected corrective conversation: %+v", retry)
+	}
+	if requests[0].Schema == nil {
+		t.Error("Expected the request to carry the analysis schema")
+	}
+}
+
+func TestInvalidResponseFallsBack(t *testing.T) {
+	fake := &FakeBackend{Respond: func(req *CompletionRequest) (string, error) {
+		return "I cannot decide.", nil
+	}}
+	client := NewClientWithBackend(fake, Config{Provider: ProviderFake, DisableStructuredOutput: true})
+
+	result, err := client.AnalyzeContent(context.Background(), testAnalysisRequest())
+	if err != nil {
+		t.Fatalf("AnalyzeContent failed: %v", err)
+	}
+	if !result.IsPrimaryReporting || result.Confidence != 0.1 {
+		t.Errorf("Expected the primary reporting fallback, got %+v", result)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 2 || requests[0].Schema != nil {
+		t.Errorf("Expected two plain JSON requests, got %+v", requests)
+	}
+}
+
+func TestParseAnalysisResponse(t *testing.T) {
+	client := &Client{}
+
+	result, err := client.parseAnalysisResponse("```json\n{\"is_primary_reporting\": true, \"original_source_name\": \"null\", \"confidence\": 1, \"reasoning\": \"\"}\n```")
+	if err != nil {
+		t.Fatalf("Expected a fenced answer to parse, got %v", err)
+	}
+	if !result.IsPrimaryReporting || result.OriginalSourceName != nil || result.Confidence != 1 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	invalid := []string{
+		"no json here",
+		`{"is_primary_reporting": true, "original_source_name": null, "confidence": 0.9}`,
+		`{"is_primary_reporting": true, "original_source_name": 42, "confidence": 0.9, "reasoning": ""}`,
+		`{"is_primary_reporting": true, "original_source_name": null, "confidence": 0.9, "reasoning": "", "source": "x"}`,
+		`{"is_primary_reporting": true, "original_source_name": null, "confidence": -0.2, "reasoning": ""}`,
+	}
+	for _, content := range invalid {
+		if _, err := client.parseAnalysisResponse(content); err == nil {
+			t.Errorf("Expected %s to be rejected", content)
+		}
+	}
+}
+
+func TestBackendErrorFallsBack(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model overloaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{Provider: ProviderAnthropic, BaseURL: server.URL, APIKey: "secret"})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	result, err := client.AnalyzeContent(context.Background(), testAnalysisRequest())
+	if err != nil {
+		t.Fatalf("AnalyzeContent failed: %v", err)
+	}
+	if !result.IsPrimaryReporting || result.Confidence != 0.1 || !strings.Contains(result.Reasoning, "model overloaded") {
+		t.Errorf("Expected the primary reporting fallback with the API error, got %+v", result)
+	}
+}
+
+func TestFakeBackend(t *testing.T) {
+	fake := &FakeBackend{}
+	client := NewClientWithBackend(fake, Config{Provider: ProviderFake, MaxTokens: 200})
+
+	result, err := client.AnalyzeContent(context.Background(), testAnalysisRequest())
+	if err != nil {
+		t.Fatalf("AnalyzeContent failed: %v", err)
+	}
+	if !result.IsPrimaryReporting || result.Confidence != 0.5 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 1 || requests[0].Model != "fake" || !strings.Contains(requests[0].Messages[0].Content, "Bridge to open in spring") {
+		t.Errorf("Unexpected requests: %+v", requests)
+	}
+}
+
+func TestNewClientErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"OpenAI without key", Config{Provider: ProviderOpenAI}},
+		{"default provider without key", Config{}},
+		{"Anthropic without key", Config{Provider: ProviderAnthropic, BaseURL: "http://localhost:8080"}},
+		{"unknown provider", Config{Provider: "mystery", APIKey: "secret"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClient(tt.cfg); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
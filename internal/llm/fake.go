@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"sync"
+)
+
+// fakeAnswer is the FakeBackend's default answer, classifying every article as primary reporting
+const fakeAnswer = `{"is_primary_reporting": true, "original_source_name": null, "confidence": 0.5, "reasoning": "Fake analysis"}`
+
+// FakeBackend answers prompts without calling a model. Respond decides the answer when set,
+// otherwise every article is classified as primary reporting. Token usage is estimated from the
+// text length so usage accounting sees plausible numbers.
+type FakeBackend struct {
+	Respond func(req *CompletionRequest) (string, error)
+
+	mu       sync.Mutex
+	requests []CompletionRequest
+}
+
+// Complete records the request and returns the configured answer
+func (f *FakeBackend) Complete(ctx context.Context, req *CompletionRequest) (*CompletionResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, *req)
+	f.mu.Unlock()
+
+	answer := fakeAnswer
+	if f.Respond != nil {
+		var err error
+		if answer, err = f.Respond(req); err != nil {
+			return nil, err
+		}
+	}
+
+	promptTokens := estimateTokens(req.System)
+	for _, message := range req.Messages {
+		promptTokens += estimateTokens(message.Content)
+	}
+	completionTokens := estimateTokens(answer)
+	return &CompletionResponse{
+		Content:      answer,
+		FinishReason: "stop",
+		Usage: Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		},
+	}, nil
+}
+
+// Requests returns the requests the backend has answered
+func (f *FakeBackend) Requests() []CompletionRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]CompletionRequest(nil), f.requests...)
+}
+
+// estimateTokens approximates a text's token count at four characters per token
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
@@ -0,0 +1,336 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitBurstWindow is how much of a minute's budget may be spent at once
+	rateLimitBurstWindow = 10 * time.Second
+
+	// retryBaseDelay and retryMaxDelay bound the backoff between retries of a failed request
+	// that came without a Retry-After header
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// RateLimiter keeps requests within a requests-per-minute and a tokens-per-minute budget using
+// two token buckets. Token costs are estimated when a request is sent and settled with the usage
+// the API reports. Rate limit headers and Retry-After pauses from the API are honored as well.
+// Waiting never holds the lock, so clients sharing a limiter only block each other while their
+// budget is exhausted.
+type RateLimiter struct {
+	mu          sync.Mutex
+	requests    *tokenBucket // nil when requests are not limited
+	tokens      *tokenBucket // nil when tokens are not limited
+	pausedUntil time.Time
+}
+
+// tokenBucket refills continuously at rate per second up to capacity. Its level may drop below
+// zero when a request used more tokens than estimated.
+type tokenBucket struct {
+	capacity float64
+	level    float64
+	rate     float64
+	updated  time.Time
+}
+
+// RateLimitInfo holds the rate limit state an API reported with a response
+type RateLimitInfo struct {
+	RemainingRequests int           // -1 when not reported
+	RemainingTokens   int           // -1 when not reported
+	ResetRequests     time.Duration // until the request budget is restored
+	ResetTokens       time.Duration // until the token budget is restored
+	RetryAfter        time.Duration // wait requested by the API before the next request
+}
+
+// APIError is a failed API response
+type APIError struct {
+	StatusCode int
+	Message    string
+	RateLimits *RateLimitInfo
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("API request failed with status %d", e.StatusCode)
+}
+
+// Retryable reports whether the request may succeed when sent again: it was rate limited or the
+// provider was temporarily unavailable or overloaded
+func (e *APIError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout, 529: // 529: Anthropic overloaded
+		return true
+	}
+	return false
+}
+
+var (
+	sharedLimitersMutex sync.Mutex
+	sharedLimiters      = make(map[string]*RateLimiter)
+)
+
+// NewRateLimiter creates a rate limiter for the given per-minute budgets, 0 leaves a budget unlimited
+func NewRateLimiter(requestsPerMinute, tokensPerMinute int) *RateLimiter {
+	now := time.Now()
+	return &RateLimiter{
+		requests: newTokenBucket(requestsPerMinute, now),
+		tokens:   newTokenBucket(tokensPerMinute, now),
+	}
+}
+
+// sharedRateLimiter returns the limiter of the API account a configuration uses. Provider rate
+// limits apply per account, so tenants sharing an API key share a limiter while tenants with
+// their own keys never wait for each other. The budgets are those of the first client created
+// for the account.
+func sharedRateLimiter(cfg *Config) *RateLimiter {
+	key := cfg.Provider + "\x00" + cfg.BaseURL + "\x00" + cfg.APIKey
+
+	sharedLimitersMutex.Lock()
+	defer sharedLimitersMutex.Unlock()
+
+	limiter, ok := sharedLimiters[key]
+	if !ok {
+		limiter = NewRateLimiter(cfg.RequestsPerMinute, cfg.TokensPerMinute)
+		sharedLimiters[key] = limiter
+	}
+	return limiter
+}
+
+func newTokenBucket(perMinute int, now time.Time) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+
+	rate := float64(perMinute) / time.Minute.Seconds()
+	capacity := math.Max(1, rate*rateLimitBurstWindow.Seconds())
+	return &tokenBucket{capacity: capacity, level: capacity, rate: rate, updated: now}
+}
+
+// refill adds the tokens accrued since the last update
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.level = math.Min(b.capacity, b.level+elapsed*b.rate)
+	}
+	b.updated = now
+}
+
+// waitFor returns how long until the bucket holds amount tokens, zero if it already does
+func (b *tokenBucket) waitFor(amount float64) time.Duration {
+	if b.level >= amount {
+		return 0
+	}
+	return time.Duration((amount - b.level) / b.rate * float64(time.Second))
+}
+
+// Wait blocks until a request estimated to use the given number of tokens fits into both
+// budgets, then reserves them and returns how many tokens it reserved, which is less than the
+// estimate for a request larger than the token bucket. It returns early when ctx is cancelled.
+func (rl *RateLimiter) Wait(ctx context.Context, estimatedTokens int) (int, error) {
+	for {
+		reserved, delay := rl.reserve(time.Now(), estimatedTokens)
+		if delay <= 0 {
+			return reserved, nil
+		}
+
+		log.Printf("🕐 Rate limiting: waiting %v before next LLM request", delay.Round(time.Millisecond))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// reserve takes a request and the estimated tokens from the buckets if both suffice and returns
+// the tokens taken, otherwise it returns how long to wait before trying again
+func (rl *RateLimiter) reserve(now time.Time, estimatedTokens int) (int, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if now.Before(rl.pausedUntil) {
+		return 0, rl.pausedUntil.Sub(now)
+	}
+
+	var delay time.Duration
+	if rl.requests != nil {
+		rl.requests.refill(now)
+		delay = rl.requests.waitFor(1)
+	}
+
+	// A single request larger than the bucket only waits for and reserves a full bucket
+	tokens := estimatedTokens
+	if rl.tokens != nil {
+		rl.tokens.refill(now)
+		tokens = min(tokens, int(rl.tokens.capacity))
+		if wait := rl.tokens.waitFor(float64(tokens)); wait > delay {
+			delay = wait
+		}
+	}
+	if delay > 0 {
+		return 0, delay
+	}
+
+	if rl.requests != nil {
+		rl.requests.level--
+	}
+	if rl.tokens != nil {
+		rl.tokens.level -= float64(tokens)
+	}
+	return tokens, 0
+}
+
+// Settle corrects a reservation of the tokens returned by Wait with the tokens the request
+// actually used, returning unused tokens or charging the excess
+func (rl *RateLimiter) Settle(reservedTokens, usedTokens int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.tokens != nil {
+		rl.tokens.level = math.Min(rl.tokens.capacity, rl.tokens.level+float64(reservedTokens-usedTokens))
+	}
+}
+
+// Pause holds back all requests for d, e.g. after the API rejected one as rate limited
+func (rl *RateLimiter) Pause(d time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.pause(time.Now(), d)
+}
+
+// pause extends the pause to now+d. Caller must hold the lock.
+func (rl *RateLimiter) pause(now time.Time, d time.Duration) {
+	if until := now.Add(d); until.After(rl.pausedUntil) {
+		rl.pausedUntil = until
+	}
+}
+
+// Observe adjusts the budgets to the rate limit state the API reported
+func (rl *RateLimiter) Observe(info *RateLimitInfo) {
+	if info == nil {
+		return
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	if info.RetryAfter > 0 {
+		rl.pause(now, info.RetryAfter)
+	}
+	rl.observeRemaining(rl.requests, info.RemainingRequests, info.ResetRequests, now)
+	rl.observeRemaining(rl.tokens, info.RemainingTokens, info.ResetTokens, now)
+}
+
+// observeRemaining pauses until an exhausted budget resets and never lets a bucket hold more
+// than the API says remains. Caller must hold the lock.
+func (rl *RateLimiter) observeRemaining(bucket *tokenBucket, remaining int, reset time.Duration, now time.Time) {
+	if remaining < 0 {
+		return
+	}
+	if remaining == 0 && reset > 0 {
+		rl.pause(now, reset)
+	}
+	if bucket != nil {
+		bucket.refill(now)
+		bucket.level = math.Min(bucket.level, float64(remaining))
+	}
+}
+
+// parseRateLimitHeaders reads the OpenAI-style x-ratelimit-*, Anthropic's anthropic-ratelimit-*
+// and the Retry-After headers, nil when a response has none of them
+func parseRateLimitHeaders(header http.Header) *RateLimitInfo {
+	info := &RateLimitInfo{RemainingRequests: -1, RemainingTokens: -1}
+	found := false
+
+	remaining := func(names ...string) int {
+		for _, name := range names {
+			if value, err := strconv.Atoi(strings.TrimSpace(header.Get(name))); err == nil {
+				found = true
+				return value
+			}
+		}
+		return -1
+	}
+	reset := func(names ...string) time.Duration {
+		for _, name := range names {
+			if value := header.Get(name); value != "" {
+				if d, ok := parseResetValue(value); ok {
+					found = true
+					return d
+				}
+			}
+		}
+		return 0
+	}
+
+	info.RemainingRequests = remaining("x-ratelimit-remaining-requests", "anthropic-ratelimit-requests-remaining")
+	info.RemainingTokens = remaining("x-ratelimit-remaining-tokens", "anthropic-ratelimit-tokens-remaining")
+	info.ResetRequests = reset("x-ratelimit-reset-requests", "anthropic-ratelimit-requests-reset")
+	info.ResetTokens = reset("x-ratelimit-reset-tokens", "anthropic-ratelimit-tokens-reset")
+
+	if ms, err := strconv.Atoi(header.Get("retry-after-ms")); err == nil && ms > 0 {
+		info.RetryAfter = time.Duration(ms) * time.Millisecond
+		found = true
+	} else if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			info.RetryAfter = time.Duration(seconds) * time.Second
+			found = true
+		} else if at, err := http.ParseTime(value); err == nil {
+			info.RetryAfter = time.Until(at)
+			found = true
+		}
+	}
+
+	if !found {
+		return nil
+	}
+	return info
+}
+
+// parseResetValue reads a rate limit reset as a duration ("6m0s", "20ms"), seconds ("1.5") or
+// an RFC 3339 timestamp
+func parseResetValue(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if d, err := time.ParseDuration(value); err == nil {
+		return d, true
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return time.Until(at), true
+	}
+	return 0, false
+}
+
+// retryDelay returns the wait before retrying a failed request: the API's Retry-After when it
+// sent one, otherwise exponential backoff with jitter
+func retryDelay(apiErr *APIError, attempt int) time.Duration {
+	if apiErr.RateLimits != nil && apiErr.RateLimits.RetryAfter > 0 {
+		return apiErr.RateLimits.RetryAfter
+	}
+
+	delay := retryBaseDelay << attempt
+	if delay <= 0 || delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	// Spread retries of concurrent requests so they don't hit the API at the same moment
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
@@ -0,0 +1,155 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBudgets(t *testing.T) {
+	now := time.Now()
+	rl := NewRateLimiter(60, 6000) // bursts of 10 requests and 1000 tokens
+	rl.requests.updated, rl.tokens.updated = now, now
+
+	for i := 0; i < 4; i++ {
+		if _, delay := rl.reserve(now, 250); delay != 0 {
+			t.Fatalf("Expected request %d to fit the burst, got delay %v", i+1, delay)
+		}
+	}
+
+	// The token bucket is empty, 100 tokens refill in one second
+	if _, delay := rl.reserve(now, 100); delay != time.Second {
+		t.Errorf("Expected to wait one second for tokens, got %v", delay)
+	}
+
+	// Reported usage below the reservation returns the difference
+	rl.Settle(250, 150)
+	if _, delay := rl.reserve(now, 100); delay != 0 {
+		t.Errorf("Expected settled tokens to be available, got delay %v", delay)
+	}
+
+	// A request larger than the bucket waits for a full bucket rather than forever
+	now = now.Add(10 * time.Second)
+	reserved, delay := rl.reserve(now, 5000)
+	if delay != 0 || reserved != 1000 {
+		t.Fatalf("Expected an oversized request to pass with a full bucket, got %d tokens after %v", reserved, delay)
+	}
+
+	// Settling charges everything beyond the full bucket it reserved: 4100 tokens take 41 seconds
+	rl.Settle(reserved, 5000)
+	if _, delay := rl.reserve(now, 100); delay != 41*time.Second {
+		t.Errorf("Expected to wait for the oversized request's excess tokens, got %v", delay)
+	}
+}
+
+func TestRateLimiterObserve(t *testing.T) {
+	rl := NewRateLimiter(600, 0)
+
+	rl.Observe(&RateLimitInfo{RemainingRequests: 0, RemainingTokens: -1, ResetRequests: 5 * time.Second})
+	if _, delay := rl.reserve(time.Now(), 100); delay < 4*time.Second || delay > 5*time.Second {
+		t.Errorf("Expected to wait for the request budget reset, got %v", delay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := rl.Wait(ctx, 100); err != context.DeadlineExceeded {
+		t.Errorf("Expected Wait to stop when the context ends, got %v", err)
+	}
+}
+
+func TestParseRateLimitHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("x-ratelimit-remaining-requests", "59")
+	header.Set("x-ratelimit-remaining-tokens", "1500")
+	header.Set("x-ratelimit-reset-requests", "1s")
+	header.Set("x-ratelimit-reset-tokens", "6m0s")
+	header.Set("Retry-After", "2")
+
+	info := parseRateLimitHeaders(header)
+	want := RateLimitInfo{RemainingRequests: 59, RemainingTokens: 1500, ResetRequests: time.Second, ResetTokens: 6 * time.Minute, RetryAfter: 2 * time.Second}
+	if info == nil || *info != want {
+		t.Errorf("Unexpected OpenAI rate limits: %+v", info)
+	}
+
+	header = http.Header{}
+	header.Set("anthropic-ratelimit-tokens-remaining", "0")
+	header.Set("anthropic-ratelimit-tokens-reset", time.Now().Add(30*time.Second).UTC().Format(time.RFC3339))
+	header.Set("retry-after-ms", "250")
+	info = parseRateLimitHeaders(header)
+	if info == nil || info.RemainingTokens != 0 || info.RemainingRequests != -1 ||
+		info.ResetTokens < 28*time.Second || info.ResetTokens > 30*time.Second || info.RetryAfter != 250*time.Millisecond {
+		t.Errorf("Unexpected Anthropic rate limits: %+v", info)
+	}
+
+	if info := parseRateLimitHeaders(http.Header{"Content-Type": {"application/json"}}); info != nil {
+		t.Errorf("Expected no rate limits without headers, got %+v", info)
+	}
+}
+
+func TestRetryAfterRateLimit(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("retry-after-ms", "20")
+			http.Error(w, `{"error": {"message": "Rate limit reached"}}`, http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{
+			"choices": [{"message": {"role": "assistant", "content": "{\"is_primary_reporting\": true, \"original_source_name\": null, \"confidence\": 0.9, \"reasoning\": \"Own reporting\"}"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 100, "completion_tokens": 20, "total_tokens": 120}
+		}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{BaseURL: server.URL, RequestsPerMinute: 600, MaxRetries: 2})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	start := time.Now()
+	result, err := client.AnalyzeContent(context.Background(), testAnalysisRequest())
+	if err != nil {
+		t.Fatalf("AnalyzeContent failed: %v", err)
+	}
+	if calls.Load() != 2 || result.Confidence != 0.9 {
+		t.Errorf("Expected the rate limited request to be retried, got %d calls and %+v", calls.Load(), result)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected the retry to honor Retry-After, retried after %v", elapsed)
+	}
+}
+
+func TestNonRetryableErrorsAreNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "invalid model", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{BaseURL: server.URL, MaxRetries: 3})
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	client.AnalyzeContent(context.Background(), testAnalysisRequest())
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected a bad request not to be retried, got %d calls", calls.Load())
+	}
+}
+
+func TestSharedRateLimiterPerAccount(t *testing.T) {
+	a := sharedRateLimiter(&Config{Provider: ProviderOpenAI, BaseURL: "http://shared.test", APIKey: "key-a"})
+	b := sharedRateLimiter(&Config{Provider: ProviderOpenAI, BaseURL: "http://shared.test", APIKey: "key-a"})
+	c := sharedRateLimiter(&Config{Provider: ProviderOpenAI, BaseURL: "http://shared.test", APIKey: "key-b"})
+
+	if a != b {
+		t.Error("Expected clients of the same account to share a limiter")
+	}
+	if a == c {
+		t.Error("Expected clients of different accounts to have their own limiters")
+	}
+}
@@ -0,0 +1,177 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// JSONSchema is the subset of JSON Schema used to describe structured model answers. It is sent
+// to providers that support schema-constrained output and validates every answer.
+type JSONSchema struct {
+	Type                 SchemaTypes            `json:"type"`
+	Description          string                 `json:"description,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+}
+
+// SchemaTypes lists the JSON types a value may have. A single type is written as a string, as
+// providers require for the root of a schema, and only a nullable field as an array.
+type SchemaTypes []string
+
+// MarshalJSON writes a single type as a string and several types as an array
+func (t SchemaTypes) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// UnmarshalJSON reads a type given either as a string or as an array
+func (t *SchemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = SchemaTypes{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// ResponseSchema names the schema a structured answer must follow
+type ResponseSchema struct {
+	Name        string
+	Description string
+	Schema      *JSONSchema
+}
+
+// analysisSchema describes the content analysis answer
+var analysisSchema = &ResponseSchema{
+	Name:        "content_analysis",
+	Description: "Record whether a news article is primary reporting and which source it references",
+	Schema: &JSONSchema{
+		Type: []string{"object"},
+		Properties: map[string]*JSONSchema{
+			"is_primary_reporting": {
+				Type:        []string{"boolean"},
+				Description: "true if the outlet did the original reporting",
+			},
+			"original_source_name": {
+				Type:        []string{"string", "null"},
+				Description: "the referenced source, \"Unknown\" if unidentified, null for primary reporting",
+			},
+			"confidence": {
+				Type:        []string{"number"},
+				Description: "confidence in the decision between 0 and 1",
+				Minimum:     floatPtr(0),
+				Maximum:     floatPtr(1),
+			},
+			"reasoning": {
+				Type:        []string{"string"},
+				Description: "brief explanation of the decision",
+			},
+		},
+		Required:             []string{"is_primary_reporting", "original_source_name", "confidence", "reasoning"},
+		AdditionalProperties: boolPtr(false),
+	},
+}
+
+// Validate checks a decoded JSON value against the schema and describes the first violations found
+func (s *JSONSchema) Validate(value any) error {
+	var problems []string
+	s.validate("$", value, &problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("response does not match the schema: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validate appends the violations of value at path to problems
+func (s *JSONSchema) validate(path string, value any, problems *[]string) {
+	if !s.allowsType(value) {
+		*problems = append(*problems, fmt.Sprintf("%s must be %s, got %s", path, strings.Join(s.Type, " or "), jsonType(value)))
+		return
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			*problems = append(*problems, fmt.Sprintf("%s must be at least %g, got %g", path, *s.Minimum, v))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			*problems = append(*problems, fmt.Sprintf("%s must be at most %g, got %g", path, *s.Maximum, v))
+		}
+
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s.%s is required", path, name))
+			}
+		}
+
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					*problems = append(*problems, fmt.Sprintf("%s.%s is not allowed", path, name))
+				}
+				continue
+			}
+			property.validate(path+"."+name, v[name], problems)
+		}
+	}
+}
+
+// allowsType reports whether value has one of the schema's types
+func (s *JSONSchema) allowsType(value any) bool {
+	if len(s.Type) == 0 {
+		return true
+	}
+
+	actual := jsonType(value)
+	for _, t := range s.Type {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType names the JSON type of a value decoded by encoding/json
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
@@ -0,0 +1,266 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// usageDayFormat keys the ledger's days, which are counted in UTC
+	usageDayFormat = "2006-01-02"
+
+	// usageRetention is how long daily usage is kept for reports
+	usageRetention = 400 * 24 * time.Hour
+)
+
+// ModelPrice is what a model costs in USD per million tokens
+type ModelPrice struct {
+	Input  float64 `json:"input" yaml:"input"`
+	Output float64 `json:"output" yaml:"output"`
+}
+
+// defaultPrices are the public list prices of common models. A configured price table extends
+// and overrides them.
+var defaultPrices = map[string]ModelPrice{
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.60},
+	"gpt-4o":            {Input: 2.50, Output: 10.00},
+	"gpt-4.1-mini":      {Input: 0.40, Output: 1.60},
+	"gpt-4.1":           {Input: 2.00, Output: 8.00},
+	"gpt-3.5-turbo":     {Input: 0.50, Output: 1.50},
+	"claude-3-5-haiku":  {Input: 0.80, Output: 4.00},
+	"claude-3-5-sonnet": {Input: 3.00, Output: 15.00},
+	"claude-3-7-sonnet": {Input: 3.00, Output: 15.00},
+	"claude-sonnet-4":   {Input: 3.00, Output: 15.00},
+	"fake":              {},
+}
+
+// priceFor returns the price of a model from the table, matching the longest model name prefix
+// so dated versions like gpt-4o-mini-2024-07-18 use the gpt-4o-mini price
+func priceFor(prices map[string]ModelPrice, model string) (ModelPrice, bool) {
+	best := ""
+	for name := range prices {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return prices[best], true
+}
+
+// Cost returns the USD cost of a completion at this price
+func (p ModelPrice) Cost(usage Usage) float64 {
+	return (float64(usage.PromptTokens)*p.Input + float64(usage.CompletionTokens)*p.Output) / 1e6
+}
+
+// UsageTotals sums the language model calls of a feed, a day or a month
+type UsageTotals struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+func (t *UsageTotals) add(other UsageTotals) {
+	t.Calls += other.Calls
+	t.PromptTokens += other.PromptTokens
+	t.CompletionTokens += other.CompletionTokens
+	t.CostUSD += other.CostUSD
+}
+
+// UsageReport is a tenant's language model usage in one month
+type UsageReport struct {
+	Month  string // YYYY-MM
+	Total  UsageTotals
+	ByFeed map[string]UsageTotals // by feed ID
+	ByDay  map[string]UsageTotals // by YYYY-MM-DD
+}
+
+// UsageLedger records a tenant's language model calls per day and feed and optionally mirrors
+// them to a JSON file so monthly totals and budgets survive restarts. Calls are only written by
+// Flush, so a crawl rewrites the file once rather than once per call.
+type UsageLedger struct {
+	path  string
+	days  map[string]map[string]*UsageTotals // day -> feed ID -> totals
+	dirty bool
+	mutex sync.Mutex
+}
+
+// NewUsageLedger creates a ledger backed by a JSON file, loading any recorded usage. An empty
+// path keeps the usage in memory only.
+func NewUsageLedger(path string) (*UsageLedger, error) {
+	l := &UsageLedger{
+		path: path,
+		days: make(map[string]map[string]*UsageTotals),
+	}
+	if path == "" {
+		return l, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return l, nil
+		}
+		return nil, fmt.Errorf("failed to read usage file %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &l.days); err != nil {
+		return nil, fmt.Errorf("failed to parse usage file %s: %w", path, err)
+	}
+
+	l.prune(time.Now())
+	return l, nil
+}
+
+// Record adds a call's usage and cost to the feed's totals for the day
+func (l *UsageLedger) Record(at time.Time, feedID string, usage Usage, cost float64) {
+	day := at.UTC().Format(usageDayFormat)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	feeds, ok := l.days[day]
+	if !ok {
+		feeds = make(map[string]*UsageTotals)
+		l.days[day] = feeds
+	}
+	totals, ok := feeds[feedID]
+	if !ok {
+		totals = &UsageTotals{}
+		feeds[feedID] = totals
+	}
+	totals.add(UsageTotals{
+		Calls:            1,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		CostUSD:          cost,
+	})
+	l.dirty = true
+}
+
+// Flush writes recorded calls to disk, dropping days past the retention window
+func (l *UsageLedger) Flush() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if !l.dirty {
+		return nil
+	}
+	l.prune(time.Now())
+	if err := l.save(); err != nil {
+		return err
+	}
+	l.dirty = false
+	return nil
+}
+
+// MonthCost returns the USD spent in the UTC month of the given time
+func (l *UsageLedger) MonthCost(at time.Time) float64 {
+	return l.Report(at).Total.CostUSD
+}
+
+// Report sums the usage of the UTC month of the given time by feed and by day
+func (l *UsageLedger) Report(at time.Time) *UsageReport {
+	month := at.UTC().Format("2006-01")
+	report := &UsageReport{
+		Month:  month,
+		ByFeed: make(map[string]UsageTotals),
+		ByDay:  make(map[string]UsageTotals),
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for day, feeds := range l.days {
+		if !strings.HasPrefix(day, month) {
+			continue
+		}
+		for feedID, totals := range feeds {
+			report.Total.add(*totals)
+
+			byFeed := report.ByFeed[feedID]
+			byFeed.add(*totals)
+			report.ByFeed[feedID] = byFeed
+
+			byDay := report.ByDay[day]
+			byDay.add(*totals)
+			report.ByDay[day] = byDay
+		}
+	}
+
+	return report
+}
+
+// Days returns the report's days in order
+func (r *UsageReport) Days() []string {
+	return sortedKeys(r.ByDay)
+}
+
+// Feeds returns the report's feed IDs in order
+func (r *UsageReport) Feeds() []string {
+	return sortedKeys(r.ByFeed)
+}
+
+func sortedKeys(m map[string]UsageTotals) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// prune drops days older than the retention window
+func (l *UsageLedger) prune(now time.Time) {
+	cutoff := now.Add(-usageRetention).UTC().Format(usageDayFormat)
+	for day := range l.days {
+		if day < cutoff {
+			delete(l.days, day)
+		}
+	}
+}
+
+// save writes the usage file atomically. Caller must hold the lock.
+func (l *UsageLedger) save() error {
+	if l.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(l.days)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create usage directory: %w", err)
+	}
+
+	// Write to a temporary file and rename so a crash never leaves a truncated usage file
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary usage file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write usage file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write usage file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return fmt.Errorf("failed to replace usage file: %w", err)
+	}
+
+	return nil
+}
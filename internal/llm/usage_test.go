@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"strandnerd-crawler/internal/models"
+)
+
+func TestUsageLedgerReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.usage.json")
+	ledger, err := NewUsageLedger(path)
+	if err != nil {
+		t.Fatalf("NewUsageLedger failed: %v", err)
+	}
+
+	now := time.Now().UTC()
+	day1 := time.Date(now.Year(), now.Month(), 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	ledger.Record(day1, "feed-a", Usage{PromptTokens: 1000, CompletionTokens: 100}, 0.5)
+	ledger.Record(day1, "feed-b", Usage{PromptTokens: 2000, CompletionTokens: 200}, 1.0)
+	ledger.Record(day2, "feed-a", Usage{PromptTokens: 1000, CompletionTokens: 100}, 0.5)
+	ledger.Record(day1.AddDate(0, 1, 0), "feed-a", Usage{PromptTokens: 5}, 9)
+	if err := ledger.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	reopened, err := NewUsageLedger(path)
+	if err != nil {
+		t.Fatalf("NewUsageLedger failed: %v", err)
+	}
+	report := reopened.Report(day2)
+
+	if report.Month != day1.Format("2006-01") || report.Total.Calls != 3 || report.Total.PromptTokens != 4000 || report.Total.CostUSD != 2.0 {
+		t.Errorf("Unexpected month total: %+v", report)
+	}
+	if feedA := report.ByFeed["feed-a"]; feedA.Calls != 2 || feedA.CompletionTokens != 200 || feedA.CostUSD != 1.0 {
+		t.Errorf("Unexpected feed totals: %+v", feedA)
+	}
+	if days := report.Days(); len(days) != 2 || days[0] != day1.Format("2006-01-02") || report.ByDay[days[0]].CostUSD != 1.5 {
+		t.Errorf("Unexpected daily totals: %v %+v", days, report.ByDay)
+	}
+}
+
+func TestUsageLedgerWritesOnFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.usage.json")
+	ledger, err := NewUsageLedger(path)
+	if err != nil {
+		t.Fatalf("NewUsageLedger failed: %v", err)
+	}
+
+	now := time.Now()
+	ledger.Record(now, "feed-a", Usage{PromptTokens: 100}, 0.1)
+	ledger.Record(now.Add(-2*usageRetention), "feed-a", Usage{PromptTokens: 100}, 0.1)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected no usage file before Flush, got %v", err)
+	}
+
+	if err := ledger.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected the usage file after Flush: %v", err)
+	}
+	if old := now.Add(-2 * usageRetention).UTC().Format(usageDayFormat); strings.Contains(string(data), old) {
+		t.Errorf("Expected the day past the retention window to be pruned on Flush, got %s", data)
+	}
+}
+
+func TestPriceFor(t *testing.T) {
+	price, ok := priceFor(defaultPrices, "gpt-4o-mini-2024-07-18")
+	if !ok || price != defaultPrices["gpt-4o-mini"] {
+		t.Errorf("Expected the gpt-4o-mini price for a dated version, got %+v", price)
+	}
+	if _, ok := priceFor(defaultPrices, "llama3.1"); ok {
+		t.Error("Expected no price for an unknown model")
+	}
+
+	cost := ModelPrice{Input: 0.15, Output: 0.60}.Cost(Usage{PromptTokens: 1_000_000, CompletionTokens: 500_000})
+	if math.Abs(cost-0.45) > 1e-9 {
+		t.Errorf("Expected a cost of $0.45, got %v", cost)
+	}
+}
+
+func TestClientRecordsUsageAndCost(t *testing.T) {
+	ledger, _ := NewUsageLedger("")
+	fake := &FakeBackend{}
+	client := NewClientWithBackend(fake, Config{
+		Provider: ProviderFake,
+		Usage:    ledger,
+		Prices:   map[string]ModelPrice{"fake": {Input: 1, Output: 2}},
+	})
+
+	req := testAnalysisRequest()
+	req.FeedID = "feed-a"
+	if _, err := client.AnalyzeContent(context.Background(), req); err != nil {
+		t.Fatalf("AnalyzeContent failed: %v", err)
+	}
+
+	totals := ledger.Report(time.Now()).ByFeed["feed-a"]
+	if totals.Calls != 1 || totals.PromptTokens == 0 || totals.CompletionTokens == 0 {
+		t.Fatalf("Expected the call to be recorded for the feed, got %+v", totals)
+	}
+	if want := float64(totals.PromptTokens+2*totals.CompletionTokens) / 1e6; math.Abs(totals.CostUSD-want) > 1e-12 {
+		t.Errorf("Expected a cost of %v, got %v", want, totals.CostUSD)
+	}
+}
+
+func TestMonthlyBudgetSwitchesToRules(t *testing.T) {
+	ledger, _ := NewUsageLedger("")
+	ledger.Record(time.Now(), "feed-a", Usage{PromptTokens: 1000}, 5)
+
+	fake := &FakeBackend{}
+	client := NewClientWithBackend(fake, Config{Provider: ProviderFake, Usage: ledger, MonthlyBudget: 5})
+
+	result, err := client.AnalyzeContent(context.Background(), testAnalysisRequest())
+	if err != nil {
+		t.Fatalf("AnalyzeContent failed: %v", err)
+	}
+	if len(fake.Requests()) != 0 || !result.IsPrimaryReporting || result.Confidence != 0.1 {
+		t.Errorf("Expected no model call over budget, got %d requests and %+v", len(fake.Requests()), result)
+	}
+
+	// The free rule-based analysis keeps working
+	content := "According to Reuters, the company announced record profits on Monday after a strong quarter."
+	result, _ = client.AnalyzeContent(context.Background(), &models.ContentAnalysisRequest{Title: "Record profits", Content: &content})
+	if result.IsPrimaryReporting || result.OriginalSourceName == nil || *result.OriginalSourceName != "Reuters" {
+		t.Errorf("Expected the rule-based result over budget, got %+v", result)
+	}
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // InspirationFeed represents an inspiration feed from the CMS
 type InspirationFeed struct {
@@ -16,8 +19,17 @@ type InspirationFeed struct {
 	UpdatedAt            string    `json:"updated_at"`
 	Category             *Category `json:"category,omitempty"`
 	PostCount            int       `json:"post_count,omitempty"`
+	FeedType             string    `json:"feed_type,omitempty"`     // see FeedType constants, empty for feeds
+	LinkSelector         *string   `json:"link_selector,omitempty"` // CSS selector for article links on HTML listing pages
 }
 
+// Feed types, telling the crawler how to read an inspiration feed's URL
+const (
+	FeedTypeRSS     = "rss"     // RSS, Atom, RDF or JSON Feed document, the default
+	FeedTypeHTML    = "html"    // HTML listing page whose article links are matched by LinkSelector
+	FeedTypeSitemap = "sitemap" // sitemap.xml, sitemap index or Google News sitemap
+)
+
 // Category represents a simplified category for the inspiration feeds
 type Category struct {
 	ID   string `json:"id"`
@@ -48,18 +60,22 @@ type InspirationFeedPost struct {
 
 // CreateInspirationFeedPostRequest represents the request to create a new inspiration feed post
 type CreateInspirationFeedPostRequest struct {
-	InspirationFeedID  string  `json:"inspiration_feed_id" binding:"required"`
-	Title              string  `json:"title" binding:"required"`
-	Description        *string `json:"description"`
-	Content            *string `json:"content"`
-	URL                string  `json:"url" binding:"required"`
-	Author             *string `json:"author"`
-	PublishedAt        *string `json:"published_at"`
-	GUID               *string `json:"guid"`
-	ImageURL           *string `json:"image_url"`
-	FullContent        *string `json:"full_content"`
-	IsPrimaryReporting *bool   `json:"is_primary_reporting"`
-	OriginalSourceName *string `json:"original_source_name"`
+	InspirationFeedID  string         `json:"inspiration_feed_id" binding:"required"`
+	Title              string         `json:"title" binding:"required"`
+	Description        *string        `json:"description"`
+	Content            *string        `json:"content"`
+	URL                string         `json:"url" binding:"required"`
+	Author             *string        `json:"author"`
+	PublishedAt        *string        `json:"published_at"`
+	GUID               *string        `json:"guid"`
+	ImageURL           *string        `json:"image_url"`
+	FullContent        *string        `json:"full_content"`
+	IsPrimaryReporting *bool          `json:"is_primary_reporting"`
+	OriginalSourceName *string        `json:"original_source_name"`
+	Categories         []string       `json:"categories,omitempty"`
+	Media              []MediaContent `json:"media,omitempty"`
+	Language           *string        `json:"language,omitempty"`      // BCP 47 tag declared by the article page
+	CanonicalURL       *string        `json:"canonical_url,omitempty"` // canonical URL declared by the article page
 }
 
 // RSS parsing types
@@ -70,17 +86,20 @@ type RSSFeed struct {
 	Items       []RSSItem `xml:"item"`
 }
 
+// RSSItem is a single feed item. RSS items are decoded namespace-aware by UnmarshalXML,
+// Atom entries are converted into the same shape by the parser.
 type RSSItem struct {
-	Title          string        `xml:"title"`
-	Description    string        `xml:"description"`
-	Content        string        `xml:"content"`
-	Link           string        `xml:"link"`
-	Author         string        `xml:"author"`
-	PubDate        string        `xml:"pubDate"`
-	GUID           string        `xml:"guid"`
-	Enclosure      *Enclosure    `xml:"enclosure"`
-	MediaThumbnail *MediaContent `xml:"media:thumbnail"`
-	MediaContent   *MediaContent `xml:"media:content"`
+	Title       string
+	Description string
+	Content     string // content:encoded
+	Link        string
+	Author      string
+	Creator     string // dc:creator, falling back to itunes:author
+	PubDate     string // pubDate, falling back to dc:date
+	GUID        string
+	Categories  []string       // category, dc:subject, media:category and media/itunes keywords
+	Enclosure   *Enclosure
+	Media       []MediaContent // media:content and media:thumbnail, including media:group members, and itunes:image
 }
 
 type Enclosure struct {
@@ -88,8 +107,14 @@ type Enclosure struct {
 	Type string `xml:"type,attr"`
 }
 
+// MediaContent is a media item attached to a feed item
 type MediaContent struct {
-	URL string `xml:"url,attr"`
+	URL       string `json:"url"`
+	Type      string `json:"type,omitempty"`   // MIME type
+	Medium    string `json:"medium,omitempty"` // image, video, audio, document or executable
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	Thumbnail bool   `json:"thumbnail,omitempty"`
 }
 
 // RSS Channel wrapper
@@ -136,14 +161,130 @@ type AtomCategory struct {
 	Term string `xml:"term,attr"`
 }
 
+// RDFFeed is an RSS 1.0 document, where items are siblings of the channel
+type RDFFeed struct {
+	Channel RSSFeed   `xml:"channel"`
+	Items   []RSSItem `xml:"item"`
+}
+
+// JSON Feed 1.0/1.1 types (https://www.jsonfeed.org/version/1.1/)
+type JSONFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	Description string           `json:"description"`
+	Author      *JSONFeedAuthor  `json:"author"`  // 1.0
+	Authors     []JSONFeedAuthor `json:"authors"` // 1.1
+	Items       []JSONFeedItem   `json:"items"`
+}
+
+type JSONFeedItem struct {
+	ID            json.RawMessage      `json:"id"` // a string by spec, but some feeds publish numbers
+	URL           string               `json:"url"`
+	ExternalURL   string               `json:"external_url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	Summary       string               `json:"summary"`
+	Image         string               `json:"image"`
+	BannerImage   string               `json:"banner_image"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Author        *JSONFeedAuthor      `json:"author"`  // 1.0
+	Authors       []JSONFeedAuthor     `json:"authors"` // 1.1
+	Tags          []string             `json:"tags"`
+	Attachments   []JSONFeedAttachment `json:"attachments"`
+}
+
+type JSONFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type JSONFeedAttachment struct {
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+}
+
+// Sitemap types (https://www.sitemaps.org/protocol.html) with the Google News and image extensions
+type SitemapURLSet struct {
+	URLs []SitemapURL `xml:"url"`
+}
+
+type SitemapURL struct {
+	Loc     string         `xml:"loc"`
+	LastMod string         `xml:"lastmod"`
+	News    *SitemapNews   `xml:"http://www.google.com/schemas/sitemap-news/0.9 news"`
+	Images  []SitemapImage `xml:"http://www.google.com/schemas/sitemap-image/1.1 image"`
+}
+
+type SitemapNews struct {
+	Title           string `xml:"title"`
+	PublicationDate string `xml:"publication_date"`
+	Keywords        string `xml:"keywords"`
+}
+
+type SitemapImage struct {
+	Loc string `xml:"loc"`
+}
+
+type SitemapIndex struct {
+	Sitemaps []SitemapRef `xml:"sitemap"`
+}
+
+type SitemapRef struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// SiteRule tells the content extractor how to read the article pages of one or more sites.
+// Each field lists CSS selectors tried in order; rules come from the built-in set, the rules
+// directory or the CMS.
+type SiteRule struct {
+	Name     string   `json:"name" yaml:"name"`
+	Domains  []string `json:"domains" yaml:"domains"`                         // host names, their subdomains match too
+	Content  []string `json:"content,omitempty" yaml:"content,omitempty"`     // article body
+	Remove   []string `json:"remove,omitempty" yaml:"remove,omitempty"`       // elements dropped from the page before extraction
+	Image    []string `json:"image,omitempty" yaml:"image,omitempty"`         // lead image, an img, meta or element containing an img
+	Date     []string `json:"date,omitempty" yaml:"date,omitempty"`           // publish date, a time, meta or text element
+	Author   []string `json:"author,omitempty" yaml:"author,omitempty"`       // author name, a meta or text element
+	TestURLs []string `json:"test_urls,omitempty" yaml:"test_urls,omitempty"` // article pages the rule is validated against
+}
+
 // CrawlResult represents the result of crawling a feed
 type CrawlResult struct {
-	FeedID       string
-	Success      bool
-	Error        error
-	PostsFound   int
-	PostsAdded   int
-	PostsSkipped int
+	FeedID        string
+	Success       bool
+	Error         error
+	PostsFound    int
+	PostsAdded    int
+	PostsSkipped  int
+	PostsDeferred int  // New posts left for a later crawl because the tenant's per-crawl cap was reached
+	NotModified   bool      // Feed answered 304 Not Modified, nothing was fetched
+	BackedOff     bool      // Feed was not crawled because it is backing off after failures
+	RetryAfter    time.Time // Earliest time a failing feed may be crawled again, zero when healthy
+}
+
+// Feed crawl status values reported to the CMS
+const (
+	FeedStatusOK      = "ok"
+	FeedStatusFailing = "failing"
+	FeedStatusParked  = "parked"
+)
+
+// FeedCrawlStatus reports a feed's crawl health to the CMS
+type FeedCrawlStatus struct {
+	Status              string  `json:"status"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+	LastError           *string `json:"last_error"`
+	LastFailureAt       *string `json:"last_failure_at"`
+	NextRetryAt         *string `json:"next_retry_at"`
+}
+
+// UpdateFeedURLRequest reports the feed URL discovered on the web page a feed was configured with
+type UpdateFeedURLRequest struct {
+	URL         string `json:"url"`
+	PreviousURL string `json:"previous_url"`
 }
 
 // IsDue checks if a feed is due for crawling based on its interval and last crawled time
@@ -172,6 +313,7 @@ type ContentAnalysisRequest struct {
 	Content     *string `json:"content"`
 	FullContent *string `json:"full_content"`
 	URL         string  `json:"url"`
+	FeedID      string  `json:"feed_id,omitempty"` // attributes the analysis cost to the feed
 }
 
 // ContentAnalysisResponse represents the response from GPT content analysis
@@ -0,0 +1,250 @@
+package models
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// Namespace URIs of the RSS modules understood by RSSItem, keyed without trailing slash and
+// lowercased, mapped to their conventional prefix
+var rssNamespaces = map[string]string{
+	"http://purl.org/rss/1.0":                    "", // RSS 1.0 core elements are treated like RSS 2.0 ones
+	"http://purl.org/rss/1.0/modules/content":    "content",
+	"http://purl.org/dc/elements/1.1":            "dc",
+	"http://search.yahoo.com/mrss":               "media",
+	"http://www.itunes.com/dtds/podcast-1.0.dtd": "itunes",
+}
+
+// namespacePrefix returns the conventional prefix for a namespace URI. encoding/xml leaves
+// undeclared prefixes in Name.Space as is, so those are returned unchanged.
+func namespacePrefix(space string) string {
+	if space == "" {
+		return ""
+	}
+	if prefix, ok := rssNamespaces[strings.TrimSuffix(strings.ToLower(space), "/")]; ok {
+		return prefix
+	}
+	return space
+}
+
+// UnmarshalXML decodes an RSS <item>. Elements are matched by namespace as well as local name,
+// so media:title or itunes:title never overwrite the item's title.
+func (item *RSSItem) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	dec := &rssItemDecoder{item: item}
+	if err := dec.decodeChildren(d, dec.decodeElement); err != nil {
+		return err
+	}
+
+	// RSS 1.0 items identify themselves with rdf:about instead of a guid
+	if item.GUID == "" {
+		item.GUID = attrValue(start, "about")
+	}
+
+	// Module fields only fill in what the core elements left empty
+	if item.Creator == "" {
+		item.Creator = dec.itunesAuthor
+	}
+	if item.PubDate == "" {
+		item.PubDate = dec.dcDate
+	}
+	if item.Description == "" {
+		item.Description = dec.itunesSummary
+	}
+
+	return nil
+}
+
+// rssItemDecoder holds the fallback values collected while decoding an item
+type rssItemDecoder struct {
+	item          *RSSItem
+	itunesAuthor  string
+	itunesSummary string
+	dcDate        string
+}
+
+// decodeChildren calls decode for each child element until the end of the enclosing element
+func (dec *rssItemDecoder) decodeChildren(d *xml.Decoder, decode func(*xml.Decoder, xml.StartElement) error) error {
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if err := decode(d, t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// decodeElement decodes a single child element of an item
+func (dec *rssItemDecoder) decodeElement(d *xml.Decoder, el xml.StartElement) error {
+	item := dec.item
+	var text string
+
+	switch namespacePrefix(el.Name.Space) + ":" + el.Name.Local {
+	case ":title":
+		return decodeText(d, &item.Title)
+	case ":link":
+		return decodeText(d, &item.Link)
+	case ":description":
+		return decodeText(d, &item.Description)
+	case ":author":
+		return decodeText(d, &item.Author)
+	case ":pubDate":
+		return decodeText(d, &item.PubDate)
+	case ":guid":
+		return decodeText(d, &item.GUID)
+	case ":enclosure":
+		item.Enclosure = &Enclosure{URL: attrValue(el, "url"), Type: attrValue(el, "type")}
+		return d.Skip()
+	case ":category", "dc:subject":
+		if err := decodeText(d, &text); err != nil {
+			return err
+		}
+		item.Categories = append(item.Categories, text)
+
+	case "content:encoded":
+		return decodeText(d, &item.Content)
+
+	case "dc:creator":
+		if err := decodeText(d, &text); err != nil {
+			return err
+		}
+		// dc:creator repeats for articles with several authors
+		if text = strings.TrimSpace(text); text != "" {
+			if item.Creator != "" {
+				text = item.Creator + ", " + text
+			}
+			item.Creator = text
+		}
+	case "dc:date":
+		return decodeText(d, &dec.dcDate)
+
+	case "media:content", "media:thumbnail", "media:group", "media:category", "media:keywords":
+		return dec.decodeMedia(d, el)
+
+	case "itunes:author":
+		return decodeText(d, &dec.itunesAuthor)
+	case "itunes:summary":
+		return decodeText(d, &dec.itunesSummary)
+	case "itunes:keywords":
+		if err := decodeText(d, &text); err != nil {
+			return err
+		}
+		item.Categories = append(item.Categories, strings.Split(text, ",")...)
+	case "itunes:category":
+		if text := attrValue(el, "text"); text != "" {
+			item.Categories = append(item.Categories, text)
+		}
+		return d.Skip()
+	case "itunes:image":
+		if href := attrValue(el, "href"); href != "" {
+			item.Media = append(item.Media, MediaContent{URL: href, Medium: "image"})
+		}
+		return d.Skip()
+
+	default:
+		return d.Skip()
+	}
+
+	return nil
+}
+
+// decodeMedia decodes a Media RSS element. media:group and media:content may nest further
+// media elements, such as the thumbnails of a video.
+func (dec *rssItemDecoder) decodeMedia(d *xml.Decoder, el xml.StartElement) error {
+	item := dec.item
+	var text string
+
+	switch namespacePrefix(el.Name.Space) + ":" + el.Name.Local {
+	case "media:content":
+		item.Media = append(item.Media, mediaFromElement(el, false))
+		return dec.decodeChildren(d, dec.decodeMedia)
+	case "media:thumbnail":
+		item.Media = append(item.Media, mediaFromElement(el, true))
+		return d.Skip()
+	case "media:group":
+		return dec.decodeChildren(d, dec.decodeMedia)
+	case "media:category":
+		if err := decodeText(d, &text); err != nil {
+			return err
+		}
+		item.Categories = append(item.Categories, text)
+	case "media:keywords":
+		if err := decodeText(d, &text); err != nil {
+			return err
+		}
+		item.Categories = append(item.Categories, strings.Split(text, ",")...)
+	default:
+		return d.Skip()
+	}
+
+	return nil
+}
+
+// decodeText reads the character data of the current element, including that of nested elements.
+// HTML pasted unescaped into a feed then still yields its text, with line breaks between blocks.
+func decodeText(d *xml.Decoder, text *string) error {
+	var buf strings.Builder
+	for depth := 0; ; {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.CharData:
+			buf.Write(t)
+		case xml.StartElement:
+			depth++
+			if blockElements[strings.ToLower(t.Name.Local)] {
+				buf.WriteString("\n")
+			}
+		case xml.EndElement:
+			if depth == 0 {
+				*text = buf.String()
+				return nil
+			}
+			depth--
+		}
+	}
+}
+
+// blockElements are HTML elements that start a new line when unescaped HTML is read as text
+var blockElements = map[string]bool{
+	"br": true, "p": true, "div": true, "hr": true, "li": true, "tr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// mediaFromElement reads a media:content or media:thumbnail element's attributes.
+// Invalid dimensions are ignored rather than failing the whole feed.
+func mediaFromElement(el xml.StartElement, thumbnail bool) MediaContent {
+	media := MediaContent{
+		URL:       attrValue(el, "url"),
+		Type:      attrValue(el, "type"),
+		Medium:    attrValue(el, "medium"),
+		Thumbnail: thumbnail,
+	}
+	media.Width, _ = strconv.Atoi(attrValue(el, "width"))
+	media.Height, _ = strconv.Atoi(attrValue(el, "height"))
+	if thumbnail && media.Medium == "" {
+		media.Medium = "image"
+	}
+	return media
+}
+
+// attrValue returns the value of the element's attribute with the given local name
+func attrValue(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return strings.TrimSpace(a.Value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// xmlEncodingPattern finds the encoding declared in an XML prolog
+var xmlEncodingPattern = regexp.MustCompile(`^<\?xml[^>]*?encoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// windows1252High maps bytes 0x80-0x9F of windows-1252 to Unicode. The rest of the
+// code page matches ISO-8859-1, which in turn matches the first 256 code points.
+var windows1252High = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// iso885915Differences lists where ISO-8859-15 deviates from ISO-8859-1
+var iso885915Differences = map[byte]rune{
+	0xA4: '€', 0xA6: 'Š', 0xA8: 'š', 0xB4: 'Ž', 0xB8: 'ž', 0xBC: 'Œ', 0xBD: 'œ', 0xBE: 'Ÿ',
+}
+
+// detectCharset determines a feed's character encoding from, in order of precedence,
+// a byte order mark, the Content-Type header and the XML prolog. Defaults to UTF-8.
+func detectCharset(contentType string, body []byte) string {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return "utf-8"
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	}
+
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		return normalizeCharset(params["charset"])
+	}
+
+	if match := xmlEncodingPattern.FindSubmatch(bytes.TrimLeft(body, " \t\r\n")); match != nil {
+		return normalizeCharset(string(match[1]))
+	}
+
+	return "utf-8"
+}
+
+// normalizeCharset maps charset labels to the decoders decodeCharset knows. Like browsers,
+// ISO-8859-1 and ASCII are read as windows-1252, which only adds characters in 0x80-0x9F.
+func normalizeCharset(label string) string {
+	switch strings.ToLower(strings.Trim(strings.TrimSpace(label), `"'`)) {
+	case "utf-8", "utf8", "unicode-1-1-utf-8":
+		return "utf-8"
+	case "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "latin-1", "l1", "cp819",
+		"us-ascii", "ascii", "windows-1252", "cp1252", "x-cp1252":
+		return "windows-1252"
+	case "iso-8859-15", "iso8859-15", "iso_8859-15", "latin-9", "latin9", "l9":
+		return "iso-8859-15"
+	case "utf-16", "utf16":
+		return "utf-16"
+	case "utf-16le":
+		return "utf-16le"
+	case "utf-16be":
+		return "utf-16be"
+	default:
+		return "unknown"
+	}
+}
+
+// decodeCharset converts a feed body to UTF-8. UTF-8 bodies containing invalid bytes, and bodies
+// in charsets without a decoder, are repaired by reading the invalid bytes as windows-1252, which
+// is what mislabelled feeds almost always are.
+func decodeCharset(contentType string, body []byte) []byte {
+	switch charset := detectCharset(contentType, body); charset {
+	case "windows-1252":
+		return decodeSingleByte(body, func(b byte) rune { return windows1252Rune(b) })
+	case "iso-8859-15":
+		return decodeSingleByte(body, func(b byte) rune {
+			if r, ok := iso885915Differences[b]; ok {
+				return r
+			}
+			return rune(b)
+		})
+	case "utf-16", "utf-16le", "utf-16be":
+		return decodeUTF16(body, charset)
+	default:
+		body = bytes.TrimPrefix(body, []byte{0xEF, 0xBB, 0xBF})
+		if utf8.Valid(body) {
+			return body
+		}
+		return repairUTF8(body)
+	}
+}
+
+// windows1252Rune decodes a single windows-1252 byte
+func windows1252Rune(b byte) rune {
+	if b >= 0x80 && b <= 0x9F {
+		return windows1252High[b-0x80]
+	}
+	return rune(b)
+}
+
+// decodeSingleByte decodes a body in a single-byte charset
+func decodeSingleByte(body []byte, decode func(byte) rune) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(body) + len(body)/8)
+	for _, b := range body {
+		if b < utf8.RuneSelf {
+			buf.WriteByte(b)
+			continue
+		}
+		buf.WriteRune(decode(b))
+	}
+	return buf.Bytes()
+}
+
+// repairUTF8 keeps valid UTF-8 sequences and reads every invalid byte as windows-1252
+func repairUTF8(body []byte) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(body) + len(body)/8)
+	for len(body) > 0 {
+		r, size := utf8.DecodeRune(body)
+		if r == utf8.RuneError && size == 1 {
+			buf.WriteRune(windows1252Rune(body[0]))
+		} else {
+			buf.Write(body[:size])
+		}
+		body = body[size:]
+	}
+	return buf.Bytes()
+}
+
+// decodeUTF16 decodes a UTF-16 body, using the byte order mark when present.
+// Without one, "utf-16" is read as big endian as the standard requires.
+func decodeUTF16(body []byte, charset string) []byte {
+	var order binary.ByteOrder = binary.BigEndian
+	if charset == "utf-16le" {
+		order = binary.LittleEndian
+	}
+	switch {
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		order, body = binary.LittleEndian, body[2:]
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		order, body = binary.BigEndian, body[2:]
+	}
+
+	units := make([]uint16, len(body)/2)
+	for i := range units {
+		units[i] = order.Uint16(body[2*i:])
+	}
+
+	var buf bytes.Buffer
+	for _, r := range utf16.Decode(units) {
+		buf.WriteRune(r)
+	}
+	return buf.Bytes()
+}
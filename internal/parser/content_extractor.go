@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -16,7 +17,7 @@ type ContentExtractor struct {
 	client      *http.Client
 	userAgent   string
 	htmlCleaner *HTMLCleaner
-	platformSelectors map[string][]string
+	siteRules   *SiteRules
 }
 
 func NewContentExtractor(client *http.Client, config *config.Config) *ContentExtractor {
@@ -24,194 +25,72 @@ func NewContentExtractor(client *http.Client, config *config.Config) *ContentExt
 		client:      client,
 		userAgent:   config.UserAgent,
 		htmlCleaner: NewHTMLCleaner(),
-		platformSelectors: initializePlatformSelectors(),
+		siteRules:   NewSiteRules(config.SiteRulesDir),
 	}
 }
 
-// initializePlatformSelectors defines content selectors for specific platforms
-func initializePlatformSelectors() map[string][]string {
-	return map[string][]string{
-		// TechCrunch
-		"techcrunch.com": {
-			".wp-block-post-content",
-			".article-content",
-			"[data-module='ArticleBody']",
-			".post-content",
-		},
-		// Medium
-		"medium.com": {
-			"article section",
-			"[data-testid='storyContent']",
-			".story-content",
-			"article div[data-selectable-paragraph]",
-		},
-		// The Verge
-		"theverge.com": {
-			".duet--article--article-body",
-			"[data-testid='ArticleBodyWrapper']",
-			".c-entry-content",
-			".l-article-content",
-		},
-		// Ars Technica
-		"arstechnica.com": {
-			".post-content",
-			"[itemprop='articleBody']",
-			".article-content",
-		},
-		// Wired
-		"wired.com": {
-			"[data-testid='BodyWrapper']",
-			".article__chunks",
-			".content-header + div",
-			"[data-testid='ArticleBodyWrapper']",
-		},
-		// Engadget
-		"engadget.com": {
-			"[data-module='ArticleBody']",
-			".article-text",
-			".o-article_body",
-		},
-		// TechRadar
-		"techradar.com": {
-			"[data-testid='article-body']",
-			"#article-body",
-			".text-copy",
-		},
-		// ZDNet
-		"zdnet.com": {
-			".storyBody",
-			"[data-module='ArticleBody']",
-			".content",
-		},
-		// BBC News
-		"bbc.com": {
-			"[data-component='text-block']",
-			".story-body__inner",
-			"[data-testid='article-text']",
-		},
-		// CNN
-		"cnn.com": {
-			".zn-body__paragraph",
-			"[data-testid='article-content']",
-			".l-container",
-		},
-		// Reuters
-		"reuters.com": {
-			"[data-testid='paragraph']",
-			".ArticleBodyWrapper",
-			".StandardArticleBody",
-		},
-		// The Guardian
-		"theguardian.com": {
-			"[data-gu-name='body']",
-			".content__article-body",
-			"#maincontent",
-		},
-		// New York Times
-		"nytimes.com": {
-			"section[name='articleBody']",
-			".StoryBodyCompanionColumn",
-			"[data-testid='articleBody']",
-		},
-		// Washington Post
-		"washingtonpost.com": {
-			"[data-testid='article-body']",
-			".article-body",
-			"#article-body",
-		},
-		// Wall Street Journal
-		"wsj.com": {
-			"[data-module='ArticleBody']",
-			".wsj-snippet-body",
-			".article-content",
-		},
-		// Forbes
-		"forbes.com": {
-			".article-body",
-			"[data-testid='article-body']",
-			".body-container",
-		},
-		// Hacker News (YCombinator)
-		"news.ycombinator.com": {
-			".comment",
-			".commtext",
-		},
-		// Reddit (for posts that might be crawled)
-		"reddit.com": {
-			"[data-testid='post-content']",
-			".md",
-			"[data-click-id='text']",
-		},
-		// GitHub Blog
-		"github.blog": {
-			".post-content",
-			"[data-testid='article-body']",
-			".markdown-body",
-		},
-		// Stack Overflow Blog
-		"stackoverflow.blog": {
-			".s-prose",
-			".post-content",
-			"[itemprop='text']",
-		},
-		// Dev.to
-		"dev.to": {
-			"[data-article-id] .crayons-article__body",
-			".article-body",
-			"#article-body",
-		},
-		// Substack
-		"substack.com": {
-			".markup",
-			"[data-testid='post-content']",
-			".post-content",
-		},
-		// Blogger/Blogspot
-		"blogspot.com": {
-			".post-body",
-			".entry-content",
-			"[itemprop='articleBody']",
-		},
-		// WordPress.com
-		"wordpress.com": {
-			".entry-content",
-			".post-content",
-			"[data-testid='post-content']",
-		},
-		// Mashable
-		"mashable.com": {
-			"[data-testid='article-body']",
-			".article-content",
-			".blueprint",
-		},
-		// VentureBeat
-		"venturebeat.com": {
-			".article-content",
-			"[data-module='ArticleBody']",
-			".the-content",
-		},
-		// 9to5Mac, 9to5Google, etc.
-		"9to5mac.com": {
-			".post-content",
-			"[data-testid='post-content']",
-			".entry-content",
-		},
-		"9to5google.com": {
-			".post-content",
-			"[data-testid='post-content']",
-			".entry-content",
-		},
-	}
+// SiteRules returns the site extraction rules used by the extractor
+func (ce *ContentExtractor) SiteRules() *SiteRules {
+	return ce.siteRules
 }
 
 type ExtractedContent struct {
 	ImageURL    string
 	FullContent string
+	PublishedAt string // publish date as found on the page, see ParseDate
+	Title       string // Open Graph, JSON-LD headline, Twitter card or document title
+	Author      string // author matched by the site rule, otherwise the page's declared authors
+	Metadata    *PageMetadata
 }
 
-// ExtractContentFromURL fetches the page and extracts the main content and image
-func (ce *ContentExtractor) ExtractContentFromURL(pageURL string) (*ExtractedContent, error) {
-	req, err := http.NewRequest("GET", pageURL, nil)
+// ExtractContentFromURL fetches the page and extracts the main content and image.
+// The selectors of the site's extraction rule take priority over the generic heuristics.
+func (ce *ContentExtractor) ExtractContentFromURL(ctx context.Context, pageURL string) (*ExtractedContent, error) {
+	doc, err := ce.fetchPage(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	extracted := &ExtractedContent{}
+
+	// Read the page's declared metadata before site rules remove any elements
+	extracted.Metadata = ce.extractMetadata(doc, pageURL)
+
+	rule := ce.siteRules.Lookup(pageURL)
+	if rule != nil {
+		ce.removeElements(doc, rule.Remove)
+		extracted.ImageURL = ce.selectRuleImage(doc, rule.Image, pageURL)
+		extracted.PublishedAt = ce.selectRuleValue(doc, rule.Date, "datetime", "content")
+		extracted.Author = ce.selectRuleValue(doc, rule.Author, "content")
+	}
+
+	// Open Graph, Twitter card or JSON-LD image (priority), then the first article image
+	if extracted.ImageURL == "" {
+		extracted.ImageURL = extracted.Metadata.ImageURL
+	}
+	if extracted.ImageURL == "" {
+		extracted.ImageURL = ce.extractMainImage(doc, pageURL)
+	}
+
+	// The publish date, title and authors fill in for feeds that do not carry them
+	if extracted.PublishedAt == "" {
+		extracted.PublishedAt = extracted.Metadata.PublishedTime
+	}
+	if extracted.Author == "" {
+		extracted.Author = strings.Join(extracted.Metadata.Authors, ", ")
+	}
+	extracted.Title = extracted.Metadata.Title
+
+	// Extract and clean main content as HTML
+	rawContent := ce.extractMainContentHTMLWithURL(doc, pageURL)
+	extracted.FullContent = ce.htmlCleaner.CleanHTML(rawContent)
+
+	return extracted, nil
+}
+
+// fetchPage fetches and parses an article page
+func (ce *ContentExtractor) fetchPage(ctx context.Context, pageURL string) (*html.Node, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -239,26 +118,15 @@ func (ce *ContentExtractor) ExtractContentFromURL(pageURL string) (*ExtractedCon
 		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
-	extracted := &ExtractedContent{}
-
-	// Extract Open Graph image (priority)
-	extracted.ImageURL = ce.extractMainImage(doc, pageURL)
-
-	// Extract and clean main content as HTML
-	rawContent := ce.extractMainContentHTMLWithURL(doc, pageURL)
-	extracted.FullContent = ce.htmlCleaner.CleanHTML(rawContent)
-
-	return extracted, nil
+	return doc, nil
 }
 
-// extractMainImage attempts to find the main image for the article
+// extractMainImage finds the first image of the article for pages without a metadata image
 func (ce *ContentExtractor) extractMainImage(doc *html.Node, baseURL string) string {
 	var imageURL string
 
-	// Priority order: Open Graph image, Twitter card image, then fallback to content images
+	// Priority order: images in the article's likely containers
 	selectors := []func(*html.Node) string{
-		func(n *html.Node) string { return ce.findMetaProperty(n, "og:image") },
-		func(n *html.Node) string { return ce.findMetaProperty(n, "twitter:image") },
 		func(n *html.Node) string { return ce.findFirstImageInElement(n, "article") },
 		func(n *html.Node) string { return ce.findFirstImageInElement(n, "main") },
 		func(n *html.Node) string { return ce.findFirstImageInElement(n, ".content") },
@@ -344,8 +212,8 @@ func (ce *ContentExtractor) extractMainContentHTML(doc *html.Node) string {
 		}
 	}
 
-	// Last resort: try to find the largest content block
-	return ce.extractLargestContentBlock(doc)
+	// Last resort: score the document for the block that reads most like an article
+	return ce.extractReadableContent(doc)
 }
 
 // extractBaseURL extracts the base URL from HTML document
@@ -387,39 +255,11 @@ func (ce *ContentExtractor) extractBaseURL(doc *html.Node) string {
 	return f(doc)
 }
 
-// getPlatformSelectors returns platform-specific selectors for a URL
+// getPlatformSelectors returns the content selectors of the site rule for a URL
 func (ce *ContentExtractor) getPlatformSelectors(urlStr string) []string {
-	if urlStr == "" {
-		return nil
-	}
-	
-	parsedURL, err := url.Parse(urlStr)
-	if err != nil {
-		return nil
-	}
-	
-	hostname := strings.ToLower(parsedURL.Hostname())
-	
-	// Direct hostname match
-	if selectors, exists := ce.platformSelectors[hostname]; exists {
-		return selectors
-	}
-	
-	// Try removing www. prefix
-	if strings.HasPrefix(hostname, "www.") {
-		cleanHostname := strings.TrimPrefix(hostname, "www.")
-		if selectors, exists := ce.platformSelectors[cleanHostname]; exists {
-			return selectors
-		}
-	}
-	
-	// Try subdomain matching for known platforms
-	for domain, selectors := range ce.platformSelectors {
-		if strings.HasSuffix(hostname, "."+domain) || strings.HasSuffix(hostname, domain) {
-			return selectors
-		}
+	if rule := ce.siteRules.Lookup(urlStr); rule != nil && len(rule.Content) > 0 {
+		return rule.Content
 	}
-	
 	return nil
 }
 
@@ -465,34 +305,6 @@ func (ce *ContentExtractor) isGoodContent(content string) bool {
 	return true
 }
 
-// findMetaProperty finds meta tag with specific property
-func (ce *ContentExtractor) findMetaProperty(n *html.Node, property string) string {
-	var result string
-	var f func(*html.Node)
-	f = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "meta" {
-			var propertyAttr, contentAttr string
-			for _, attr := range n.Attr {
-				switch attr.Key {
-				case "property", "name":
-					propertyAttr = attr.Val
-				case "content":
-					contentAttr = attr.Val
-				}
-			}
-			if propertyAttr == property && contentAttr != "" {
-				result = contentAttr
-				return
-			}
-		}
-		for c := n.FirstChild; c != nil && result == ""; c = c.NextSibling {
-			f(c)
-		}
-	}
-	f(n)
-	return result
-}
-
 // findFirstImageInElement finds first image within a specific element
 func (ce *ContentExtractor) findFirstImageInElement(n *html.Node, elementSelector string) string {
 	element := ce.findElementBySelector(n, elementSelector)
@@ -523,191 +335,28 @@ func (ce *ContentExtractor) findFirstImage(n *html.Node) string {
 	return result
 }
 
-// findElementBySelector finds element by selector (supports tag, class, id, and attribute selectors)
+// findElementBySelector finds the first element, starting with n itself, matching a CSS selector
 func (ce *ContentExtractor) findElementBySelector(n *html.Node, selector string) *html.Node {
-	var result *html.Node
-	var f func(*html.Node)
-	f = func(n *html.Node) {
-		if n.Type == html.ElementNode {
-			if ce.matchesSelector(n, selector) {
-				result = n
-				return
-			}
-		}
-		for c := n.FirstChild; c != nil && result == nil; c = c.NextSibling {
-			f(c)
-		}
-	}
-	f(n)
-	return result
-}
-
-// matchesSelector checks if a node matches a given selector
-func (ce *ContentExtractor) matchesSelector(n *html.Node, selector string) bool {
-	selector = strings.TrimSpace(selector)
-	
-	// Handle attribute selectors like [data-testid='article-body'] or [role=main]
-	if strings.HasPrefix(selector, "[") && strings.HasSuffix(selector, "]") {
-		attrPart := strings.Trim(selector, "[]")
-		
-		// Split on = to get attribute and value
-		if strings.Contains(attrPart, "=") {
-			parts := strings.SplitN(attrPart, "=", 2)
-			attrName := strings.TrimSpace(parts[0])
-			attrValue := strings.Trim(strings.TrimSpace(parts[1]), "'\"")
-			
-			for _, attr := range n.Attr {
-				if attr.Key == attrName && attr.Val == attrValue {
-					return true
-				}
-			}
-		} else {
-			// Just check for attribute existence
-			for _, attr := range n.Attr {
-				if attr.Key == strings.TrimSpace(attrPart) {
-					return true
-				}
-			}
-		}
-		return false
-	}
-	
-	// Handle ID selector
-	if idName, ok := strings.CutPrefix(selector, "#"); ok {
-		for _, attr := range n.Attr {
-			if attr.Key == "id" && attr.Val == idName {
-				return true
-			}
-		}
-		return false
-	}
-	
-	// Handle class selector
-	if className, ok := strings.CutPrefix(selector, "."); ok {
-		for _, attr := range n.Attr {
-			if attr.Key == "class" {
-				classes := strings.Fields(attr.Val)
-				for _, cls := range classes {
-					if cls == className {
-						return true
-					}
-				}
-			}
-		}
-		return false
-	}
-	
-	// Handle space-separated selectors (like "article section")
-	if strings.Contains(selector, " ") {
-		return ce.matchesDescendantSelector(n, selector)
+	compiled := cachedSelector(selector)
+	if compiled == nil {
+		return nil
 	}
-	
-	// Handle tag name selector
-	return n.Data == selector
+	return compiled.MatchFirst(n)
 }
 
-// matchesDescendantSelector handles space-separated selectors like "article section"
-func (ce *ContentExtractor) matchesDescendantSelector(n *html.Node, selector string) bool {
-	parts := strings.Fields(selector)
-	if len(parts) < 2 {
-		return false
-	}
-	
-	// Find if current node matches the last part (avoid infinite recursion)
-	lastSelector := parts[len(parts)-1]
-	if !ce.matchesSimpleSelector(n, lastSelector) {
-		return false
-	}
-	
-	// Check if any ancestor matches the previous selectors
-	if len(parts) == 2 {
-		// Simple case: just check if any ancestor matches the first selector
-		ancestorSelector := parts[0]
-		current := n.Parent
-		
-		for current != nil {
-			if current.Type == html.ElementNode {
-				if ce.matchesSimpleSelector(current, ancestorSelector) {
-					return true
-				}
-			}
-			current = current.Parent
-		}
-	} else {
-		// Complex case: check if any ancestor matches the complex ancestor selector
-		ancestorSelector := strings.Join(parts[:len(parts)-1], " ")
-		current := n.Parent
-		
-		for current != nil {
-			if current.Type == html.ElementNode {
-				if ce.matchesDescendantSelector(current, ancestorSelector) {
-					return true
-				}
-			}
-			current = current.Parent
-		}
+// findAllBySelector finds all elements matching the selector in document order
+func (ce *ContentExtractor) findAllBySelector(n *html.Node, selector string) []*html.Node {
+	compiled := cachedSelector(selector)
+	if compiled == nil {
+		return nil
 	}
-	
-	return false
+	return compiled.MatchAll(n)
 }
 
-// matchesSimpleSelector checks if a node matches a simple (non-descendant) selector
-func (ce *ContentExtractor) matchesSimpleSelector(n *html.Node, selector string) bool {
-	selector = strings.TrimSpace(selector)
-	
-	// Handle attribute selectors like [data-testid='article-body'] or [role=main]
-	if strings.HasPrefix(selector, "[") && strings.HasSuffix(selector, "]") {
-		attrPart := strings.Trim(selector, "[]")
-		
-		// Split on = to get attribute and value
-		if strings.Contains(attrPart, "=") {
-			parts := strings.SplitN(attrPart, "=", 2)
-			attrName := strings.TrimSpace(parts[0])
-			attrValue := strings.Trim(strings.TrimSpace(parts[1]), "'\"")
-			
-			for _, attr := range n.Attr {
-				if attr.Key == attrName && attr.Val == attrValue {
-					return true
-				}
-			}
-		} else {
-			// Just check for attribute existence
-			for _, attr := range n.Attr {
-				if attr.Key == strings.TrimSpace(attrPart) {
-					return true
-				}
-			}
-		}
-		return false
-	}
-	
-	// Handle ID selector
-	if idName, ok := strings.CutPrefix(selector, "#"); ok {
-		for _, attr := range n.Attr {
-			if attr.Key == "id" && attr.Val == idName {
-				return true
-			}
-		}
-		return false
-	}
-	
-	// Handle class selector
-	if className, ok := strings.CutPrefix(selector, "."); ok {
-		for _, attr := range n.Attr {
-			if attr.Key == "class" {
-				classes := strings.Fields(attr.Val)
-				for _, cls := range classes {
-					if cls == className {
-						return true
-					}
-				}
-			}
-		}
-		return false
-	}
-	
-	// Handle tag name selector
-	return n.Data == selector
+// matchesSelector checks if a node matches a given CSS selector
+func (ce *ContentExtractor) matchesSelector(n *html.Node, selector string) bool {
+	compiled := cachedSelector(strings.TrimSpace(selector))
+	return compiled != nil && compiled.Match(n)
 }
 
 // extractHTMLFromSelector extracts HTML content from element matching selector
@@ -726,29 +375,6 @@ func (ce *ContentExtractor) renderHTML(n *html.Node) string {
 	return buf.String()
 }
 
-// extractLargestContentBlock finds the element with the most content
-func (ce *ContentExtractor) extractLargestContentBlock(doc *html.Node) string {
-	var maxHTML string
-	var maxLength int
-
-	var f func(*html.Node)
-	f = func(n *html.Node) {
-		if n.Type == html.ElementNode {
-			htmlContent := ce.renderHTML(n)
-			textLength := len(strings.TrimSpace(ce.extractTextFromHTML(htmlContent)))
-			if textLength > maxLength && textLength > 100 { // Minimum content threshold
-				maxHTML = htmlContent
-				maxLength = textLength
-			}
-		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			f(c)
-		}
-	}
-	f(doc)
-	return ce.cleanHTML(maxHTML)
-}
-
 // extractTextFromHTML extracts plain text from HTML for length calculation
 func (ce *ContentExtractor) extractTextFromHTML(htmlContent string) string {
 	doc, err := html.Parse(strings.NewReader(htmlContent))
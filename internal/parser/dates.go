@@ -0,0 +1,213 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timezoneOffsets maps timezone abbreviations found in feeds to their UTC offsets. time.Parse only
+// knows the abbreviations of the local zone and silently treats all others as UTC. Ambiguous
+// abbreviations use the reading most common in English-language news feeds (IST is India).
+var timezoneOffsets = map[string]string{
+	"UT": "+0000", "UTC": "+0000", "GMT": "+0000", "Z": "+0000", "WET": "+0000",
+	"EST": "-0500", "EDT": "-0400", "CST": "-0600", "CDT": "-0500",
+	"MST": "-0700", "MDT": "-0600", "PST": "-0800", "PDT": "-0700",
+	"AKST": "-0900", "AKDT": "-0800", "HST": "-1000", "HDT": "-0900",
+	"AST": "-0400", "ADT": "-0300", "NST": "-0330", "NDT": "-0230",
+	"BRT": "-0300", "ART": "-0300", "CLT": "-0400", "CLST": "-0300",
+	"BST": "+0100", "IST": "+0530", "WEST": "+0100",
+	"CET": "+0100", "CEST": "+0200", "MET": "+0100", "MEST": "+0200",
+	"EET": "+0200", "EEST": "+0300", "MSK": "+0300", "SAST": "+0200",
+	"GST": "+0400", "PKT": "+0500", "ICT": "+0700", "WIB": "+0700",
+	"SGT": "+0800", "HKT": "+0800", "PHT": "+0800", "AWST": "+0800",
+	"JST": "+0900", "KST": "+0900", "ACST": "+0930", "ACDT": "+1030",
+	"AEST": "+1000", "AEDT": "+1100", "NZST": "+1200", "NZDT": "+1300",
+}
+
+// dateLayouts are the full layouts tried in order, ISO 8601 forms first. The RFC 822/1123 family
+// is covered by combining dateParts, timeParts and zoneParts once the weekday has been removed
+// and named zones have been replaced by offsets.
+var dateLayouts = buildDateLayouts()
+
+var (
+	isoDateLayouts = []string{
+		"2006-01-02T15:04:05.999999999Z07:00",
+		"2006-01-02T15:04:05Z07:00",
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04:05.999999999Z0700",
+		"2006-01-02T15:04:05Z0700",
+		"2006-01-02T15:04Z0700",
+		"2006-01-02T15:04:05Z07",
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04",
+		"20060102T150405Z0700",
+		"20060102T150405",
+	}
+	dateParts = []string{
+		"2 Jan 2006", "2 January 2006", "2 Jan 06", "2-Jan-2006", "2-Jan-06",
+		"Jan 2, 2006", "January 2, 2006", "Jan 2 2006", "January 2 2006",
+		"2006-01-02", "2006/01/02", "02.01.2006", "2.1.2006", "20060102",
+	}
+	timeParts = []string{
+		"15:04:05.999999999", "15:04:05", "15:04", "3:04:05 PM", "3:04 PM", "3:04PM", "3PM",
+	}
+	zoneParts = []string{"-0700", "-07:00", "Z07:00", "-07", ""}
+)
+
+// buildDateLayouts combines the layout parts into the full layout table
+func buildDateLayouts() []string {
+	layouts := append([]string{}, isoDateLayouts...)
+	for _, datePart := range dateParts {
+		for _, timePart := range timeParts {
+			for _, zonePart := range zoneParts {
+				layouts = append(layouts, strings.TrimSpace(datePart+" "+timePart+" "+zonePart))
+			}
+		}
+		// Dates without a time
+		layouts = append(layouts, datePart)
+	}
+	// Unix date order with the year last
+	return append(layouts, "Jan 2 15:04:05 -0700 2006", "Jan 2 15:04:05 2006")
+}
+
+var (
+	weekdayPattern     = regexp.MustCompile(`(?i)^(mon|tue|tues|wed|thu|thur|thurs|fri|sat|sun)[a-z]*\.?,?\s+`)
+	ordinalPattern     = regexp.MustCompile(`(?i)\b(\d{1,2})(st|nd|rd|th)\b`)
+	zoneCommentPattern = regexp.MustCompile(`\s*\([^)]*\)$`)
+	namedZonePattern   = regexp.MustCompile(`\s([A-Za-z]{1,5})$`)
+	offsetZonePattern  = regexp.MustCompile(`(?i)\s(?:GMT|UTC)([+-]\d{1,2})(?::?(\d{2}))?$`)
+	relativePattern    = regexp.MustCompile(`(?i)^(\d+|an?|one)\s+(second|sec|minute|min|hour|hr|day|week|month|year)s?\s+ago$`)
+)
+
+// ParseDate normalises a date from a feed or web page. It understands RFC 3339 / ISO 8601,
+// RFC 822 and 1123 including two-digit years, named timezones, common US and European forms,
+// Unix timestamps and relative dates such as "3 hours ago".
+func ParseDate(value string) (time.Time, error) {
+	return parseDateAt(value, time.Now())
+}
+
+// parseDateAt parses a date, resolving relative dates against now
+func parseDateAt(value string, now time.Time) (time.Time, error) {
+	normalized := normalizeDate(value)
+	if normalized == "" {
+		return time.Time{}, fmt.Errorf("unable to parse date: empty value")
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, normalized); err == nil {
+			return t, nil
+		}
+	}
+
+	if t, ok := parseUnixTimestamp(normalized); ok {
+		return t, nil
+	}
+
+	if t, ok := parseRelativeDate(normalized, now); ok {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse date: %s", value)
+}
+
+// normalizeDate rewrites a date into a form the layout table covers: single spaces, no weekday,
+// no ordinal suffixes or zone comments, and named timezones replaced by numeric offsets
+func normalizeDate(value string) string {
+	s := strings.Join(strings.Fields(value), " ")
+	s = weekdayPattern.ReplaceAllString(s, "")
+	s = zoneCommentPattern.ReplaceAllString(s, "")
+	s = ordinalPattern.ReplaceAllString(s, "$1")
+	s = strings.Replace(s, " at ", " ", 1)
+	s = strings.Replace(s, "Sept ", "Sep ", 1)
+	s = strings.ReplaceAll(s, "a.m.", "AM")
+	s = strings.ReplaceAll(s, "p.m.", "PM")
+
+	// "GMT+2" and "UTC-05:30"
+	if match := offsetZonePattern.FindStringSubmatch(s); match != nil {
+		hours, _ := strconv.Atoi(match[1])
+		minutes := match[2]
+		if minutes == "" {
+			minutes = "00"
+		}
+		sign := "+"
+		if hours < 0 {
+			sign, hours = "-", -hours
+		}
+		s = s[:len(s)-len(match[0])] + fmt.Sprintf(" %s%02d%s", sign, hours, minutes)
+	}
+
+	// A trailing named zone, unless it is the AM/PM marker
+	if match := namedZonePattern.FindStringSubmatch(s); match != nil {
+		if offset, ok := timezoneOffsets[strings.ToUpper(match[1])]; ok {
+			s = s[:len(s)-len(match[0])] + " " + offset
+		}
+	}
+
+	return strings.TrimSpace(s)
+}
+
+// parseUnixTimestamp parses seconds or milliseconds since the epoch
+func parseUnixTimestamp(s string) (time.Time, bool) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	switch len(s) {
+	case 10:
+		return time.Unix(n, 0).UTC(), true
+	case 13:
+		return time.UnixMilli(n).UTC(), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// parseRelativeDate parses "just now", "today", "yesterday" and "<n> <unit>s ago"
+func parseRelativeDate(s string, now time.Time) (time.Time, bool) {
+	switch strings.ToLower(s) {
+	case "just now", "now":
+		return now, true
+	case "today":
+		return midnight(now), true
+	case "yesterday":
+		return midnight(now.AddDate(0, 0, -1)), true
+	}
+
+	match := relativePattern.FindStringSubmatch(s)
+	if match == nil {
+		return time.Time{}, false
+	}
+
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		n = 1 // "a", "an" or "one"
+	}
+
+	switch strings.ToLower(match[2]) {
+	case "second", "sec":
+		return now.Add(-time.Duration(n) * time.Second), true
+	case "minute", "min":
+		return now.Add(-time.Duration(n) * time.Minute), true
+	case "hour", "hr":
+		return now.Add(-time.Duration(n) * time.Hour), true
+	case "day":
+		return now.AddDate(0, 0, -n), true
+	case "week":
+		return now.AddDate(0, 0, -7*n), true
+	case "month":
+		return now.AddDate(0, -n, 0), true
+	default:
+		return now.AddDate(-n, 0, 0), true
+	}
+}
+
+// midnight returns the start of t's day in t's location. Truncate would round to midnight UTC,
+// which is the wrong day for zones far from UTC.
+func midnight(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
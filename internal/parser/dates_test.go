@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+func TestParseDate(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"2024-03-05T10:00:00Z", "2024-03-05T10:00:00Z"},
+		{"2024-03-05T10:00:00.123+02:00", "2024-03-05T08:00:00Z"},
+		{"2024-03-05T10:00+0100", "2024-03-05T09:00:00Z"},
+		{"2024-03-05 10:00:00", "2024-03-05T10:00:00Z"},
+		{"Tue, 05 Mar 2024 10:00:00 +0000", "2024-03-05T10:00:00Z"},
+		{"Tue, 5 Mar 2024 10:00:00 EST", "2024-03-05T15:00:00Z"},
+		{"Tuesday, 05 March 2024 10:00:00 PDT", "2024-03-05T17:00:00Z"},
+		{"Tue, 05 Mar 24 10:00:00 GMT", "2024-03-05T10:00:00Z"},
+		{"Tue, 05 Mar 2024 10:00 CEST", "2024-03-05T08:00:00Z"},
+		{"05 Mar 2024 15:30:00 IST", "2024-03-05T10:00:00Z"},
+		{"Tue, 05 Mar 2024 10:00:00 GMT+2", "2024-03-05T08:00:00Z"},
+		{"Tue, 05 Mar 2024 10:00:00 -0500 (EST)", "2024-03-05T15:00:00Z"},
+		{"March 5th, 2024 at 3:04 PM", "2024-03-05T15:04:00Z"},
+		{"Sept 5, 2024", "2024-09-05T00:00:00Z"},
+		{"05.03.2024 10:00", "2024-03-05T10:00:00Z"},
+		{"Tue Mar 5 10:00:00 2024", "2024-03-05T10:00:00Z"},
+		{"1709632800", "2024-03-05T10:00:00Z"},
+		{"1709632800000", "2024-03-05T10:00:00Z"},
+		{"3 hours ago", "2024-03-10T09:00:00Z"},
+		{"an hour ago", "2024-03-10T11:00:00Z"},
+		{"yesterday", "2024-03-09T00:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDateAt(tt.value, now)
+		if err != nil {
+			t.Errorf("parseDateAt(%q) failed: %v", tt.value, err)
+			continue
+		}
+		if formatted := got.UTC().Format(time.RFC3339); formatted != tt.expected {
+			t.Errorf("parseDateAt(%q) = %s, expected %s", tt.value, formatted, tt.expected)
+		}
+	}
+
+	for _, value := range []string{"", "not a date", "32 Mar 2024"} {
+		if _, err := parseDateAt(value, now); err == nil {
+			t.Errorf("parseDateAt(%q) should fail", value)
+		}
+	}
+}
+
+func TestRelativeDaysStartAtLocalMidnight(t *testing.T) {
+	// Just after midnight east of UTC, while it is still the previous day in UTC
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	now := time.Date(2024, 3, 10, 1, 0, 0, 0, zone)
+
+	tests := map[string]time.Time{
+		"today":     time.Date(2024, 3, 10, 0, 0, 0, 0, zone),
+		"yesterday": time.Date(2024, 3, 9, 0, 0, 0, 0, zone),
+	}
+
+	for value, expected := range tests {
+		got, err := parseDateAt(value, now)
+		if err != nil {
+			t.Errorf("parseDateAt(%q) failed: %v", value, err)
+			continue
+		}
+		if !got.Equal(expected) {
+			t.Errorf("parseDateAt(%q) = %s, expected %s", value, got, expected)
+		}
+	}
+}
+
+func TestExtractPublishedTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		page     string
+		expected string
+	}{
+		{"Open Graph", `<html><head><meta property="article:published_time" content="2024-03-05T10:00:00Z"><meta name="date" content="2020-01-01"></head></html>`, "2024-03-05T10:00:00Z"},
+		{"JSON-LD graph", `<html><head><script type="application/ld+json">{"@graph":[{"@type":"WebPage"},{"@type":"NewsArticle","datePublished":"2024-03-05T10:00:00Z"}]}</script></head></html>`, "2024-03-05T10:00:00Z"},
+		{"time element", `<html><body><time class="entry-date published" datetime="2024-03-05">March 5</time></body></html>`, "2024-03-05"},
+		{"none", `<html><body><time datetime="2024-03-05">March 5</time></body></html>`, ""},
+	}
+
+	ce := &ContentExtractor{}
+	for _, tt := range tests {
+		doc, err := html.Parse(strings.NewReader(tt.page))
+		if err != nil {
+			t.Fatalf("%s: failed to parse page: %v", tt.name, err)
+		}
+		if got := ce.extractMetadata(doc, "").PublishedTime; got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, got)
+		}
+	}
+}
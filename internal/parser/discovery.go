@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"bytes"
+	"mime"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// feedLinkTypes are the <link rel="alternate"> types that point to a feed ParseFeed can read
+var feedLinkTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/rdf+xml":   true,
+	"application/feed+json": true,
+}
+
+// isHTMLDocument reports whether a response that is not a feed is an HTML page
+func isHTMLDocument(contentType string, body []byte) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+		return true
+	}
+
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))
+	prefix := strings.ToLower(string(trimmed[:min(len(trimmed), 64)]))
+	return strings.HasPrefix(prefix, "<!doctype html") || strings.HasPrefix(prefix, "<html") ||
+		sniffXMLRoot(trimmed) == "html"
+}
+
+// discoverFeedURL finds the feed advertised by an HTML page through <link rel="alternate">,
+// resolved against the page URL or its <base href>. Comment feeds are only used when the page
+// advertises nothing else. Returns "" if the page links no feed.
+func discoverFeedURL(body []byte, pageURL *url.URL) string {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	base := pageURL
+	var candidates, commentFeeds []string
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "base":
+				if href := strings.TrimSpace(getAttr(n, "href")); href != "" {
+					if resolved, err := pageURL.Parse(href); err == nil {
+						base = resolved
+					}
+				}
+			case "link":
+				if isFeedLink(n) {
+					if strings.Contains(strings.ToLower(getAttr(n, "title")), "comment") {
+						commentFeeds = append(commentFeeds, getAttr(n, "href"))
+					} else {
+						candidates = append(candidates, getAttr(n, "href"))
+					}
+				}
+			case "body":
+				// Feed links belong in the head, don't scan the whole page
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	for _, href := range append(candidates, commentFeeds...) {
+		href = strings.TrimSpace(href)
+		if href == "" {
+			continue
+		}
+		if resolved, err := base.Parse(href); err == nil && (resolved.Scheme == "http" || resolved.Scheme == "https") {
+			return resolved.String()
+		}
+	}
+
+	return ""
+}
+
+// isFeedLink reports whether a <link> element advertises an alternate feed
+func isFeedLink(n *html.Node) bool {
+	mediaType, _, _ := mime.ParseMediaType(getAttr(n, "type"))
+	return hasRel(n, "alternate") && feedLinkTypes[mediaType]
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"strandnerd-crawler/internal/config"
+)
+
+const testHomepage = `<!DOCTYPE html>
+<html>
+<head>
+	<title>Example News</title>
+	<link rel="alternate" type="application/rss+xml" title="Comments Feed" href="/comments/feed/">
+	<link rel="alternate" type="application/rss+xml; charset=UTF-8" title="Example News" href="/feed/">
+	<link rel="stylesheet" href="/style.css">
+</head>
+<body><link rel="alternate" type="application/atom+xml" href="/body-feed"></body>
+</html>`
+
+func TestParseFeedDiscoversFeedOnHomepage(t *testing.T) {
+	var homepageRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			homepageRequests++
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(testHomepage))
+		case "/feed/":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			w.Write([]byte(testRSSFeed))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := NewRSSParser(&http.Client{Timeout: 5 * time.Second}, &config.Config{UserAgent: "test"})
+
+	for i := 0; i < 2; i++ {
+		feed, err := p.ParseFeed(context.Background(), server.URL+"/")
+		if err != nil {
+			t.Fatalf("Fetch %d failed: %v", i+1, err)
+		}
+		if len(feed.Items) != 1 {
+			t.Fatalf("Expected 1 item, got %d", len(feed.Items))
+		}
+	}
+
+	if discovered, ok := p.GetDiscoveredFeedURL(server.URL + "/"); !ok || discovered != server.URL+"/feed/" {
+		t.Errorf("Expected discovered feed %s, got %q", server.URL+"/feed/", discovered)
+	}
+	if homepageRequests != 1 {
+		t.Errorf("Expected the homepage to be fetched once, got %d requests", homepageRequests)
+	}
+}
+
+func TestParseFeedHomepageWithoutFeed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>No feed</title></head><body></body></html>`))
+	}))
+	defer server.Close()
+
+	p := NewRSSParser(&http.Client{Timeout: 5 * time.Second}, &config.Config{UserAgent: "test"})
+
+	if _, err := p.ParseFeed(context.Background(), server.URL); err == nil {
+		t.Fatal("Expected an error for a page without a feed link")
+	}
+	if _, ok := p.GetDiscoveredFeedURL(server.URL); ok {
+		t.Error("No feed URL should be remembered")
+	}
+}
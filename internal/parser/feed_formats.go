@@ -0,0 +1,250 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"strandnerd-crawler/internal/models"
+)
+
+// feedFormat is a syndication format ParseFeed understands
+type feedFormat string
+
+const (
+	formatRSS     feedFormat = "RSS"
+	formatAtom    feedFormat = "Atom"
+	formatRDF     feedFormat = "RDF"
+	formatJSON    feedFormat = "JSON Feed"
+	formatUnknown feedFormat = ""
+)
+
+// feedAcceptHeader lists the feed media types ParseFeed can handle
+const feedAcceptHeader = "application/rss+xml, application/atom+xml, application/rdf+xml, application/feed+json, application/xml;q=0.9, text/xml;q=0.9, application/json;q=0.8, */*;q=0.1"
+
+// jsonFeedTitleLength bounds titles derived from the text of untitled JSON Feed items
+const jsonFeedTitleLength = 100
+
+// parseFeedBody converts the document to UTF-8, detects its format and normalises it into an RSSFeed.
+// XML documents are sanitised first and decoded leniently if they are still not well-formed.
+func parseFeedBody(contentType string, body []byte) (*models.RSSFeed, error) {
+	body = decodeCharset(contentType, body)
+
+	switch format := detectFeedFormat(contentType, body); format {
+	case formatAtom:
+		atomFeed, err := decodeXML[models.AtomFeed](sanitizeXML(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Atom feed: %w", err)
+		}
+		// Convert Atom to RSS format for consistent processing
+		return convertAtomToRSS(atomFeed), nil
+
+	case formatRDF:
+		rdf, err := decodeXML[models.RDFFeed](sanitizeXML(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RDF feed: %w", err)
+		}
+		feed := rdf.Channel
+		feed.Items = rdf.Items
+		return &feed, nil
+
+	case formatJSON:
+		var jsonFeed models.JSONFeed
+		if err := json.Unmarshal(body, &jsonFeed); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON feed: %w", err)
+		}
+		if !strings.HasPrefix(jsonFeed.Version, "https://jsonfeed.org/version/") {
+			return nil, fmt.Errorf("failed to parse JSON feed: not a JSON Feed document")
+		}
+		return convertJSONFeedToRSS(&jsonFeed), nil
+
+	case formatRSS:
+		rss, err := decodeXML[models.RSS](sanitizeXML(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSS: %w", err)
+		}
+		return &rss.Channel, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported feed format (content type %q)", contentType)
+	}
+}
+
+// detectFeedFormat identifies the feed format from the document's root element, falling back
+// to the Content-Type header when the body does not tell
+func detectFeedFormat(contentType string, body []byte) feedFormat {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return formatJSON
+	}
+
+	switch sniffXMLRoot(trimmed) {
+	case "rss":
+		return formatRSS
+	case "feed":
+		return formatAtom
+	case "rdf":
+		return formatRDF
+	case "":
+		// Not parseable far enough to find a root element, trust the server
+	default:
+		return formatUnknown
+	}
+
+	switch mediaType {
+	case "application/feed+json", "application/json":
+		return formatJSON
+	case "application/atom+xml":
+		return formatAtom
+	case "application/rdf+xml":
+		return formatRDF
+	case "application/rss+xml", "application/xml", "text/xml":
+		return formatRSS
+	default:
+		return formatUnknown
+	}
+}
+
+// sniffXMLRoot returns the lowercased local name of the document's root element, or "" if
+// none could be read
+func sniffXMLRoot(body []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	// Only the root element's name matters here, so any declared charset is read as is
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return strings.ToLower(start.Name.Local)
+		}
+	}
+}
+
+// convertJSONFeedToRSS converts a JSON Feed to RSS format for consistent processing
+func convertJSONFeedToRSS(jsonFeed *models.JSONFeed) *models.RSSFeed {
+	rss := &models.RSSFeed{
+		Title:       jsonFeed.Title,
+		Description: jsonFeed.Description,
+		Link:        jsonFeed.HomePageURL,
+		Items:       make([]models.RSSItem, 0, len(jsonFeed.Items)),
+	}
+
+	feedAuthors := jsonFeedAuthorNames(jsonFeed.Author, jsonFeed.Authors)
+
+	for _, entry := range jsonFeed.Items {
+		item := models.RSSItem{
+			Title:       entry.Title,
+			Description: entry.Summary,
+			Content:     entry.ContentHTML,
+			Link:        entry.URL,
+			Creator:     jsonFeedAuthorNames(entry.Author, entry.Authors),
+			PubDate:     entry.DatePublished,
+			GUID:        jsonFeedItemID(entry.ID),
+			Categories:  entry.Tags,
+		}
+
+		if item.Content == "" {
+			item.Content = entry.ContentText
+		}
+		if item.Link == "" {
+			item.Link = entry.ExternalURL
+		}
+		if item.Creator == "" {
+			item.Creator = feedAuthors
+		}
+		if item.PubDate == "" {
+			item.PubDate = entry.DateModified
+		}
+
+		// Microblog items have no title, use the start of their text instead
+		if item.Title == "" {
+			item.Title = truncateText(cleanString(firstNonEmpty(entry.Summary, entry.ContentText, entry.ContentHTML)), jsonFeedTitleLength)
+		}
+
+		if entry.Image != "" {
+			item.Media = append(item.Media, models.MediaContent{URL: entry.Image, Medium: "image"})
+		}
+		if entry.BannerImage != "" {
+			item.Media = append(item.Media, models.MediaContent{URL: entry.BannerImage, Medium: "image"})
+		}
+		for _, attachment := range entry.Attachments {
+			item.Media = append(item.Media, models.MediaContent{
+				URL:    attachment.URL,
+				Type:   attachment.MimeType,
+				Medium: mediumFromType(attachment.MimeType),
+			})
+		}
+
+		rss.Items = append(rss.Items, item)
+	}
+
+	return rss
+}
+
+// jsonFeedAuthorNames joins the names of a JSON Feed 1.1 authors list or a 1.0 author
+func jsonFeedAuthorNames(author *models.JSONFeedAuthor, authors []models.JSONFeedAuthor) string {
+	if len(authors) == 0 && author != nil {
+		authors = []models.JSONFeedAuthor{*author}
+	}
+
+	var names []string
+	for _, a := range authors {
+		if name := strings.TrimSpace(a.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// jsonFeedItemID returns an item ID given either as a string or a number
+func jsonFeedItemID(raw json.RawMessage) string {
+	var id string
+	if err := json.Unmarshal(raw, &id); err == nil {
+		return id
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(raw, &number); err == nil {
+		return number.String()
+	}
+
+	return ""
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// truncateText shortens text to at most maxLength characters at a word boundary
+func truncateText(text string, maxLength int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= maxLength {
+		return text
+	}
+
+	runes := []rune(text)
+	cut := string(runes[:maxLength])
+	if i := strings.LastIndex(cut, " "); i > maxLength/2 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestDetectFeedFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    feedFormat
+	}{
+		{"RSS mentioning feed", "text/xml", `<?xml version="1.0"?><rss version="2.0"><channel><item><description>&lt;feed&gt; and <![CDATA[<feed>]]></description></item></channel></rss>`, formatRSS},
+		{"Atom", "text/xml", `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"></feed>`, formatAtom},
+		{"RDF", "application/xml", `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/"></rdf:RDF>`, formatRDF},
+		{"JSON Feed with BOM", "text/plain", "\xef\xbb\xbf  {\"version\": \"https://jsonfeed.org/version/1.1\"}", formatJSON},
+		{"HTML page", "text/html", `<!DOCTYPE html><html><body>Not a feed</body></html>`, formatUnknown},
+		{"Unreadable body falls back to content type", "application/atom+xml", `<<<`, formatAtom},
+	}
+
+	for _, tt := range tests {
+		if got := detectFeedFormat(tt.contentType, []byte(tt.body)); got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestParseRDFFeed(t *testing.T) {
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+	<channel rdf:about="https://example.com/">
+		<title>RDF Feed</title>
+		<link>https://example.com/</link>
+		<items><rdf:Seq><rdf:li rdf:resource="https://example.com/one"/></rdf:Seq></items>
+	</channel>
+	<item rdf:about="https://example.com/one">
+		<title>First item</title>
+		<link>https://example.com/one</link>
+		<description>Item description</description>
+		<dc:creator>Jane Doe</dc:creator>
+		<dc:date>2024-03-05T10:00:00+01:00</dc:date>
+	</item>
+</rdf:RDF>`
+
+	feed, err := parseFeedBody("application/rdf+xml", []byte(body))
+	if err != nil {
+		t.Fatalf("Failed to parse RDF feed: %v", err)
+	}
+	if feed.Title != "RDF Feed" || len(feed.Items) != 1 {
+		t.Fatalf("Expected channel title and 1 item, got %q and %d items", feed.Title, len(feed.Items))
+	}
+
+	item := feed.Items[0]
+	if item.Title != "First item" || item.Link != "https://example.com/one" || item.Description != "Item description" {
+		t.Errorf("Unexpected item fields: %+v", item)
+	}
+	if item.GUID != "https://example.com/one" {
+		t.Errorf("Expected rdf:about as GUID, got %q", item.GUID)
+	}
+	if item.Creator != "Jane Doe" || item.PubDate != "2024-03-05T10:00:00+01:00" {
+		t.Errorf("Expected Dublin Core creator and date, got %q and %q", item.Creator, item.PubDate)
+	}
+}
+
+func TestParseJSONFeed(t *testing.T) {
+	body := `{
+		"version": "https://jsonfeed.org/version/1.1",
+		"title": "JSON Feed",
+		"home_page_url": "https://example.com/",
+		"authors": [{"name": "Feed Author"}],
+		"items": [
+			{
+				"id": "1",
+				"url": "https://example.com/one",
+				"title": "First item",
+				"content_html": "<p>Hello</p>",
+				"summary": "Summary",
+				"image": "https://example.com/one.jpg",
+				"date_published": "2024-03-05T10:00:00Z",
+				"tags": ["go", "feeds"],
+				"authors": [{"name": "Jane Doe"}, {"name": "John Roe"}]
+			},
+			{
+				"id": 2,
+				"external_url": "https://other.example.com/two",
+				"content_text": "A microblog post without a title",
+				"attachments": [{"url": "https://example.com/two.mp3", "mime_type": "audio/mpeg"}]
+			}
+		]
+	}`
+
+	feed, err := parseFeedBody("application/feed+json", []byte(body))
+	if err != nil {
+		t.Fatalf("Failed to parse JSON feed: %v", err)
+	}
+	if feed.Title != "JSON Feed" || len(feed.Items) != 2 {
+		t.Fatalf("Expected feed title and 2 items, got %q and %d items", feed.Title, len(feed.Items))
+	}
+
+	first := feed.Items[0]
+	if first.GUID != "1" || first.Link != "https://example.com/one" || first.Content != "<p>Hello</p>" {
+		t.Errorf("Unexpected first item: %+v", first)
+	}
+	if first.Creator != "Jane Doe, John Roe" || len(first.Categories) != 2 || len(first.Media) != 1 {
+		t.Errorf("Expected item authors, tags and image, got %+v", first)
+	}
+
+	second := feed.Items[1]
+	if second.GUID != "2" || second.Link != "https://other.example.com/two" {
+		t.Errorf("Expected numeric ID and external URL, got %q and %q", second.GUID, second.Link)
+	}
+	if second.Title != "A microblog post without a title" || second.Content != "A microblog post without a title" {
+		t.Errorf("Expected title and content from content_text, got %q and %q", second.Title, second.Content)
+	}
+	if second.Creator != "Feed Author" {
+		t.Errorf("Expected feed author as fallback, got %q", second.Creator)
+	}
+	if len(second.Media) != 1 || second.Media[0].Medium != "audio" {
+		t.Errorf("Expected audio attachment, got %+v", second.Media)
+	}
+}
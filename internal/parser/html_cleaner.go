@@ -257,47 +257,13 @@ func (hc *HTMLCleaner) ExtractCleanContent(rawHTML string) string {
 	return hc.CleanHTML(rawHTML)
 }
 
-// findElementBySelector finds element by simple selector
+// findElementBySelector finds the first element matching a CSS selector
 func (hc *HTMLCleaner) findElementBySelector(n *html.Node, selector string) *html.Node {
-	var result *html.Node
-	var f func(*html.Node)
-	f = func(n *html.Node) {
-		if n.Type == html.ElementNode {
-			// Handle attribute selectors like [role=main]
-			if strings.HasPrefix(selector, "[") && strings.HasSuffix(selector, "]") {
-				attrPair := strings.Trim(selector, "[]")
-				parts := strings.Split(attrPair, "=")
-				if len(parts) == 2 {
-					attrName := parts[0]
-					attrValue := parts[1]
-					for _, attr := range n.Attr {
-						if attr.Key == attrName && attr.Val == attrValue {
-							result = n
-							return
-						}
-					}
-				}
-			} else if strings.HasPrefix(selector, ".") {
-				// Handle class selector
-				className := strings.TrimPrefix(selector, ".")
-				for _, attr := range n.Attr {
-					if attr.Key == "class" && strings.Contains(attr.Val, className) {
-						result = n
-						return
-					}
-				}
-			} else if n.Data == selector {
-				// Handle tag name selector
-				result = n
-				return
-			}
-		}
-		for c := n.FirstChild; c != nil && result == nil; c = c.NextSibling {
-			f(c)
-		}
+	compiled := cachedSelector(selector)
+	if compiled == nil {
+		return nil
 	}
-	f(n)
-	return result
+	return compiled.MatchFirst(n)
 }
 
 // extractTextContent extracts plain text from HTML for length estimation
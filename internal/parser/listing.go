@@ -0,0 +1,177 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+
+	"strandnerd-crawler/internal/models"
+)
+
+// maxListingPageSize bounds the HTML read from a listing page
+const maxListingPageSize = 10 << 20
+
+// ParseListingPage fetches an HTML index page of a site without a feed and turns the article
+// links matched by linkSelector into feed items. The selector may match the <a> elements
+// themselves or elements containing them, such as article teasers.
+func (p *RSSParser) ParseListingPage(ctx context.Context, pageURL, linkSelector string) (*models.RSSFeed, error) {
+	body, contentType, finalURL, err := p.fetchDocument(ctx, pageURL, "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8", maxListingPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := html.Parse(bytes.NewReader(decodeCharset(contentType, body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	base := finalURL
+	if baseTag := p.contentExtractor.findElementBySelector(doc, "base"); baseTag != nil {
+		if resolved, err := finalURL.Parse(strings.TrimSpace(getAttr(baseTag, "href"))); err == nil {
+			base = resolved
+		}
+	}
+
+	feed := &models.RSSFeed{Link: finalURL.String()}
+	if titleNode := p.contentExtractor.findElementBySelector(doc, "title"); titleNode != nil {
+		feed.Title = strings.TrimSpace(p.contentExtractor.extractText(titleNode))
+	}
+
+	seen := make(map[string]bool)
+	for _, match := range p.contentExtractor.findAllBySelector(doc, linkSelector) {
+		anchor := linkElement(match)
+		if anchor == nil {
+			continue
+		}
+
+		link, err := base.Parse(strings.TrimSpace(getAttr(anchor, "href")))
+		if err != nil || (link.Scheme != "http" && link.Scheme != "https") {
+			continue
+		}
+		link.Fragment = ""
+		if seen[link.String()] {
+			continue
+		}
+		seen[link.String()] = true
+
+		feed.Items = append(feed.Items, models.RSSItem{
+			Title: p.listingLinkTitle(match, anchor, link.String()),
+			Link:  link.String(),
+			GUID:  link.String(),
+		})
+	}
+
+	if len(feed.Items) == 0 {
+		return nil, fmt.Errorf("link selector %q matched no article links on %s", linkSelector, pageURL)
+	}
+
+	return feed, nil
+}
+
+// fetchDocument fetches a page or sitemap and returns up to maxSize bytes of its body, its
+// Content-Type and the URL it was served from
+func (p *RSSParser) fetchDocument(ctx context.Context, docURL, accept string, maxSize int64) ([]byte, string, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", docURL, nil)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", p.userAgent)
+	req.Header.Set("Accept", accept)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to fetch %s: %w", docURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", nil, fmt.Errorf("%s returned status %d", docURL, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize))
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return body, resp.Header.Get("Content-Type"), resp.Request.URL, nil
+}
+
+// linkElement returns the link for a selector match: the match itself, its closest <a> ancestor
+// or the first <a> inside it
+func linkElement(n *html.Node) *html.Node {
+	for a := n; a != nil; a = a.Parent {
+		if a.Type == html.ElementNode && a.Data == "a" && getAttr(a, "href") != "" {
+			return a
+		}
+	}
+
+	var found *html.Node
+	var f func(*html.Node)
+	f = func(c *html.Node) {
+		if c.Type == html.ElementNode && c.Data == "a" && getAttr(c, "href") != "" {
+			found = c
+			return
+		}
+		for child := c.FirstChild; child != nil && found == nil; child = child.NextSibling {
+			f(child)
+		}
+	}
+	f(n)
+	return found
+}
+
+// listingLinkTitle finds the headline of a listing entry: a heading in the matched element,
+// the link text, its title attribute or an image's alt text, falling back to the URL slug
+func (p *RSSParser) listingLinkTitle(match, anchor *html.Node, link string) string {
+	ce := p.contentExtractor
+	candidates := []string{}
+	for _, heading := range []string{"h1", "h2", "h3", "h4"} {
+		if n := ce.findElementBySelector(match, heading); n != nil && n != match {
+			candidates = append(candidates, ce.extractText(n))
+			break
+		}
+	}
+	candidates = append(candidates, ce.extractText(anchor), getAttr(anchor, "title"))
+	if img := ce.findElementBySelector(anchor, "img"); img != nil {
+		candidates = append(candidates, getAttr(img, "alt"))
+	}
+
+	if title := strings.Join(strings.Fields(firstNonEmpty(candidates...)), " "); title != "" {
+		return title
+	}
+	return titleFromURL(link)
+}
+
+// titleFromURL derives a placeholder title from the last path segment of an article URL,
+// e.g. "/2024/03/city-council-approves-budget.html" becomes "City council approves budget"
+func titleFromURL(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+
+	slug := path.Base(strings.TrimSuffix(u.Path, "/"))
+	slug = strings.TrimSuffix(slug, path.Ext(slug))
+	words := strings.FieldsFunc(slug, func(r rune) bool { return r == '-' || r == '_' || r == '+' })
+	if len(words) == 0 || slug == "." || slug == "/" {
+		return u.Host
+	}
+
+	title := []rune(strings.Join(words, " "))
+	title[0] = unicode.ToUpper(title[0])
+	return string(title)
+}
+
+// isPlaceholderTitle reports whether a post's title was derived from its URL by titleFromURL
+// rather than provided by the source
+func isPlaceholderTitle(title, link string) bool {
+	return title == titleFromURL(link)
+}
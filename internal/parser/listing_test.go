@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"strandnerd-crawler/internal/config"
+)
+
+const testListingPage = `<!DOCTYPE html>
+<html>
+<head><title>Example News</title></head>
+<body>
+	<nav><a href="/about">About</a></nav>
+	<div class="teaser">
+		<a href="/2024/03/first-story.html"><img src="/first.jpg" alt="Ignored"></a>
+		<h2>First story headline</h2>
+	</div>
+	<div class="teaser"><a href="https://example.org/2024/03/second-story#comments"><img src="/second.jpg" alt="Second story"></a></div>
+	<div class="teaser"><a href="/2024/03/first-story.html">First story again</a></div>
+	<div class="teaser"><a href="/2024/03/city-council-approves-budget/"></a></div>
+	<div class="teaser"><a href="mailto:tips@example.com">Send tips</a></div>
+</body>
+</html>`
+
+func TestParseListingPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(testListingPage))
+	}))
+	defer server.Close()
+
+	p := NewRSSParser(&http.Client{Timeout: 5 * time.Second}, &config.Config{UserAgent: "test"})
+
+	feed, err := p.ParseListingPage(context.Background(), server.URL+"/news/", ".teaser")
+	if err != nil {
+		t.Fatalf("ParseListingPage failed: %v", err)
+	}
+
+	expected := []struct{ title, link string }{
+		{"First story headline", server.URL + "/2024/03/first-story.html"},
+		{"Second story", "https://example.org/2024/03/second-story"},
+		{"City council approves budget", server.URL + "/2024/03/city-council-approves-budget/"},
+	}
+	if len(feed.Items) != len(expected) {
+		t.Fatalf("Expected %d items, got %d: %+v", len(expected), len(feed.Items), feed.Items)
+	}
+	for i, want := range expected {
+		if feed.Items[i].Title != want.title || feed.Items[i].Link != want.link {
+			t.Errorf("Item %d: expected %q <%s>, got %q <%s>", i, want.title, want.link, feed.Items[i].Title, feed.Items[i].Link)
+		}
+	}
+	if feed.Title != "Example News" {
+		t.Errorf("Expected page title as feed title, got %q", feed.Title)
+	}
+
+	if _, err := p.ParseListingPage(context.Background(), server.URL, ".missing"); err == nil {
+		t.Error("Expected an error when the selector matches nothing")
+	}
+}
+
+func TestParseListingPageHeaderCharset(t *testing.T) {
+	// "Café charges 3€" in ISO-8859-15, declared only in the Content-Type header
+	page := []byte("<html><body><div class=\"teaser\"><a href=\"/cafe\">Caf\xe9 charges 3\xa4</a></div></body></html>")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-15")
+		w.Write(page)
+	}))
+	defer server.Close()
+
+	p := NewRSSParser(&http.Client{Timeout: 5 * time.Second}, &config.Config{UserAgent: "test"})
+
+	feed, err := p.ParseListingPage(context.Background(), server.URL, ".teaser")
+	if err != nil {
+		t.Fatalf("ParseListingPage failed: %v", err)
+	}
+	if len(feed.Items) != 1 || feed.Items[0].Title != "Café charges 3€" {
+		t.Errorf("Expected the title decoded as ISO-8859-15, got %+v", feed.Items)
+	}
+}
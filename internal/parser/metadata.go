@@ -0,0 +1,346 @@
+package parser
+
+import (
+	"encoding/json"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// PageMetadata is what an article page declares about itself in schema.org JSON-LD, Open
+// Graph, Twitter card and standard meta tags
+type PageMetadata struct {
+	Title         string
+	Description   string
+	ImageURL      string // absolute
+	Authors       []string
+	PublishedTime string // as found on the page, see ParseDate
+	ModifiedTime  string // as found on the page, see ParseDate
+	Section       string
+	Tags          []string
+	CanonicalURL  string // absolute
+	Language      string // BCP 47 tag such as "en" or "pt-BR"
+	SiteName      string
+	Type          string // JSON-LD @type or og:type, e.g. "NewsArticle" or "article"
+}
+
+// publishedTimeMetaNames are the meta tags carrying an article's publish date, by priority
+var publishedTimeMetaNames = []string{
+	"article:published_time",
+	"og:published_time",
+	"datepublished",
+	"dc.date.issued",
+	"dcterms.created",
+	"parsely-pub-date",
+	"sailthru.date",
+	"pubdate",
+	"date",
+}
+
+// jsonLDArticleTypes are the schema.org types describing an article page's main content
+var jsonLDArticleTypes = map[string]bool{
+	"Article": true, "NewsArticle": true, "ReportageNewsArticle": true, "AnalysisNewsArticle": true,
+	"OpinionNewsArticle": true, "ReviewNewsArticle": true, "BackgroundNewsArticle": true,
+	"AskPublicQuestionNewsArticle": true, "BlogPosting": true, "LiveBlogPosting": true,
+	"SocialMediaPosting": true, "TechArticle": true, "ScholarlyArticle": true, "Report": true,
+}
+
+// pageTags holds the metadata sources collected from a page in one walk
+type pageTags struct {
+	metas       map[string]string   // first value per lower-cased property, name, itemprop or http-equiv
+	multiMetas  map[string][]string // every value of repeatable properties such as article:tag
+	scripts     []string            // JSON-LD documents
+	canonical   string
+	language    string
+	title       string
+	timeElement string
+}
+
+// repeatableMetas are the meta properties a page may list several times
+var repeatableMetas = map[string]bool{"article:tag": true, "article:author": true}
+
+// extractMetadata collects a page's structured metadata. Each field takes the first source that
+// has it: Open Graph and article:* properties first, then the JSON-LD article object, then
+// Twitter cards and standard meta tags, then the HTML itself. Publish dates keep the order of
+// publishedTimeMetaNames before JSON-LD and <time> elements. URLs are resolved against pageURL.
+func (ce *ContentExtractor) extractMetadata(doc *html.Node, pageURL string) *PageMetadata {
+	tags := collectPageTags(doc)
+	article, jsonLD := findJSONLDArticle(tags.scripts)
+	meta := func(name string) string { return tags.metas[name] }
+
+	metadata := &PageMetadata{
+		Title:        firstNonEmpty(meta("og:title"), jsonLDString(article["headline"]), jsonLDString(article["name"]), meta("twitter:title"), tags.title),
+		Description:  firstNonEmpty(meta("og:description"), jsonLDString(article["description"]), meta("twitter:description"), meta("description")),
+		ModifiedTime: firstNonEmpty(meta("article:modified_time"), meta("og:updated_time"), jsonLDString(article["dateModified"]), meta("datemodified")),
+		Section:      firstNonEmpty(meta("article:section"), jsonLDString(article["articleSection"]), meta("parsely-section")),
+		Language:     normalizeLanguage(firstNonEmpty(tags.language, jsonLDString(article["inLanguage"]), meta("og:locale"), meta("content-language"))),
+		SiteName:     firstNonEmpty(meta("og:site_name"), jsonLDString(article["publisher"]), meta("application-name")),
+		Type:         firstNonEmpty(jsonLDString(article["@type"]), meta("og:type")),
+	}
+
+	if image := firstNonEmpty(meta("og:image"), meta("og:image:url"), meta("twitter:image"), meta("twitter:image:src"), jsonLDURL(article["image"]), jsonLDURL(article["thumbnailUrl"])); image != "" {
+		metadata.ImageURL = ce.resolveURL(image, pageURL)
+	}
+	if canonical := firstNonEmpty(tags.canonical, meta("og:url"), jsonLDURL(article["url"]), jsonLDURL(article["mainEntityOfPage"])); canonical != "" {
+		metadata.CanonicalURL = ce.resolveURL(canonical, pageURL)
+	}
+
+	metadata.Authors = jsonLDStrings(article["author"])
+	if len(metadata.Authors) == 0 {
+		for _, author := range tags.multiMetas["article:author"] {
+			// article:author is often a profile URL rather than a name
+			if !strings.HasPrefix(author, "http://") && !strings.HasPrefix(author, "https://") {
+				metadata.Authors = append(metadata.Authors, author)
+			}
+		}
+	}
+	if len(metadata.Authors) == 0 {
+		if author := firstNonEmpty(meta("author"), meta("parsely-author"), meta("dc.creator"), meta("sailthru.author")); author != "" {
+			metadata.Authors = []string{author}
+		}
+	}
+
+	metadata.Tags = tags.multiMetas["article:tag"]
+	if len(metadata.Tags) == 0 {
+		metadata.Tags = jsonLDStrings(article["keywords"])
+	}
+	if len(metadata.Tags) == 0 {
+		metadata.Tags = splitKeywords(firstNonEmpty(meta("news_keywords"), meta("keywords")))
+	}
+
+	for _, name := range publishedTimeMetaNames {
+		if metadata.PublishedTime = meta(name); metadata.PublishedTime != "" {
+			break
+		}
+	}
+	if metadata.PublishedTime == "" {
+		metadata.PublishedTime = firstNonEmpty(jsonLDString(article["datePublished"]), findJSONLDDatePublished(jsonLD), tags.timeElement)
+	}
+
+	return metadata
+}
+
+// collectPageTags walks a page once for its meta tags, JSON-LD scripts, canonical link,
+// language, title and publish date <time> element
+func collectPageTags(doc *html.Node) *pageTags {
+	tags := &pageTags{metas: make(map[string]string), multiMetas: make(map[string][]string)}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "html":
+				tags.language = strings.TrimSpace(firstNonEmpty(getAttr(n, "lang"), getAttr(n, "xml:lang")))
+			case "title":
+				if tags.title == "" && n.FirstChild != nil {
+					tags.title = strings.Join(strings.Fields(n.FirstChild.Data), " ")
+				}
+			case "meta":
+				key := strings.ToLower(firstNonEmpty(getAttr(n, "property"), getAttr(n, "name"), getAttr(n, "itemprop"), getAttr(n, "http-equiv")))
+				if content := strings.TrimSpace(getAttr(n, "content")); key != "" && content != "" {
+					if _, ok := tags.metas[key]; !ok {
+						tags.metas[key] = content
+					}
+					if repeatableMetas[key] {
+						tags.multiMetas[key] = append(tags.multiMetas[key], content)
+					}
+				}
+			case "link":
+				if tags.canonical == "" && hasRel(n, "canonical") {
+					tags.canonical = strings.TrimSpace(getAttr(n, "href"))
+				}
+			case "script":
+				if strings.EqualFold(strings.TrimSpace(getAttr(n, "type")), "application/ld+json") && n.FirstChild != nil {
+					tags.scripts = append(tags.scripts, n.FirstChild.Data)
+				}
+			case "time":
+				if tags.timeElement == "" && (getAttr(n, "itemprop") == "datePublished" || hasClass(n, "published") || hasClass(n, "pubdate")) {
+					tags.timeElement = strings.TrimSpace(getAttr(n, "datetime"))
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return tags
+}
+
+// findJSONLDArticle returns the first article object of a page's JSON-LD documents, or nil,
+// together with all decoded documents
+func findJSONLDArticle(scripts []string) (map[string]any, []any) {
+	var documents []any
+	for _, script := range scripts {
+		var data any
+		if err := json.Unmarshal([]byte(strings.TrimSpace(script)), &data); err != nil {
+			continue
+		}
+		documents = append(documents, data)
+	}
+
+	var article map[string]any
+	var find func(any)
+	find = func(data any) {
+		switch v := data.(type) {
+		case map[string]any:
+			for _, t := range jsonLDStrings(v["@type"]) {
+				if jsonLDArticleTypes[t] {
+					article = v
+					return
+				}
+			}
+			for _, key := range []string{"@graph", "mainEntity"} {
+				if article == nil && v[key] != nil {
+					find(v[key])
+				}
+			}
+		case []any:
+			for _, item := range v {
+				if article == nil {
+					find(item)
+				}
+			}
+		}
+	}
+	find(documents)
+
+	return article, documents
+}
+
+// findJSONLDDatePublished searches a JSON-LD document, including @graph lists and nested
+// objects, for the first datePublished value
+func findJSONLDDatePublished(data any) string {
+	switch v := data.(type) {
+	case map[string]any:
+		if date, ok := v["datePublished"].(string); ok && strings.TrimSpace(date) != "" {
+			return strings.TrimSpace(date)
+		}
+		if graph, ok := v["@graph"]; ok {
+			if date := findJSONLDDatePublished(graph); date != "" {
+				return date
+			}
+		}
+		if entity, ok := v["mainEntity"]; ok {
+			return findJSONLDDatePublished(entity)
+		}
+	case []any:
+		for _, item := range v {
+			if date := findJSONLDDatePublished(item); date != "" {
+				return date
+			}
+		}
+	}
+	return ""
+}
+
+// jsonLDString returns a JSON-LD value as text: a string, the name of an object such as a
+// Person or Organization, or the first of a list
+func jsonLDString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]any:
+		for _, key := range []string{"name", "@value"} {
+			if s := jsonLDString(v[key]); s != "" {
+				return s
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if s := jsonLDString(item); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// jsonLDURL returns a JSON-LD URL value: a string, the url, contentUrl or @id of an object such
+// as an ImageObject or WebPage, or the first of a list
+func jsonLDURL(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]any:
+		for _, key := range []string{"url", "contentUrl", "@id"} {
+			if s := jsonLDURL(v[key]); s != "" {
+				return s
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if s := jsonLDURL(item); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// jsonLDStrings returns every text of a JSON-LD value that may be a single value or a list.
+// Comma separated strings, as keywords often are, are split.
+func jsonLDStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return splitKeywords(v)
+	case []any:
+		var values []string
+		for _, item := range v {
+			values = append(values, jsonLDStrings(item)...)
+		}
+		return values
+	default:
+		if s := jsonLDString(v); s != "" {
+			return []string{s}
+		}
+	}
+	return nil
+}
+
+// splitKeywords splits a comma separated list, dropping empty entries
+func splitKeywords(list string) []string {
+	var keywords []string
+	for _, keyword := range strings.Split(list, ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// normalizeLanguage turns a language tag or Open Graph locale ("en_US") into a BCP 47 tag
+func normalizeLanguage(language string) string {
+	return strings.ReplaceAll(strings.TrimSpace(language), "_", "-")
+}
+
+// getAttr returns the value of an element's attribute, or "" if it is not set
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// hasClass reports whether an element has the given class
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(getAttr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRel reports whether a link element has the given relation
+func hasRel(n *html.Node, rel string) bool {
+	for _, r := range strings.Fields(strings.ToLower(getAttr(n, "rel"))) {
+		if r == rel {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+
+	"strandnerd-crawler/internal/models"
+)
+
+const testMetadataPage = `<!DOCTYPE html>
+<html lang="en_GB">
+<head>
+	<title>Council approves budget | Example News</title>
+	<link rel="canonical" href="/2024/03/council-budget">
+	<meta property="og:title" content="Council approves budget">
+	<meta property="og:type" content="article">
+	<meta property="og:site_name" content="Example News">
+	<meta property="article:section" content="Local">
+	<meta property="article:tag" content="Budget">
+	<meta property="article:tag" content="City Council">
+	<meta property="article:author" content="https://example.com/staff/jane-doe">
+	<meta name="twitter:description" content="Twitter summary">
+	<meta name="keywords" content="ignored, keywords">
+	<script type="application/ld+json">{
+		"@context": "https://schema.org",
+		"@graph": [
+			{"@type": "WebSite", "name": "Example News", "url": "https://example.com/"},
+			{
+				"@type": ["NewsArticle"],
+				"headline": "Council approves budget after long debate",
+				"description": "The council approved the budget on Tuesday.",
+				"image": {"@type": "ImageObject", "name": "Council chamber", "url": "https://cdn.example.com/council.jpg"},
+				"author": [{"@type": "Person", "name": "Jane Doe"}, {"@type": "Person", "name": "John Smith"}],
+				"datePublished": "2024-03-05T10:00:00Z",
+				"dateModified": "2024-03-05T12:30:00Z",
+				"publisher": {"@type": "Organization", "name": "Example Media Group"}
+			}
+		]
+	}</script>
+</head>
+<body><p>Article</p></body>
+</html>`
+
+func TestExtractMetadata(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(testMetadataPage))
+	if err != nil {
+		t.Fatalf("Failed to parse page: %v", err)
+	}
+
+	got := (&ContentExtractor{}).extractMetadata(doc, "https://example.com/2024/03/council-budget?utm_source=feed")
+	want := &PageMetadata{
+		Title:         "Council approves budget",
+		Description:   "The council approved the budget on Tuesday.",
+		ImageURL:      "https://cdn.example.com/council.jpg",
+		Authors:       []string{"Jane Doe", "John Smith"},
+		PublishedTime: "2024-03-05T10:00:00Z",
+		ModifiedTime:  "2024-03-05T12:30:00Z",
+		Section:       "Local",
+		Tags:          []string{"Budget", "City Council"},
+		CanonicalURL:  "https://example.com/2024/03/council-budget",
+		Language:      "en-GB",
+		SiteName:      "Example News",
+		Type:          "NewsArticle",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected metadata:\n got: %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestExtractMetadataFallbacks(t *testing.T) {
+	page := `<html><head>
+		<title> Plain   page </title>
+		<meta name="description" content="Meta description">
+		<meta name="author" content="Jane Doe">
+		<meta name="news_keywords" content="budget, , council">
+		<meta name="twitter:image" content="/images/lead.jpg">
+	</head><body></body></html>`
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Failed to parse page: %v", err)
+	}
+
+	got := (&ContentExtractor{}).extractMetadata(doc, "https://example.com/a/b")
+	if got.Title != "Plain page" || got.Description != "Meta description" || got.ImageURL != "https://example.com/images/lead.jpg" {
+		t.Errorf("Unexpected title, description or image: %+v", got)
+	}
+	if !reflect.DeepEqual(got.Authors, []string{"Jane Doe"}) || !reflect.DeepEqual(got.Tags, []string{"budget", "council"}) {
+		t.Errorf("Unexpected authors or tags: %+v", got)
+	}
+}
+
+func TestFillFromMetadata(t *testing.T) {
+	feedDescription := "From the feed"
+	post := &models.CreateInspirationFeedPostRequest{Description: &feedDescription}
+
+	fillFromMetadata(post, &PageMetadata{
+		Description:  "From the page",
+		Section:      "Local",
+		Tags:         []string{"Budget", "local"},
+		Language:     "en",
+		CanonicalURL: "https://example.com/a",
+	})
+
+	if *post.Description != "From the feed" {
+		t.Errorf("Expected the feed's description to be kept, got %q", *post.Description)
+	}
+	if !reflect.DeepEqual(post.Categories, []string{"Local", "Budget"}) {
+		t.Errorf("Expected section and tags as categories, got %v", post.Categories)
+	}
+	if post.Language == nil || *post.Language != "en" || post.CanonicalURL == nil || *post.CanonicalURL != "https://example.com/a" {
+		t.Errorf("Expected language and canonical URL to be filled, got %v %v", post.Language, post.CanonicalURL)
+	}
+}
@@ -0,0 +1,330 @@
+package parser
+
+import (
+	"math"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Content scoring in the spirit of Mozilla Readability: paragraphs award points to their
+// ancestors by length and comma count, candidates are weighted by class/id hints and
+// penalised by link density, and the best candidate is merged with related siblings.
+
+var (
+	unlikelyCandidatePattern = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|ad-break|agegate|pagination|pager|popup|yom-remote|newsletter|subscribe|share|promo`)
+	maybeCandidatePattern    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveHintPattern      = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativeHintPattern      = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget|byline|author|newsletter|subscribe`)
+)
+
+// readabilitySkipTags never contain article content
+var readabilitySkipTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "nav": true, "header": true, "footer": true,
+	"aside": true, "form": true, "button": true, "iframe": true, "svg": true, "select": true, "template": true,
+}
+
+// readabilityBlockTags are the elements that stop a <div> from being scored as a paragraph
+var readabilityBlockTags = map[string]bool{
+	"blockquote": true, "dl": true, "div": true, "ol": true, "p": true, "pre": true, "table": true,
+	"ul": true, "section": true, "article": true, "figure": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+const (
+	// minParagraphLength is the shortest text that counts as a paragraph
+	minParagraphLength = 25
+	// minReadableLength is the shortest text accepted as article content
+	minReadableLength = 100
+)
+
+// readabilityScorer holds the content scores of the candidate elements of one document
+type readabilityScorer struct {
+	ce         *ContentExtractor
+	scores     map[*html.Node]float64
+	candidates []*html.Node // scored elements in the order they were found, so ties resolve to the first
+}
+
+// extractReadableContent finds the article content by scoring the document's elements and
+// returns it as HTML, or "" if no candidate holds enough text
+func (ce *ContentExtractor) extractReadableContent(doc *html.Node) string {
+	scorer := &readabilityScorer{ce: ce, scores: make(map[*html.Node]float64)}
+	scorer.scoreParagraphs(doc)
+
+	top := scorer.topCandidate()
+	if top == nil {
+		return ""
+	}
+
+	var buf strings.Builder
+	buf.WriteString("<div>")
+	for _, n := range scorer.mergeSiblings(top) {
+		html.Render(&buf, scorer.prune(n))
+	}
+	buf.WriteString("</div>")
+
+	content := buf.String()
+	if len(strings.TrimSpace(ce.extractTextFromHTML(content))) < minReadableLength {
+		return ""
+	}
+	return ce.cleanHTML(content)
+}
+
+// scoreParagraphs awards every paragraph's score to its parent in full, its grandparent by
+// half and further ancestors by a third of their distance
+func (s *readabilityScorer) scoreParagraphs(n *html.Node) {
+	if n.Type == html.ElementNode {
+		if readabilitySkipTags[n.Data] || isUnlikelyCandidate(n) {
+			return
+		}
+		if isParagraph(n) {
+			s.scoreParagraph(n)
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		s.scoreParagraphs(c)
+	}
+}
+
+// scoreParagraph distributes one paragraph's score over its ancestors
+func (s *readabilityScorer) scoreParagraph(p *html.Node) {
+	text := normalizedText(s.ce, p)
+	if len(text) < minParagraphLength {
+		return
+	}
+
+	score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+
+	level := 0
+	for ancestor := p.Parent; ancestor != nil && level < 5; ancestor = ancestor.Parent {
+		if ancestor.Type != html.ElementNode || ancestor.Data == "html" {
+			break
+		}
+		if _, ok := s.scores[ancestor]; !ok {
+			s.scores[ancestor] = initialScore(ancestor)
+			s.candidates = append(s.candidates, ancestor)
+		}
+
+		switch level {
+		case 0:
+			s.scores[ancestor] += score
+		case 1:
+			s.scores[ancestor] += score / 2
+		default:
+			s.scores[ancestor] += score / float64(level*3)
+		}
+		level++
+	}
+}
+
+// topCandidate returns the candidate with the highest score after the link density penalty
+func (s *readabilityScorer) topCandidate() *html.Node {
+	var top *html.Node
+	topScore := 0.0
+	for _, n := range s.candidates {
+		score := s.scores[n] * (1 - linkDensity(s.ce, n))
+		s.scores[n] = score
+		if top == nil || score > topScore {
+			top, topScore = n, score
+		}
+	}
+	if top == nil || topScore <= 0 {
+		return nil
+	}
+
+	// A lone paragraph-sized wrapper scores like its parent, take the parent for more context
+	for top.Parent != nil && top.Parent.Type == html.ElementNode && top.Parent.Data != "body" &&
+		countElementChildren(top.Parent) == 1 {
+		top = top.Parent
+	}
+	return top
+}
+
+// mergeSiblings returns the top candidate together with the siblings that look like part
+// of the same article, such as content split over several wrappers
+func (s *readabilityScorer) mergeSiblings(top *html.Node) []*html.Node {
+	if top.Parent == nil {
+		return []*html.Node{top}
+	}
+
+	topScore := s.scores[top]
+	threshold := math.Max(10, topScore*0.2)
+	topClass := getAttr(top, "class")
+
+	var merged []*html.Node
+	for sibling := top.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type != html.ElementNode {
+			continue
+		}
+		if sibling == top {
+			merged = append(merged, sibling)
+			continue
+		}
+		if readabilitySkipTags[sibling.Data] || isUnlikelyCandidate(sibling) {
+			continue
+		}
+
+		bonus := 0.0
+		if topClass != "" && getAttr(sibling, "class") == topClass {
+			bonus = topScore * 0.2
+		}
+		if score, ok := s.scores[sibling]; ok && score+bonus >= threshold {
+			merged = append(merged, sibling)
+			continue
+		}
+
+		// Lead images usually sit next to the article text
+		if sibling.Data == "figure" || sibling.Data == "picture" || sibling.Data == "img" {
+			merged = append(merged, sibling)
+			continue
+		}
+
+		if sibling.Data == "p" {
+			text := normalizedText(s.ce, sibling)
+			density := linkDensity(s.ce, sibling)
+			if (len(text) > 80 && density < 0.25) ||
+				(len(text) > 0 && density == 0 && strings.HasSuffix(strings.TrimSpace(text), ".")) {
+				merged = append(merged, sibling)
+			}
+		}
+	}
+	return merged
+}
+
+// prune returns a copy of n without the descendants that are not article content: boilerplate
+// elements, unlikely candidates and link-heavy blocks such as related article lists
+func (s *readabilityScorer) prune(n *html.Node) *html.Node {
+	clone := &html.Node{Type: n.Type, DataAtom: n.DataAtom, Data: n.Data, Namespace: n.Namespace, Attr: n.Attr}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && s.isClutter(c) {
+			continue
+		}
+		clone.AppendChild(s.prune(c))
+	}
+	return clone
+}
+
+// isClutter reports whether an element inside the article should be dropped
+func (s *readabilityScorer) isClutter(n *html.Node) bool {
+	if readabilitySkipTags[n.Data] || isUnlikelyCandidate(n) {
+		return true
+	}
+	// Figures and embedded media are kept whatever their class says
+	if s.ce.findElementBySelector(n, "img") != nil || s.ce.findElementBySelector(n, "video") != nil {
+		return false
+	}
+	if classWeight(n) < 0 && s.scores[n] < minParagraphLength {
+		return true
+	}
+
+	switch n.Data {
+	case "div", "section", "ul", "ol", "table":
+		density := linkDensity(s.ce, n)
+		return density > 0.5 || (density > 0.2 && len(normalizedText(s.ce, n)) < minParagraphLength)
+	}
+	return false
+}
+
+// isParagraph reports whether an element is scored as a paragraph. Divs count when they hold
+// text directly instead of wrapping other blocks.
+func isParagraph(n *html.Node) bool {
+	switch n.Data {
+	case "p", "pre", "td", "blockquote":
+		return true
+	case "div", "section":
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && readabilityBlockTags[c.Data] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// isUnlikelyCandidate reports whether an element's class, id or role marks it as page furniture
+func isUnlikelyCandidate(n *html.Node) bool {
+	if n.Data == "body" || n.Data == "article" || n.Data == "main" {
+		return false
+	}
+	switch getAttr(n, "role") {
+	case "navigation", "complementary", "banner", "contentinfo", "dialog", "menu":
+		return true
+	}
+
+	hints := getAttr(n, "class") + " " + getAttr(n, "id")
+	return unlikelyCandidatePattern.MatchString(hints) && !maybeCandidatePattern.MatchString(hints)
+}
+
+// initialScore is a candidate's score before any paragraph is counted
+func initialScore(n *html.Node) float64 {
+	score := classWeight(n)
+	switch n.Data {
+	case "div", "article", "main":
+		score += 5
+	case "pre", "td", "blockquote", "section":
+		score += 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score -= 5
+	}
+	return score
+}
+
+// classWeight scores an element's class and id hints
+func classWeight(n *html.Node) float64 {
+	weight := 0.0
+	for _, hint := range []string{getAttr(n, "class"), getAttr(n, "id")} {
+		if hint == "" {
+			continue
+		}
+		if negativeHintPattern.MatchString(hint) {
+			weight -= 25
+		}
+		if positiveHintPattern.MatchString(hint) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// linkDensity is the share of an element's text that sits inside links
+func linkDensity(ce *ContentExtractor, n *html.Node) float64 {
+	textLength := len(normalizedText(ce, n))
+	if textLength == 0 {
+		return 0
+	}
+
+	linkLength := 0
+	var f func(*html.Node)
+	f = func(c *html.Node) {
+		if c.Type == html.ElementNode && c.Data == "a" {
+			linkLength += len(normalizedText(ce, c))
+			return
+		}
+		for child := c.FirstChild; child != nil; child = child.NextSibling {
+			f(child)
+		}
+	}
+	f(n)
+
+	return float64(linkLength) / float64(textLength)
+}
+
+// normalizedText returns an element's text with whitespace collapsed
+func normalizedText(ce *ContentExtractor, n *html.Node) string {
+	return strings.Join(strings.Fields(ce.extractText(n)), " ")
+}
+
+// countElementChildren counts an element's child elements
+func countElementChildren(n *html.Node) int {
+	count := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			count++
+		}
+	}
+	return count
+}
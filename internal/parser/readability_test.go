@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// minExtractionF1 is the word-level F1 score every article of the corpus must reach
+const minExtractionF1 = 0.9
+
+// TestReadableContentGoldenFiles extracts every article in testdata/articles and compares the
+// extracted text with the hand-written .golden file next to it
+func TestReadableContentGoldenFiles(t *testing.T) {
+	pages, err := filepath.Glob(filepath.Join("testdata", "articles", "*.html"))
+	if err != nil || len(pages) == 0 {
+		t.Fatalf("No article fixtures found: %v", err)
+	}
+
+	ce := &ContentExtractor{}
+	for _, page := range pages {
+		name := strings.TrimSuffix(filepath.Base(page), ".html")
+		t.Run(name, func(t *testing.T) {
+			body, err := os.ReadFile(page)
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+			golden, err := os.ReadFile(strings.TrimSuffix(page, ".html") + ".golden")
+			if err != nil {
+				t.Fatalf("Failed to read golden file: %v", err)
+			}
+
+			doc, err := html.Parse(strings.NewReader(string(body)))
+			if err != nil {
+				t.Fatalf("Failed to parse fixture: %v", err)
+			}
+
+			extracted := ce.extractTextFromHTML(ce.extractReadableContent(doc))
+			precision, recall := wordOverlap(extracted, string(golden))
+			f1 := 0.0
+			if precision+recall > 0 {
+				f1 = 2 * precision * recall / (precision + recall)
+			}
+			t.Logf("precision %.2f, recall %.2f, F1 %.2f", precision, recall, f1)
+
+			if f1 < minExtractionF1 {
+				t.Errorf("F1 %.2f below %.2f, extracted:\n%s", f1, minExtractionF1, strings.Join(strings.Fields(extracted), " "))
+			}
+		})
+	}
+}
+
+// wordOverlap returns the share of extracted words that are in the expected text (precision)
+// and the share of expected words that were extracted (recall), counting repeated words
+func wordOverlap(extracted, expected string) (float64, float64) {
+	expectedWords := make(map[string]int)
+	expectedCount := 0
+	for _, word := range strings.Fields(strings.ToLower(expected)) {
+		expectedWords[word]++
+		expectedCount++
+	}
+
+	extractedWords := strings.Fields(strings.ToLower(extracted))
+	matched := 0
+	for _, word := range extractedWords {
+		if expectedWords[word] > 0 {
+			expectedWords[word]--
+			matched++
+		}
+	}
+
+	if len(extractedWords) == 0 || expectedCount == 0 {
+		return 0, 0
+	}
+	return float64(matched) / float64(len(extractedWords)), float64(matched) / float64(expectedCount)
+}
@@ -1,43 +1,115 @@
 package parser
 
 import (
-	"encoding/xml"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"strandnerd-crawler/internal/config"
 	"strandnerd-crawler/internal/models"
 )
 
+// ErrNotModified is returned by ParseFeed when the server answers a conditional
+// request with 304 Not Modified, meaning the feed has no new items
+var ErrNotModified = errors.New("feed not modified")
+
+// FeedValidators holds the HTTP cache validators returned for a feed
+type FeedValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
 // RSSParser handles parsing RSS feeds
 type RSSParser struct {
 	httpClient       *http.Client
 	userAgent        string
 	contentExtractor *ContentExtractor
+	validators       map[string]FeedValidators
+	validatorsMutex  sync.RWMutex
+	discovered       map[string]string // feed URLs that are HTML pages -> the feed they link to
+	discoveredMutex  sync.RWMutex
 }
 
 // NewRSSParser creates a new RSS parser
 func NewRSSParser(client *http.Client, config *config.Config) *RSSParser {
 	return &RSSParser{
 		httpClient:       client,
+		userAgent:        config.UserAgent,
 		contentExtractor: NewContentExtractor(client, config),
+		validators:       make(map[string]FeedValidators),
+		discovered:       make(map[string]string),
+	}
+}
+
+// GetValidators returns the cache validators remembered for a feed URL
+func (p *RSSParser) GetValidators(feedURL string) (FeedValidators, bool) {
+	p.validatorsMutex.RLock()
+	defer p.validatorsMutex.RUnlock()
+
+	v, ok := p.validators[feedURL]
+	return v, ok
+}
+
+// SetValidators stores the cache validators for a feed URL, removing the entry when both are empty
+func (p *RSSParser) SetValidators(feedURL string, v FeedValidators) {
+	p.validatorsMutex.Lock()
+	defer p.validatorsMutex.Unlock()
+
+	if v.ETag == "" && v.LastModified == "" {
+		delete(p.validators, feedURL)
+		return
+	}
+	p.validators[feedURL] = v
+}
+
+// ParseFeed fetches and parses an RSS 2.0, RSS 1.0 (RDF), Atom or JSON Feed document from the given URL.
+// If the URL is an HTML page, the feed it advertises is discovered and fetched instead, and
+// remembered for later crawls; see GetDiscoveredFeedURL.
+func (p *RSSParser) ParseFeed(ctx context.Context, feedURL string) (*models.RSSFeed, error) {
+	discovered, ok := p.GetDiscoveredFeedURL(feedURL)
+	if !ok {
+		return p.fetchFeed(ctx, feedURL, feedURL, true)
+	}
+
+	feed, err := p.fetchFeed(ctx, feedURL, discovered, false)
+	if err != nil && !errors.Is(err, ErrNotModified) && ctx.Err() == nil {
+		// The site may have moved its feed, discover it again on the next crawl
+		p.SetDiscoveredFeedURL(feedURL, "")
 	}
+	return feed, err
 }
 
-// ParseFeed fetches and parses an RSS feed from the given URL
-func (p *RSSParser) ParseFeed(feedURL string) (*models.RSSFeed, error) {
+// fetchFeed fetches and parses the document at fetchURL for the feed configured as feedURL.
+// Cache validators are kept per fetched URL so a page and the feed it links never share them.
+// With discover set, an HTML page is searched for a feed link.
+func (p *RSSParser) fetchFeed(ctx context.Context, feedURL, fetchURL string, discover bool) (*models.RSSFeed, error) {
 	// Create request
-	req, err := http.NewRequest("GET", feedURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fetchURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set user agent
 	req.Header.Set("User-Agent", p.userAgent)
-	req.Header.Set("Accept", "application/rss+xml, application/xml, text/xml")
+	req.Header.Set("Accept", feedAcceptHeader)
+
+	// Send conditional headers so unchanged feeds can answer with 304
+	if v, ok := p.GetValidators(fetchURL); ok {
+		if v.ETag != "" {
+			req.Header.Set("If-None-Match", v.ETag)
+		}
+		if v.LastModified != "" {
+			req.Header.Set("If-Modified-Since", v.LastModified)
+		}
+	}
 
 	// Fetch the feed
 	resp, err := p.httpClient.Do(req)
@@ -46,6 +118,10 @@ func (p *RSSParser) ParseFeed(feedURL string) (*models.RSSFeed, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, ErrNotModified
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("feed returned status %d", resp.StatusCode)
 	}
@@ -56,27 +132,61 @@ func (p *RSSParser) ParseFeed(feedURL string) (*models.RSSFeed, error) {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	// Detect feed type by checking the root element
-	bodyStr := string(body)
-	
-	if strings.Contains(bodyStr, `xmlns="http://www.w3.org/2005/Atom"`) || strings.Contains(bodyStr, "<feed") {
-		// Parse as Atom feed
-		var atomFeed models.AtomFeed
-		if err := xml.Unmarshal(body, &atomFeed); err != nil {
-			return nil, fmt.Errorf("failed to parse Atom feed: %w", err)
-		}
-		
-		// Convert Atom to RSS format for consistent processing
-		return convertAtomToRSS(&atomFeed), nil
-	} else {
-		// Parse as RSS feed
-		var rss models.RSS
-		if err := xml.Unmarshal(body, &rss); err != nil {
-			return nil, fmt.Errorf("failed to parse RSS: %w", err)
-		}
-		
-		return &rss.Channel, nil
+	contentType := resp.Header.Get("Content-Type")
+	if discover && detectFeedFormat(contentType, body) == formatUnknown && isHTMLDocument(contentType, body) {
+		return p.followFeedLink(ctx, feedURL, resp.Request.URL, body)
+	}
+
+	feed, err := parseFeedBody(contentType, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only remember validators once the body parsed, so a broken response is refetched in full
+	p.SetValidators(fetchURL, FeedValidators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	})
+
+	return feed, nil
+}
+
+// followFeedLink fetches the feed advertised by the HTML page found at a feed's URL
+func (p *RSSParser) followFeedLink(ctx context.Context, feedURL string, pageURL *url.URL, body []byte) (*models.RSSFeed, error) {
+	discovered := discoverFeedURL(body, pageURL)
+	if discovered == "" {
+		return nil, fmt.Errorf("%s is an HTML page that does not link to a feed", pageURL)
+	}
+	log.Printf("🔎 Discovered feed %s on page %s", discovered, pageURL)
+
+	feed, err := p.fetchFeed(ctx, feedURL, discovered, false)
+	if err != nil && !errors.Is(err, ErrNotModified) {
+		return nil, fmt.Errorf("failed to fetch discovered feed %s: %w", discovered, err)
 	}
+
+	p.SetDiscoveredFeedURL(feedURL, discovered)
+	return feed, err
+}
+
+// GetDiscoveredFeedURL returns the feed URL discovered on the HTML page configured as a feed's URL
+func (p *RSSParser) GetDiscoveredFeedURL(feedURL string) (string, bool) {
+	p.discoveredMutex.RLock()
+	defer p.discoveredMutex.RUnlock()
+
+	discovered, ok := p.discovered[feedURL]
+	return discovered, ok
+}
+
+// SetDiscoveredFeedURL remembers the feed discovered for a page URL, removing the entry when empty
+func (p *RSSParser) SetDiscoveredFeedURL(feedURL, discovered string) {
+	p.discoveredMutex.Lock()
+	defer p.discoveredMutex.Unlock()
+
+	if discovered == "" {
+		delete(p.discovered, feedURL)
+		return
+	}
+	p.discovered[feedURL] = discovered
 }
 
 // GetContentExtractor returns the content extractor instance
@@ -84,8 +194,9 @@ func (p *RSSParser) GetContentExtractor() *ContentExtractor {
 	return p.contentExtractor
 }
 
-// ConvertToInspirationPosts converts RSS items to inspiration feed posts
-func ConvertToInspirationPosts(feedID string, rssItems []models.RSSItem, contentExtractor *ContentExtractor) []*models.CreateInspirationFeedPostRequest {
+// ConvertToInspirationPosts converts RSS items to inspiration feed posts using only the data in the feed.
+// Article pages are not fetched here; call ExtractPostContent for the posts that are actually new.
+func ConvertToInspirationPosts(feedID string, rssItems []models.RSSItem) []*models.CreateInspirationFeedPostRequest {
 	var posts []*models.CreateInspirationFeedPostRequest
 
 	for _, item := range rssItems {
@@ -107,16 +218,22 @@ func ConvertToInspirationPosts(feedID string, rssItems []models.RSSItem, content
 			post.Content = &content
 		}
 
-		// Handle author
-		if item.Author != "" {
+		// Handle author, preferring the creator's name over the RSS author field which is usually an email address
+		if item.Creator != "" {
+			author := cleanString(item.Creator)
+			post.Author = &author
+		} else if item.Author != "" {
 			author := cleanString(item.Author)
 			post.Author = &author
 		}
 
+		post.Categories = cleanCategories(item.Categories)
+		post.Media = collectMedia(item)
+
 		// Handle published date
 		if item.PubDate != "" {
 			// Try to parse the date and convert to RFC3339
-			if parsedTime, err := parseRSSDate(item.PubDate); err == nil {
+			if parsedTime, err := ParseDate(item.PubDate); err == nil {
 				pubDate := parsedTime.Format(time.RFC3339)
 				post.PublishedAt = &pubDate
 			}
@@ -132,33 +249,9 @@ func ConvertToInspirationPosts(feedID string, rssItems []models.RSSItem, content
 			post.GUID = &guid
 		}
 
-		// Extract content and image from the actual webpage
-		if post.URL != "" {
-			if extracted, err := contentExtractor.ExtractContentFromURL(post.URL); err == nil {
-				// Use extracted Open Graph image as priority
-				if extracted.ImageURL != "" {
-					post.ImageURL = &extracted.ImageURL
-				}
-
-				// Use extracted HTML content as full content
-				if extracted.FullContent != "" {
-					post.FullContent = &extracted.FullContent
-				}
-			}
-		}
-
-		// Fallback to RSS-embedded images if webpage extraction failed
-		if post.ImageURL == nil {
-			if item.MediaThumbnail != nil && item.MediaThumbnail.URL != "" {
-				imageURL := cleanString(item.MediaThumbnail.URL)
-				post.ImageURL = &imageURL
-			} else if item.MediaContent != nil && item.MediaContent.URL != "" {
-				imageURL := cleanString(item.MediaContent.URL)
-				post.ImageURL = &imageURL
-			} else if item.Enclosure != nil && item.Enclosure.URL != "" && isImageType(item.Enclosure.Type) {
-				imageURL := cleanString(item.Enclosure.URL)
-				post.ImageURL = &imageURL
-			}
+		// Use RSS-embedded images until the webpage extraction provides a better one
+		if imageURL := bestImage(post.Media); imageURL != "" {
+			post.ImageURL = &imageURL
 		}
 
 		// Only add posts with both title and URL
@@ -170,6 +263,183 @@ func ConvertToInspirationPosts(feedID string, rssItems []models.RSSItem, content
 	return posts
 }
 
+// cleanCategories trims categories and drops empty and duplicate ones, keeping the first spelling
+func cleanCategories(categories []string) []string {
+	var cleaned []string
+	seen := make(map[string]bool)
+
+	for _, category := range categories {
+		category = cleanString(category)
+		key := strings.ToLower(category)
+		if category == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		cleaned = append(cleaned, category)
+	}
+
+	return cleaned
+}
+
+// collectMedia returns the item's media items followed by its enclosure, without duplicate URLs
+func collectMedia(item models.RSSItem) []models.MediaContent {
+	media := append([]models.MediaContent{}, item.Media...)
+	if item.Enclosure != nil {
+		enclosure := models.MediaContent{
+			URL:    item.Enclosure.URL,
+			Type:   item.Enclosure.Type,
+			Medium: mediumFromType(item.Enclosure.Type),
+		}
+		// Unlike Media RSS, an untyped enclosure is not assumed to be an image
+		if enclosure.Medium == "" {
+			enclosure.Medium = mediumFromExtension(enclosure.URL)
+		}
+		media = append(media, enclosure)
+	}
+
+	var collected []models.MediaContent
+	seen := make(map[string]bool)
+	for _, m := range media {
+		m.URL = cleanString(m.URL)
+		if m.URL == "" || seen[m.URL] {
+			continue
+		}
+		seen[m.URL] = true
+		if m.Medium == "" {
+			m.Medium = mediumFromType(m.Type)
+		}
+		collected = append(collected, m)
+	}
+
+	return collected
+}
+
+// bestImage picks the image to show for a post: the largest known image, preferring full
+// images over thumbnails. Media without a medium or type is assumed to be an image.
+func bestImage(media []models.MediaContent) string {
+	var best *models.MediaContent
+	for i := range media {
+		m := &media[i]
+		if m.Medium != "" && m.Medium != "image" {
+			continue
+		}
+		if best == nil || betterImage(m, best) {
+			best = m
+		}
+	}
+
+	if best == nil {
+		return ""
+	}
+	return best.URL
+}
+
+// betterImage reports whether a is preferred over b: full images beat thumbnails, then the
+// larger image wins
+func betterImage(a, b *models.MediaContent) bool {
+	if a.Thumbnail != b.Thumbnail {
+		return !a.Thumbnail
+	}
+	return a.Width*a.Height > b.Width*b.Height
+}
+
+// mediumFromType derives the Media RSS medium from a MIME type
+func mediumFromType(mimeType string) string {
+	switch {
+	case isImageType(mimeType):
+		return "image"
+	case strings.HasPrefix(strings.ToLower(mimeType), "video/"):
+		return "video"
+	case strings.HasPrefix(strings.ToLower(mimeType), "audio/"):
+		return "audio"
+	case mimeType == "":
+		return ""
+	default:
+		return "document"
+	}
+}
+
+// mediumFromExtension guesses the medium of a media URL from its file extension
+func mediumFromExtension(mediaURL string) string {
+	ext := strings.ToLower(path.Ext(strings.SplitN(mediaURL, "?", 2)[0]))
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".avif":
+		return "image"
+	case ".mp4", ".m4v", ".mov", ".webm":
+		return "video"
+	case ".mp3", ".m4a", ".aac", ".ogg", ".wav":
+		return "audio"
+	default:
+		return "document"
+	}
+}
+
+// ExtractPostContent fetches the post's webpage and fills in the full content and main image.
+// The extracted Open Graph image takes priority over any image embedded in the feed, while the
+// page's publish date, title, author and other metadata are only used when the feed did not
+// provide them.
+func ExtractPostContent(ctx context.Context, post *models.CreateInspirationFeedPostRequest, contentExtractor *ContentExtractor) error {
+	if post.URL == "" {
+		return nil
+	}
+
+	extracted, err := contentExtractor.ExtractContentFromURL(ctx, post.URL)
+	if err != nil {
+		return err
+	}
+
+	// Use extracted Open Graph image as priority
+	if extracted.ImageURL != "" {
+		post.ImageURL = &extracted.ImageURL
+	}
+
+	// Use extracted HTML content as full content
+	if extracted.FullContent != "" {
+		post.FullContent = &extracted.FullContent
+	}
+
+	if post.Author == nil && extracted.Author != "" {
+		post.Author = &extracted.Author
+	}
+
+	if extracted.Title != "" && isPlaceholderTitle(post.Title, post.URL) {
+		post.Title = extracted.Title
+	}
+
+	if post.PublishedAt == nil && extracted.PublishedAt != "" {
+		if parsedTime, err := ParseDate(extracted.PublishedAt); err == nil {
+			pubDate := parsedTime.Format(time.RFC3339)
+			post.PublishedAt = &pubDate
+		}
+	}
+
+	if extracted.Metadata != nil {
+		fillFromMetadata(post, extracted.Metadata)
+	}
+
+	return nil
+}
+
+// fillFromMetadata fills the post fields the feed left empty from the page's declared metadata
+func fillFromMetadata(post *models.CreateInspirationFeedPostRequest, metadata *PageMetadata) {
+	if post.Description == nil && metadata.Description != "" {
+		description := cleanString(metadata.Description)
+		post.Description = &description
+	}
+
+	if len(post.Categories) == 0 {
+		post.Categories = cleanCategories(append([]string{metadata.Section}, metadata.Tags...))
+	}
+
+	if post.Language == nil && metadata.Language != "" {
+		post.Language = &metadata.Language
+	}
+
+	if post.CanonicalURL == nil && metadata.CanonicalURL != "" {
+		post.CanonicalURL = &metadata.CanonicalURL
+	}
+}
+
 // cleanString removes extra whitespace and HTML tags
 func cleanString(s string) string {
 	// Remove leading/trailing whitespace
@@ -198,31 +468,6 @@ func cleanString(s string) string {
 	return strings.TrimSpace(s)
 }
 
-// parseRSSDate tries to parse common RSS and Atom date formats
-func parseRSSDate(dateStr string) (time.Time, error) {
-	// Common RSS and Atom date formats
-	formats := []string{
-		time.RFC3339,  // "2006-01-02T15:04:05Z07:00" (Atom standard)
-		time.RFC3339Nano, // "2006-01-02T15:04:05.999999999Z07:00" (Atom with nanoseconds)
-		time.RFC1123Z, // "Mon, 02 Jan 2006 15:04:05 -0700" (RSS standard)
-		time.RFC1123,  // "Mon, 02 Jan 2006 15:04:05 MST"
-		"2006-01-02T15:04:05-07:00", // Atom without Z
-		"2006-01-02T15:04:05.000Z", // Atom with milliseconds
-		"2006-01-02 15:04:05 -0700",
-		"2006-01-02 15:04:05",
-		"Mon, 2 Jan 2006 15:04:05 -0700",
-		"Mon, 2 Jan 2006 15:04:05 MST",
-	}
-
-	for _, format := range formats {
-		if t, err := time.Parse(format, dateStr); err == nil {
-			return t, nil
-		}
-	}
-
-	return time.Time{}, fmt.Errorf("unable to parse date: %s", dateStr)
-}
-
 // convertAtomToRSS converts an Atom feed to RSS format for consistent processing
 func convertAtomToRSS(atomFeed *models.AtomFeed) *models.RSSFeed {
 	rss := &models.RSSFeed{
@@ -249,6 +494,10 @@ func convertAtomToRSS(atomFeed *models.AtomFeed) *models.RSSFeed {
 			GUID:        entry.ID,
 		}
 
+		for _, category := range entry.Category {
+			item.Categories = append(item.Categories, category.Term)
+		}
+
 		// Handle published date - prefer published over updated
 		if entry.Published != "" {
 			item.PubDate = entry.Published
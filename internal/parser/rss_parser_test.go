@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"strandnerd-crawler/internal/config"
+)
+
+const testRSSFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+	<title>Test Feed</title>
+	<link>https://example.com</link>
+	<item>
+		<title>First post</title>
+		<link>https://example.com/first</link>
+		<guid>first</guid>
+	</item>
+</channel>
+</rss>`
+
+func TestParseFeedConditionalGet(t *testing.T) {
+	const etag = `"abc123"`
+	const lastModified = "Tue, 05 Mar 2024 10:00:00 GMT"
+
+	var gotIfNoneMatch, gotIfModifiedSince string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIfNoneMatch = r.Header.Get("If-None-Match")
+		gotIfModifiedSince = r.Header.Get("If-Modified-Since")
+		if gotIfNoneMatch == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write([]byte(testRSSFeed))
+	}))
+	defer server.Close()
+
+	p := NewRSSParser(&http.Client{Timeout: 5 * time.Second}, &config.Config{UserAgent: "test"})
+
+	feed, err := p.ParseFeed(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("First fetch failed: %v", err)
+	}
+	if len(feed.Items) != 1 {
+		t.Fatalf("Expected 1 item, got %d", len(feed.Items))
+	}
+	if gotIfNoneMatch != "" || gotIfModifiedSince != "" {
+		t.Errorf("First fetch should not be conditional, got If-None-Match=%q If-Modified-Since=%q", gotIfNoneMatch, gotIfModifiedSince)
+	}
+
+	_, err = p.ParseFeed(context.Background(), server.URL)
+	if !errors.Is(err, ErrNotModified) {
+		t.Fatalf("Expected ErrNotModified on second fetch, got %v", err)
+	}
+	if gotIfModifiedSince != lastModified {
+		t.Errorf("Expected If-Modified-Since %q, got %q", lastModified, gotIfModifiedSince)
+	}
+}
+
+const testNamespacedRSSFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"
+	xmlns:content="http://purl.org/rss/1.0/modules/content/"
+	xmlns:dc="http://purl.org/dc/elements/1.1/"
+	xmlns:media="http://search.yahoo.com/mrss/"
+	xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+<channel>
+	<title>Namespaced Feed</title>
+	<item>
+		<title>Article title</title>
+		<media:title>Media title</media:title>
+		<itunes:title>iTunes title</itunes:title>
+		<link>https://example.com/article</link>
+		<description>Short summary</description>
+		<content:encoded><![CDATA[<p>Full <b>article</b> body</p>]]></content:encoded>
+		<author>editor@example.com (Editor)</author>
+		<dc:creator>Jane Doe</dc:creator>
+		<dc:creator>John Roe</dc:creator>
+		<dc:date>2024-03-05T10:00:00Z</dc:date>
+		<category>Politics</category>
+		<category>politics</category>
+		<dc:subject>Elections</dc:subject>
+		<media:group>
+			<media:content url="https://example.com/small.jpg" medium="image" width="320" height="180"/>
+			<media:content url="https://example.com/large.jpg" medium="image" width="1280" height="720"/>
+			<media:content url="https://example.com/clip.mp4" type="video/mp4" width="1920" height="1080">
+				<media:thumbnail url="https://example.com/clip-thumb.jpg" width="1920" height="1080"/>
+			</media:content>
+		</media:group>
+		<enclosure url="https://example.com/episode" length="123" />
+	</item>
+</channel>
+</rss>`
+
+func TestParseNamespacedRSS(t *testing.T) {
+	feed, err := parseFeedBody("application/rss+xml", []byte(testNamespacedRSSFeed))
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+	if len(feed.Items) != 1 {
+		t.Fatalf("Expected 1 item, got %d", len(feed.Items))
+	}
+
+	posts := ConvertToInspirationPosts("feed-1", feed.Items)
+	if len(posts) != 1 {
+		t.Fatalf("Expected 1 post, got %d", len(posts))
+	}
+	post := posts[0]
+
+	if post.Title != "Article title" {
+		t.Errorf("Expected title from the RSS element, got %q", post.Title)
+	}
+	if post.Content == nil || *post.Content != "Full article body" {
+		t.Errorf("Expected content:encoded as content, got %v", post.Content)
+	}
+	if post.Author == nil || *post.Author != "Jane Doe, John Roe" {
+		t.Errorf("Expected dc:creator as author, got %v", post.Author)
+	}
+	if post.PublishedAt == nil || *post.PublishedAt != "2024-03-05T10:00:00Z" {
+		t.Errorf("Expected dc:date as published date, got %v", post.PublishedAt)
+	}
+
+	expectedCategories := []string{"Politics", "Elections"}
+	if len(post.Categories) != len(expectedCategories) {
+		t.Fatalf("Expected categories %v, got %v", expectedCategories, post.Categories)
+	}
+	for i, category := range expectedCategories {
+		if post.Categories[i] != category {
+			t.Errorf("Expected categories %v, got %v", expectedCategories, post.Categories)
+		}
+	}
+
+	if len(post.Media) != 5 {
+		t.Fatalf("Expected 5 media items, got %d: %+v", len(post.Media), post.Media)
+	}
+	if post.Media[1].Width != 1280 || post.Media[1].Height != 720 {
+		t.Errorf("Expected media dimensions to be kept, got %+v", post.Media[1])
+	}
+	if enclosure := post.Media[4]; enclosure.Medium != "document" {
+		t.Errorf("Expected untyped enclosure not to be treated as an image, got %+v", enclosure)
+	}
+	if post.ImageURL == nil || *post.ImageURL != "https://example.com/large.jpg" {
+		t.Errorf("Expected the largest image as post image, got %v", post.ImageURL)
+	}
+}
@@ -0,0 +1,755 @@
+package parser
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// Selector is a compiled CSS Level 3 selector group, such as "article > p:first-of-type, .body".
+// It supports type, universal, class, id and attribute selectors (=, ~=, |=, ^=, $=, *=, with an
+// optional i flag), the descendant, child (>), adjacent (+) and general (~) sibling combinators,
+// :not() and the structural pseudo-classes. Pseudo-elements are rejected; dynamic pseudo-classes
+// such as :hover parse but never match a static document.
+type Selector struct {
+	source    string
+	complexes []complexSelector
+}
+
+// complexSelector is a chain of compound selectors joined by combinators
+type complexSelector struct {
+	compounds   []compoundSelector
+	combinators []byte // combinators[i] joins compounds[i] and compounds[i+1]: ' ', '>', '+' or '~'
+}
+
+// compoundSelector is a sequence of simple selectors that must all match the same element
+type compoundSelector struct {
+	tag      string // lowercased type selector, "" for any element
+	matchers []func(*html.Node) bool
+}
+
+// CompileSelector parses a CSS selector group
+func CompileSelector(selector string) (*Selector, error) {
+	p := &selectorParser{src: selector}
+	p.skipWhitespace()
+
+	complexes, err := p.parseGroup()
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+	}
+	if !p.eof() {
+		return nil, fmt.Errorf("invalid selector %q: unexpected %q at offset %d", selector, p.peek(), p.pos)
+	}
+
+	return &Selector{source: selector, complexes: complexes}, nil
+}
+
+// String returns the selector's source text
+func (s *Selector) String() string {
+	return s.source
+}
+
+// Match reports whether the element matches the selector
+func (s *Selector) Match(n *html.Node) bool {
+	if n == nil || n.Type != html.ElementNode {
+		return false
+	}
+	for _, complex := range s.complexes {
+		if complex.matchAt(n, len(complex.compounds)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchFirst returns the first element in document order, starting with root itself, that
+// matches the selector
+func (s *Selector) MatchFirst(root *html.Node) *html.Node {
+	if s.Match(root) {
+		return root
+	}
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		if found := s.MatchFirst(c); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// MatchAll returns all elements in document order, including root itself, that match the selector
+func (s *Selector) MatchAll(root *html.Node) []*html.Node {
+	var matches []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if s.Match(n) {
+			matches = append(matches, n)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return matches
+}
+
+// compiledSelectors caches selectors by source; invalid selectors are cached as nil
+var compiledSelectors sync.Map
+
+// cachedSelector compiles a selector once, logging and returning nil if it is invalid
+func cachedSelector(selector string) *Selector {
+	if cached, ok := compiledSelectors.Load(selector); ok {
+		return cached.(*Selector)
+	}
+
+	compiled, err := CompileSelector(selector)
+	if err != nil {
+		log.Printf("Warning: ignoring %v", err)
+	}
+	compiledSelectors.Store(selector, compiled)
+	return compiled
+}
+
+// matchAt matches the compound at index i against n and the rest of the chain against n's
+// ancestors and siblings, backtracking for descendant and general sibling combinators
+func (c complexSelector) matchAt(n *html.Node, i int) bool {
+	if !c.compounds[i].match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+
+	switch c.combinators[i-1] {
+	case '>':
+		parent := parentElement(n)
+		return parent != nil && c.matchAt(parent, i-1)
+	case '+':
+		previous := previousElementSibling(n)
+		return previous != nil && c.matchAt(previous, i-1)
+	case '~':
+		for previous := previousElementSibling(n); previous != nil; previous = previousElementSibling(previous) {
+			if c.matchAt(previous, i-1) {
+				return true
+			}
+		}
+		return false
+	default:
+		for ancestor := parentElement(n); ancestor != nil; ancestor = parentElement(ancestor) {
+			if c.matchAt(ancestor, i-1) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// match reports whether the element matches every simple selector of the compound
+func (c compoundSelector) match(n *html.Node) bool {
+	if n.Type != html.ElementNode || (c.tag != "" && n.Data != c.tag) {
+		return false
+	}
+	for _, matcher := range c.matchers {
+		if !matcher(n) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectorParser is a recursive descent parser for selector groups
+type selectorParser struct {
+	src string
+	pos int
+}
+
+// parseGroup parses a comma-separated list of complex selectors, stopping at ")" or the end
+func (p *selectorParser) parseGroup() ([]complexSelector, error) {
+	var complexes []complexSelector
+	for {
+		complex, err := p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		complexes = append(complexes, complex)
+
+		p.skipWhitespace()
+		if p.eof() || p.peek() != ',' {
+			return complexes, nil
+		}
+		p.pos++
+		p.skipWhitespace()
+	}
+}
+
+// parseComplex parses compound selectors joined by combinators
+func (p *selectorParser) parseComplex() (complexSelector, error) {
+	var complex complexSelector
+
+	compound, err := p.parseCompound()
+	if err != nil {
+		return complex, err
+	}
+	complex.compounds = append(complex.compounds, compound)
+
+	for {
+		sawWhitespace := p.skipWhitespace()
+		if p.eof() || p.peek() == ',' || p.peek() == ')' {
+			return complex, nil
+		}
+
+		combinator := byte(' ')
+		switch p.peek() {
+		case '>', '+', '~':
+			combinator = p.peek()
+			p.pos++
+			p.skipWhitespace()
+		default:
+			if !sawWhitespace {
+				return complex, fmt.Errorf("unexpected %q at offset %d", p.peek(), p.pos)
+			}
+		}
+
+		compound, err := p.parseCompound()
+		if err != nil {
+			return complex, err
+		}
+		complex.compounds = append(complex.compounds, compound)
+		complex.combinators = append(complex.combinators, combinator)
+	}
+}
+
+// parseCompound parses an optional type selector followed by id, class, attribute and
+// pseudo-class selectors
+func (p *selectorParser) parseCompound() (compoundSelector, error) {
+	var compound compoundSelector
+	start := p.pos
+
+	if !p.eof() && p.peek() == '*' {
+		p.pos++
+	} else if p.atIdentStart() {
+		tag, err := p.parseIdent()
+		if err != nil {
+			return compound, err
+		}
+		compound.tag = strings.ToLower(tag)
+	}
+
+	for !p.eof() {
+		var matcher func(*html.Node) bool
+		var err error
+
+		switch p.peek() {
+		case '#':
+			p.pos++
+			var id string
+			if id, err = p.parseIdent(); err == nil {
+				matcher = func(n *html.Node) bool { return getAttr(n, "id") == id }
+			}
+		case '.':
+			p.pos++
+			var class string
+			if class, err = p.parseIdent(); err == nil {
+				matcher = func(n *html.Node) bool { return hasClass(n, class) }
+			}
+		case '[':
+			matcher, err = p.parseAttribute()
+		case ':':
+			matcher, err = p.parsePseudoClass()
+		default:
+			if p.pos == start {
+				return compound, fmt.Errorf("expected a selector at offset %d, found %q", p.pos, p.peek())
+			}
+			return compound, nil
+		}
+
+		if err != nil {
+			return compound, err
+		}
+		compound.matchers = append(compound.matchers, matcher)
+	}
+
+	if p.pos == start {
+		return compound, fmt.Errorf("expected a selector at offset %d", p.pos)
+	}
+	return compound, nil
+}
+
+// parseAttribute parses an attribute selector such as [data-testid='body' i]
+func (p *selectorParser) parseAttribute() (func(*html.Node) bool, error) {
+	p.pos++ // [
+	p.skipWhitespace()
+
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	name = strings.ToLower(name)
+	p.skipWhitespace()
+
+	if p.eof() {
+		return nil, fmt.Errorf("unterminated attribute selector")
+	}
+	if p.peek() == ']' {
+		p.pos++
+		return func(n *html.Node) bool {
+			_, ok := attrLookup(n, name)
+			return ok
+		}, nil
+	}
+
+	var operator string
+	for _, op := range []string{"=", "~=", "|=", "^=", "$=", "*="} {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			operator = op
+		}
+	}
+	if operator == "" {
+		return nil, fmt.Errorf("unexpected %q in attribute selector at offset %d", p.peek(), p.pos)
+	}
+	p.pos += len(operator)
+	p.skipWhitespace()
+
+	var value string
+	if !p.eof() && (p.peek() == '"' || p.peek() == '\'') {
+		value, err = p.parseString()
+	} else {
+		value, err = p.parseIdent()
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+
+	caseInsensitive := false
+	if !p.eof() && (p.peek() == 'i' || p.peek() == 'I') {
+		caseInsensitive = true
+		p.pos++
+		p.skipWhitespace()
+	} else if !p.eof() && (p.peek() == 's' || p.peek() == 'S') {
+		p.pos++
+		p.skipWhitespace()
+	}
+
+	if p.eof() || p.peek() != ']' {
+		return nil, fmt.Errorf("unterminated attribute selector")
+	}
+	p.pos++
+
+	if caseInsensitive {
+		value = strings.ToLower(value)
+	}
+
+	return func(n *html.Node) bool {
+		actual, ok := attrLookup(n, name)
+		if !ok {
+			return false
+		}
+		if caseInsensitive {
+			actual = strings.ToLower(actual)
+		}
+		return matchAttributeValue(operator, actual, value)
+	}, nil
+}
+
+// matchAttributeValue applies an attribute selector operator
+func matchAttributeValue(operator, actual, value string) bool {
+	switch operator {
+	case "=":
+		return actual == value
+	case "~=":
+		for _, word := range strings.Fields(actual) {
+			if word == value {
+				return true
+			}
+		}
+		return false
+	case "|=":
+		return actual == value || strings.HasPrefix(actual, value+"-")
+	case "^=":
+		return value != "" && strings.HasPrefix(actual, value)
+	case "$=":
+		return value != "" && strings.HasSuffix(actual, value)
+	default: // *=
+		return value != "" && strings.Contains(actual, value)
+	}
+}
+
+// parsePseudoClass parses a pseudo-class such as :first-child, :nth-of-type(2n+1) or :not(.ad)
+func (p *selectorParser) parsePseudoClass() (func(*html.Node) bool, error) {
+	p.pos++ // :
+	if !p.eof() && p.peek() == ':' {
+		return nil, fmt.Errorf("pseudo-elements are not supported (offset %d)", p.pos-1)
+	}
+
+	name, err := p.parseIdent()
+	if err != nil {
+		return nil, err
+	}
+	name = strings.ToLower(name)
+
+	if !p.eof() && p.peek() == '(' {
+		p.pos++
+		p.skipWhitespace()
+		matcher, err := p.parseFunctionalPseudoClass(name)
+		if err != nil {
+			return nil, err
+		}
+		p.skipWhitespace()
+		if p.eof() || p.peek() != ')' {
+			return nil, fmt.Errorf("missing ) after :%s(", name)
+		}
+		p.pos++
+		return matcher, nil
+	}
+
+	switch name {
+	case "root":
+		return func(n *html.Node) bool { return parentElement(n) == nil }, nil
+	case "empty":
+		return isEmptyElement, nil
+	case "first-child":
+		return nthMatcher(0, 1, false, false), nil
+	case "last-child":
+		return nthMatcher(0, 1, true, false), nil
+	case "only-child":
+		return func(n *html.Node) bool {
+			return nthMatcher(0, 1, false, false)(n) && nthMatcher(0, 1, true, false)(n)
+		}, nil
+	case "first-of-type":
+		return nthMatcher(0, 1, false, true), nil
+	case "last-of-type":
+		return nthMatcher(0, 1, true, true), nil
+	case "only-of-type":
+		return func(n *html.Node) bool {
+			return nthMatcher(0, 1, false, true)(n) && nthMatcher(0, 1, true, true)(n)
+		}, nil
+	case "link", "any-link":
+		return func(n *html.Node) bool {
+			_, ok := attrLookup(n, "href")
+			return ok && (n.Data == "a" || n.Data == "area" || n.Data == "link")
+		}, nil
+	case "checked":
+		return func(n *html.Node) bool {
+			_, checked := attrLookup(n, "checked")
+			_, selected := attrLookup(n, "selected")
+			return (n.Data == "input" && checked) || (n.Data == "option" && selected)
+		}, nil
+	case "disabled":
+		return func(n *html.Node) bool {
+			_, ok := attrLookup(n, "disabled")
+			return ok
+		}, nil
+	case "enabled":
+		return func(n *html.Node) bool {
+			_, ok := attrLookup(n, "disabled")
+			return !ok && isFormControl(n)
+		}, nil
+	case "hover", "active", "focus", "visited", "target", "focus-within", "focus-visible":
+		// A fetched document has no user interaction state
+		return func(*html.Node) bool { return false }, nil
+	default:
+		return nil, fmt.Errorf("unsupported pseudo-class :%s", name)
+	}
+}
+
+// parseFunctionalPseudoClass parses the argument of :not(), :nth-*() and :lang()
+func (p *selectorParser) parseFunctionalPseudoClass(name string) (func(*html.Node) bool, error) {
+	switch name {
+	case "not":
+		complexes, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		negated := &Selector{complexes: complexes}
+		return func(n *html.Node) bool { return !negated.Match(n) }, nil
+
+	case "nth-child", "nth-last-child", "nth-of-type", "nth-last-of-type":
+		end := strings.IndexByte(p.src[p.pos:], ')')
+		if end == -1 {
+			return nil, fmt.Errorf("missing ) after :%s(", name)
+		}
+		a, b, err := parseNth(p.src[p.pos : p.pos+end])
+		if err != nil {
+			return nil, err
+		}
+		p.pos += end
+		fromEnd := strings.Contains(name, "last")
+		ofType := strings.HasSuffix(name, "of-type")
+		return nthMatcher(a, b, fromEnd, ofType), nil
+
+	case "lang":
+		lang, err := p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+		lang = strings.ToLower(lang)
+		return func(n *html.Node) bool {
+			for e := n; e != nil; e = parentElement(e) {
+				if value, ok := attrLookup(e, "lang"); ok {
+					value = strings.ToLower(value)
+					return value == lang || strings.HasPrefix(value, lang+"-")
+				}
+			}
+			return false
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported pseudo-class :%s()", name)
+	}
+}
+
+// parseNth parses the an+b argument of the :nth-* pseudo-classes, including odd and even
+func parseNth(arg string) (int, int, error) {
+	s := strings.ToLower(strings.Join(strings.Fields(arg), ""))
+	switch s {
+	case "odd":
+		return 2, 1, nil
+	case "even":
+		return 2, 0, nil
+	case "":
+		return 0, 0, fmt.Errorf("empty nth expression")
+	}
+
+	n := strings.IndexByte(s, 'n')
+	if n == -1 {
+		b, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid nth expression %q", arg)
+		}
+		return 0, b, nil
+	}
+
+	var a int
+	switch coefficient := s[:n]; coefficient {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		parsed, err := strconv.Atoi(coefficient)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid nth expression %q", arg)
+		}
+		a = parsed
+	}
+
+	b := 0
+	if rest := s[n+1:]; rest != "" {
+		if rest[0] != '+' && rest[0] != '-' {
+			return 0, 0, fmt.Errorf("invalid nth expression %q", arg)
+		}
+		parsed, err := strconv.Atoi(rest)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid nth expression %q", arg)
+		}
+		b = parsed
+	}
+
+	return a, b, nil
+}
+
+// nthMatcher matches elements whose 1-based position among their (same type) siblings,
+// counted from the start or the end, is a*k+b for some k >= 0
+func nthMatcher(a, b int, fromEnd, ofType bool) func(*html.Node) bool {
+	return func(n *html.Node) bool {
+		if n.Parent == nil {
+			return false
+		}
+
+		position := 1
+		sibling := func(s *html.Node) *html.Node { return s.PrevSibling }
+		if fromEnd {
+			sibling = func(s *html.Node) *html.Node { return s.NextSibling }
+		}
+		for s := sibling(n); s != nil; s = sibling(s) {
+			if s.Type == html.ElementNode && (!ofType || s.Data == n.Data) {
+				position++
+			}
+		}
+
+		if a == 0 {
+			return position == b
+		}
+		k := (position - b) / a
+		return k >= 0 && a*k+b == position
+	}
+}
+
+// isEmptyElement reports whether an element has no child elements or text
+func isEmptyElement(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode || (c.Type == html.TextNode && c.Data != "") {
+			return false
+		}
+	}
+	return true
+}
+
+// isFormControl reports whether an element can be enabled or disabled
+func isFormControl(n *html.Node) bool {
+	switch n.Data {
+	case "button", "input", "select", "textarea", "option", "optgroup", "fieldset":
+		return true
+	}
+	return false
+}
+
+// attrLookup returns an element's attribute value and whether it is set
+func attrLookup(n *html.Node, key string) (string, bool) {
+	for _, attr := range n.Attr {
+		if attr.Namespace == "" && attr.Key == key {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+// parentElement returns an element's parent element, or nil at the root element
+func parentElement(n *html.Node) *html.Node {
+	if n.Parent != nil && n.Parent.Type == html.ElementNode {
+		return n.Parent
+	}
+	return nil
+}
+
+// previousElementSibling returns the closest preceding sibling element
+func previousElementSibling(n *html.Node) *html.Node {
+	for s := n.PrevSibling; s != nil; s = s.PrevSibling {
+		if s.Type == html.ElementNode {
+			return s
+		}
+	}
+	return nil
+}
+
+func (p *selectorParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *selectorParser) peek() byte {
+	return p.src[p.pos]
+}
+
+// skipWhitespace skips whitespace and reports whether there was any
+func (p *selectorParser) skipWhitespace() bool {
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\n\r\f", p.peek()) != -1 {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+// atIdentStart reports whether an identifier starts at the current position
+func (p *selectorParser) atIdentStart() bool {
+	if p.eof() {
+		return false
+	}
+	c := p.peek()
+	if c == '-' && p.pos+1 < len(p.src) {
+		c = p.src[p.pos+1]
+		if c == '-' {
+			return true
+		}
+	}
+	return isNameStart(c) || c == '\\'
+}
+
+// parseIdent parses a CSS identifier, resolving backslash escapes
+func (p *selectorParser) parseIdent() (string, error) {
+	if !p.atIdentStart() {
+		if p.eof() {
+			return "", fmt.Errorf("expected an identifier at the end of the selector")
+		}
+		return "", fmt.Errorf("expected an identifier at offset %d, found %q", p.pos, p.peek())
+	}
+
+	var ident strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case c == '\\':
+			r, err := p.parseEscape()
+			if err != nil {
+				return "", err
+			}
+			ident.WriteRune(r)
+		case isNameStart(c) || c == '-' || (c >= '0' && c <= '9'):
+			ident.WriteByte(c)
+			p.pos++
+		default:
+			return ident.String(), nil
+		}
+	}
+	return ident.String(), nil
+}
+
+// parseString parses a single or double quoted string
+func (p *selectorParser) parseString() (string, error) {
+	quote := p.peek()
+	p.pos++
+
+	var value strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case c == quote:
+			p.pos++
+			return value.String(), nil
+		case c == '\n':
+			return "", fmt.Errorf("newline in string at offset %d", p.pos)
+		case c == '\\':
+			r, err := p.parseEscape()
+			if err != nil {
+				return "", err
+			}
+			value.WriteRune(r)
+		default:
+			value.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// parseEscape parses a backslash escape: up to six hex digits and an optional space, or a
+// single literal character
+func (p *selectorParser) parseEscape() (rune, error) {
+	p.pos++ // backslash
+	if p.eof() {
+		return 0, fmt.Errorf("incomplete escape at the end of the selector")
+	}
+
+	end := p.pos
+	for end < len(p.src) && end-p.pos < 6 && isHexDigit(p.src[end]) {
+		end++
+	}
+	if end > p.pos {
+		code, _ := strconv.ParseUint(p.src[p.pos:end], 16, 32)
+		p.pos = end
+		if !p.eof() && (p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\n') {
+			p.pos++
+		}
+		if code == 0 || code > utf8.MaxRune {
+			return utf8.RuneError, nil
+		}
+		return rune(code), nil
+	}
+
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	return r, nil
+}
+
+// isNameStart reports whether c can start an identifier: a letter, underscore or non-ASCII byte
+func isNameStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c >= utf8.RuneSelf
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
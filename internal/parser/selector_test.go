@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const testSelectorDocument = `<!DOCTYPE html>
+<html lang="en-GB">
+<body>
+	<div id="header" class="content-header site">Header</div>
+	<div id="body" class="article body" data-testid="ArticleBody">
+		<p id="p1" class="lead">First</p>
+		<p id="p2">Second <a id="link" href="/more">more</a></p>
+		<span id="s1"></span>
+		<p id="p3" data-tags="news world-news">Third</p>
+		<p id="p4" lang="de">Fourth</p>
+	</div>
+	<aside id="aside" class="related"><p id="p5">Related</p></aside>
+</body>
+</html>`
+
+func TestSelectorMatching(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(testSelectorDocument))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	tests := []struct {
+		selector string
+		expected string
+	}{
+		{"p", "p1 p2 p3 p4 p5"},
+		{"DIV.article.body", "body"},
+		{".content-header + div", "body"},
+		{"#header ~ aside p", "p5"},
+		{"div > p:first-child", "p1"},
+		{"#body > :last-child", "p4"},
+		{"p:nth-child(2n+1)", "p1 p4 p5"},
+		{"p:nth-of-type(even)", "p2 p4"},
+		{"p:nth-last-of-type(1)", "p4 p5"},
+		{"#body p:not(.lead):not([lang])", "p2 p3"},
+		{"p:not(#p1, #p2)", "p3 p4 p5"},
+		{"span:empty, aside:only-of-type", "s1 aside"},
+		{"[data-testid='articlebody' i]", "body"},
+		{`[data-testid="ArticleBody"]`, "body"},
+		{"[data-tags~=news]", "p3"},
+		{"[data-tags*=world]", "p3"},
+		{"[class^=content]", "header"},
+		{"[class$=body]", "body"},
+		{"[lang|=de]", "p4"},
+		{"p:lang(en)", "p1 p2 p3 p5"},
+		{"a:link", "link"},
+		{"body *:hover", ""},
+		{"#\\62 ody", "body"},
+	}
+
+	for _, tt := range tests {
+		selector, err := CompileSelector(tt.selector)
+		if err != nil {
+			t.Errorf("CompileSelector(%q) failed: %v", tt.selector, err)
+			continue
+		}
+
+		var ids []string
+		for _, n := range selector.MatchAll(doc) {
+			ids = append(ids, getAttr(n, "id"))
+		}
+		if got := strings.Join(ids, " "); got != tt.expected {
+			t.Errorf("%q matched %q, expected %q", tt.selector, got, tt.expected)
+		}
+	}
+}
+
+func TestCompileSelectorErrors(t *testing.T) {
+	for _, selector := range []string{"", "p >", "div..x", "[href", "p::before", "p:nth-child(x)", "p:unknown", "a, ", ":not(p", "p)"} {
+		if _, err := CompileSelector(selector); err == nil {
+			t.Errorf("CompileSelector(%q) should fail", selector)
+		}
+	}
+}
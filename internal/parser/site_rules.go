@@ -0,0 +1,408 @@
+package parser
+
+import (
+	_ "embed"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v3"
+
+	"strandnerd-crawler/internal/models"
+)
+
+//go:embed rules/builtin.yaml
+var builtinRulesYAML []byte
+
+// siteRulesReloadInterval is how often the rules directory is checked for changed files
+const siteRulesReloadInterval = 30 * time.Second
+
+// Sources of site rules, by increasing precedence
+const (
+	siteRuleSourceBuiltin = "built-in"
+	siteRuleSourceCMS     = "cms"
+)
+
+// loadedRule is a site rule with where it came from and what is wrong with it. Rules with
+// problems are kept for the validation report but never used for extraction.
+type loadedRule struct {
+	rule     models.SiteRule
+	source   string // siteRuleSource constant or the rule file's path
+	problems []string
+}
+
+// SiteRules resolves the extraction rule for a page. Rules fetched from the CMS take
+// precedence over the rules directory, which takes precedence over the built-in rules; a rule
+// replaces the lower ranked rules of the domains it lists. The rules directory is reloaded when
+// its files change, so edited rules apply without a restart.
+type SiteRules struct {
+	dir        string
+	builtin    []loadedRule
+	dirRules   []loadedRule
+	cmsRules   []loadedRule
+	dirVersion string // names, sizes and modification times of the rule files last loaded
+	checkedAt  time.Time
+	index      map[string]*models.SiteRule // domain -> rule
+	mutex      sync.RWMutex
+}
+
+// NewSiteRules loads the built-in rules and the rules in dir, if set
+func NewSiteRules(dir string) *SiteRules {
+	builtin, err := parseSiteRules(builtinRulesYAML, siteRuleSourceBuiltin)
+	if err != nil {
+		log.Panicf("failed to parse built-in site rules: %v", err)
+	}
+
+	r := &SiteRules{dir: dir, builtin: builtin}
+	r.reloadDir()
+	r.rebuildIndex()
+	return r
+}
+
+// Lookup returns the rule for a page URL: the rule of its host name or of the closest parent
+// domain, or nil if no rule covers the site
+func (r *SiteRules) Lookup(pageURL string) *models.SiteRule {
+	if r == nil || pageURL == "" {
+		return nil
+	}
+	r.reloadIfChanged()
+
+	parsedURL, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	hostname := strings.TrimSuffix(strings.ToLower(parsedURL.Hostname()), ".")
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for domain := hostname; domain != ""; {
+		if rule, ok := r.index[domain]; ok {
+			return rule
+		}
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return nil
+}
+
+// SetTenantRules replaces the rules fetched from the tenant's CMS
+func (r *SiteRules) SetTenantRules(rules []models.SiteRule) {
+	loaded := make([]loadedRule, 0, len(rules))
+	for _, rule := range rules {
+		loaded = append(loaded, newLoadedRule(rule, siteRuleSourceCMS))
+	}
+	logRuleProblems(loaded)
+
+	r.mutex.Lock()
+	r.cmsRules = loaded
+	r.mutex.Unlock()
+
+	r.rebuildIndex()
+}
+
+// all returns every loaded rule, including overridden rules and rules with problems
+func (r *SiteRules) all() []loadedRule {
+	r.reloadIfChanged()
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	rules := make([]loadedRule, 0, len(r.builtin)+len(r.dirRules)+len(r.cmsRules))
+	rules = append(rules, r.builtin...)
+	rules = append(rules, r.dirRules...)
+	return append(rules, r.cmsRules...)
+}
+
+// reloadIfChanged reloads the rules directory when it was last checked long enough ago and
+// its files have changed since
+func (r *SiteRules) reloadIfChanged() {
+	if r.dir == "" {
+		return
+	}
+
+	r.mutex.Lock()
+	if time.Since(r.checkedAt) < siteRulesReloadInterval {
+		r.mutex.Unlock()
+		return
+	}
+	r.checkedAt = time.Now()
+	r.mutex.Unlock()
+
+	if r.reloadDir() {
+		r.rebuildIndex()
+	}
+}
+
+// reloadDir loads the rules directory if its files changed and reports whether they did.
+// A directory that cannot be read keeps the previously loaded rules.
+func (r *SiteRules) reloadDir() bool {
+	if r.dir == "" {
+		return false
+	}
+
+	files, version, err := siteRuleFiles(r.dir)
+	if err != nil {
+		version = "error: " + err.Error()
+	}
+
+	r.mutex.RLock()
+	unchanged := version == r.dirVersion
+	r.mutex.RUnlock()
+	if unchanged {
+		return false
+	}
+
+	if err != nil {
+		log.Printf("Warning: failed to read site rules directory %s, keeping the loaded rules: %v", r.dir, err)
+		r.mutex.Lock()
+		r.dirVersion = version
+		r.mutex.Unlock()
+		return false
+	}
+
+	var loaded []loadedRule
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			loaded = append(loaded, loadedRule{source: file, problems: []string{fmt.Sprintf("failed to read rule file: %v", err)}})
+			continue
+		}
+		rules, err := parseSiteRules(data, file)
+		if err != nil {
+			loaded = append(loaded, loadedRule{source: file, problems: []string{err.Error()}})
+			continue
+		}
+		loaded = append(loaded, rules...)
+	}
+	logRuleProblems(loaded)
+
+	r.mutex.Lock()
+	r.dirRules = loaded
+	r.dirVersion = version
+	r.mutex.Unlock()
+
+	log.Printf("📐 Loaded %d site rules from %s", len(loaded), r.dir)
+	return true
+}
+
+// rebuildIndex maps every domain to its rule of highest precedence
+func (r *SiteRules) rebuildIndex() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	index := make(map[string]*models.SiteRule)
+	for _, layer := range [][]loadedRule{r.builtin, r.dirRules, r.cmsRules} {
+		for i := range layer {
+			if len(layer[i].problems) > 0 {
+				continue
+			}
+			for _, domain := range layer[i].rule.Domains {
+				index[normalizeRuleDomain(domain)] = &layer[i].rule
+			}
+		}
+	}
+	r.index = index
+}
+
+// siteRuleFiles lists the YAML and JSON files of a rules directory, sorted by name, together
+// with a version string that changes whenever one of them is added, removed or modified
+func siteRuleFiles(dir string) ([]string, string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var files []string
+	var version strings.Builder
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.IsDir() {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+		fmt.Fprintf(&version, "%s:%d:%d;", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	sort.Strings(files)
+
+	return files, version.String(), nil
+}
+
+// parseSiteRules parses a rule file holding either a list of rules or a single rule. JSON files
+// parse as YAML.
+func parseSiteRules(data []byte, source string) ([]loadedRule, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+	}
+	if len(node.Content) == 0 {
+		return nil, nil // empty file
+	}
+
+	var rules []models.SiteRule
+	switch root := node.Content[0]; root.Kind {
+	case yaml.SequenceNode:
+		if err := root.Decode(&rules); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+		}
+	case yaml.MappingNode:
+		var rule models.SiteRule
+		if err := root.Decode(&rule); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+		}
+		rules = append(rules, rule)
+	default:
+		return nil, fmt.Errorf("%s holds neither a rule nor a list of rules", source)
+	}
+
+	loaded := make([]loadedRule, 0, len(rules))
+	for _, rule := range rules {
+		loaded = append(loaded, newLoadedRule(rule, source))
+	}
+	return loaded, nil
+}
+
+// newLoadedRule checks a rule's domains and selectors, naming unnamed rules after their first domain
+func newLoadedRule(rule models.SiteRule, source string) loadedRule {
+	if rule.Name == "" && len(rule.Domains) > 0 {
+		rule.Name = rule.Domains[0]
+	}
+
+	var problems []string
+	if len(rule.Domains) == 0 {
+		problems = append(problems, "no domains")
+	}
+	for _, domain := range rule.Domains {
+		if d := normalizeRuleDomain(domain); d == "" || strings.ContainsAny(d, "/: ") {
+			problems = append(problems, fmt.Sprintf("invalid domain %q", domain))
+		}
+	}
+	if len(rule.Content)+len(rule.Remove)+len(rule.Image)+len(rule.Date)+len(rule.Author) == 0 {
+		problems = append(problems, "no selectors")
+	}
+	for _, field := range siteRuleFields(rule) {
+		for _, selector := range field.selectors {
+			if _, err := CompileSelector(selector); err != nil {
+				problems = append(problems, fmt.Sprintf("invalid %s selector: %v", field.name, err))
+			}
+		}
+	}
+
+	return loadedRule{rule: rule, source: source, problems: problems}
+}
+
+// siteRuleField is one of a rule's selector lists
+type siteRuleField struct {
+	name      string
+	selectors []string
+}
+
+// siteRuleFields returns a rule's selector lists in a fixed order
+func siteRuleFields(rule models.SiteRule) []siteRuleField {
+	return []siteRuleField{
+		{"content", rule.Content},
+		{"remove", rule.Remove},
+		{"image", rule.Image},
+		{"date", rule.Date},
+		{"author", rule.Author},
+	}
+}
+
+// normalizeRuleDomain lower-cases a rule's domain and strips a leading "www."
+func normalizeRuleDomain(domain string) string {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	return strings.TrimPrefix(domain, "www.")
+}
+
+// logRuleProblems warns about the rules that will not be used
+func logRuleProblems(rules []loadedRule) {
+	for _, loaded := range rules {
+		if len(loaded.problems) > 0 {
+			log.Printf("Warning: ignoring site rule %q from %s: %s",
+				loaded.rule.Name, loaded.source, strings.Join(loaded.problems, "; "))
+		}
+	}
+}
+
+// removeElements drops the elements matched by a rule's remove selectors from the page
+func (ce *ContentExtractor) removeElements(doc *html.Node, selectors []string) {
+	for _, selector := range selectors {
+		for _, n := range ce.findAllBySelector(doc, selector) {
+			if n.Parent != nil {
+				n.Parent.RemoveChild(n)
+			}
+		}
+	}
+}
+
+// selectRuleValue returns the value of the first element matched by a rule's selectors: the
+// first of attrs it has set, otherwise its text
+func (ce *ContentExtractor) selectRuleValue(doc *html.Node, selectors []string, attrs ...string) string {
+	for _, selector := range selectors {
+		n := ce.findElementBySelector(doc, selector)
+		if n == nil {
+			continue
+		}
+		for _, attr := range attrs {
+			if value := strings.TrimSpace(getAttr(n, attr)); value != "" {
+				return value
+			}
+		}
+		if text := strings.Join(strings.Fields(ce.extractText(n)), " "); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// selectRuleImage returns the absolute URL of the image matched by a rule's selectors, which
+// may match an img, a meta tag or an element containing an img
+func (ce *ContentExtractor) selectRuleImage(doc *html.Node, selectors []string, pageURL string) string {
+	for _, selector := range selectors {
+		n := ce.findElementBySelector(doc, selector)
+		if n == nil {
+			continue
+		}
+
+		var imageURL string
+		switch n.Data {
+		case "img", "source":
+			imageURL = firstNonEmpty(getAttr(n, "src"), getAttr(n, "data-src"), firstSrcsetURL(getAttr(n, "srcset")))
+		case "meta":
+			imageURL = getAttr(n, "content")
+		case "link":
+			imageURL = getAttr(n, "href")
+		default:
+			imageURL = ce.findFirstImage(n)
+		}
+
+		if imageURL = strings.TrimSpace(imageURL); imageURL != "" {
+			return ce.resolveURL(imageURL, pageURL)
+		}
+	}
+	return ""
+}
+
+// firstSrcsetURL returns the first candidate URL of a srcset attribute
+func firstSrcsetURL(srcset string) string {
+	candidate, _, _ := strings.Cut(strings.TrimSpace(srcset), ",")
+	if fields := strings.Fields(candidate); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
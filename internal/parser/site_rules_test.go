@@ -0,0 +1,189 @@
+package parser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"strandnerd-crawler/internal/config"
+	"strandnerd-crawler/internal/models"
+)
+
+const testRuleArticlePage = `<!DOCTYPE html>
+<html>
+<head><meta name="parsely-author" content="Jane Doe"></head>
+<body>
+	<div class="story">
+		<div class="ad">Buy our stuff, buy our stuff, buy our stuff, buy our stuff, buy it now!</div>
+		<picture class="lead"><img srcset="/lead-800.jpg 800w, /lead-400.jpg 400w"></picture>
+		<span class="when">5 March 2024</span>
+		<p>The council approved the budget on Tuesday after a long debate, with the new spending
+		plan funding road repairs, two new libraries and an expanded bus network across the city.</p>
+	</div>
+</body>
+</html>`
+
+func TestSiteRulesPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	writeRuleFile(t, dir, "techcrunch.yaml", "name: TechCrunch redesign\ndomains: [techcrunch.com]\ncontent: [\".article-body\"]\n")
+	writeRuleFile(t, dir, "more.json", `[{"domains": ["www.example.com"], "content": ["#story"]}]`)
+	writeRuleFile(t, dir, "broken.yml", "domains: [broken.com]\ncontent: [\"div::before\"]\n")
+	writeRuleFile(t, dir, "notes.txt", "not a rule")
+
+	rules := NewSiteRules(dir)
+
+	tests := []struct {
+		url  string
+		want string // first content selector, "" for no rule
+	}{
+		{"https://techcrunch.com/2024/article", ".article-body"},
+		{"https://news.example.com/story", "#story"},
+		{"https://www.medium.com/@user/article", "article section"},
+		{"https://notmedium.com/article", ""},
+		{"https://broken.com/article", ""},
+	}
+	for _, test := range tests {
+		got := ""
+		if rule := rules.Lookup(test.url); rule != nil {
+			got = rule.Content[0]
+		}
+		if got != test.want {
+			t.Errorf("Lookup(%s): expected content selector %q, got %q", test.url, test.want, got)
+		}
+	}
+
+	rules.SetTenantRules([]models.SiteRule{{Domains: []string{"example.com"}, Content: []string{".tenant-body"}}})
+	if rule := rules.Lookup("https://example.com/story"); rule == nil || rule.Content[0] != ".tenant-body" || rule.Name != "example.com" {
+		t.Errorf("Expected the CMS rule to take precedence, got %+v", rule)
+	}
+
+	var brokenReported bool
+	for _, loaded := range rules.all() {
+		if strings.HasSuffix(loaded.source, "broken.yml") && len(loaded.problems) > 0 {
+			brokenReported = true
+		}
+	}
+	if !brokenReported {
+		t.Errorf("Expected the rule with an invalid selector to be kept with its problems")
+	}
+}
+
+func TestSiteRulesReloadOnChange(t *testing.T) {
+	dir := t.TempDir()
+	writeRuleFile(t, dir, "example.yaml", "domains: [example.com]\ncontent: [\".old\"]\n")
+
+	rules := NewSiteRules(dir)
+	if rule := rules.Lookup("https://example.com/a"); rule == nil || rule.Content[0] != ".old" {
+		t.Fatalf("Expected the initial rule, got %+v", rule)
+	}
+
+	writeRuleFile(t, dir, "example.yaml", "domains: [example.com]\ncontent: [\".new-body\"]\n")
+
+	// Within the reload interval the directory is not checked again
+	if rule := rules.Lookup("https://example.com/a"); rule == nil || rule.Content[0] != ".old" {
+		t.Fatalf("Expected the rules directory not to be checked yet, got %+v", rule)
+	}
+
+	rules.mutex.Lock()
+	rules.checkedAt = time.Time{}
+	rules.mutex.Unlock()
+
+	if rule := rules.Lookup("https://example.com/a"); rule == nil || rule.Content[0] != ".new-body" {
+		t.Errorf("Expected the edited rule after the reload interval, got %+v", rule)
+	}
+}
+
+func TestExtractContentWithSiteRule(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(testRuleArticlePage))
+	}))
+	defer server.Close()
+
+	ce := NewContentExtractor(&http.Client{Timeout: 5 * time.Second}, &config.Config{UserAgent: "test"})
+	ce.SiteRules().SetTenantRules([]models.SiteRule{{
+		Domains: []string{"127.0.0.1"},
+		Content: []string{".story"},
+		Remove:  []string{".ad"},
+		Image:   []string{".lead img"},
+		Date:    []string{".when"},
+		Author:  []string{"meta[name=parsely-author]"},
+	}})
+
+	extracted, err := ce.ExtractContentFromURL(context.Background(), server.URL+"/2024/03/budget")
+	if err != nil {
+		t.Fatalf("ExtractContentFromURL failed: %v", err)
+	}
+
+	if extracted.ImageURL != server.URL+"/lead-800.jpg" {
+		t.Errorf("Expected the rule's lead image, got %q", extracted.ImageURL)
+	}
+	if extracted.PublishedAt != "5 March 2024" {
+		t.Errorf("Expected the rule's date, got %q", extracted.PublishedAt)
+	}
+	if extracted.Author != "Jane Doe" {
+		t.Errorf("Expected the rule's author, got %q", extracted.Author)
+	}
+	if !strings.Contains(extracted.FullContent, "approved the budget") || strings.Contains(extracted.FullContent, "Buy our stuff") {
+		t.Errorf("Expected the article without the removed elements, got %q", extracted.FullContent)
+	}
+}
+
+func TestValidateSiteRules(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(testRuleArticlePage))
+	}))
+	defer server.Close()
+
+	ce := NewContentExtractor(&http.Client{Timeout: 5 * time.Second}, &config.Config{UserAgent: "test"})
+	ce.SiteRules().SetTenantRules([]models.SiteRule{
+		{Name: "current", Domains: []string{"current.test"}, Content: []string{".story"}, Date: []string{".when"}, TestURLs: []string{server.URL + "/a"}},
+		{Name: "stale", Domains: []string{"stale.test"}, Content: []string{".story", ".old-body"}, Author: []string{".byline"}, TestURLs: []string{server.URL + "/a", server.URL + "/gone"}},
+		{Name: "untested", Domains: []string{"untested.test"}, Content: []string{".body"}},
+		{Name: "recent", Domains: []string{"127.0.0.1"}, Content: []string{".story"}, Author: []string{".byline"}},
+	})
+
+	// Rules without test URLs are checked against recent posts on their domains
+	recentURLs := []string{"https://other.test/post", server.URL + "/a"}
+
+	reports := make(map[string]SiteRuleReport)
+	for _, report := range ce.ValidateSiteRules(context.Background(), recentURLs) {
+		if report.Source == siteRuleSourceCMS {
+			reports[report.Name] = report
+		}
+	}
+
+	if report := reports["current"]; !report.OK() || report.PagesChecked != 1 {
+		t.Errorf("Expected the current rule to pass on 1 page, got %+v", report)
+	}
+	if report := reports["untested"]; !report.OK() || report.PagesChecked != 0 {
+		t.Errorf("Expected the rule without test URLs or recent posts to pass the syntax check only, got %+v", report)
+	}
+	if report := reports["recent"]; report.PagesChecked != 1 || len(report.Problems) != 2 {
+		t.Errorf("Expected the rule to be checked against the recent post on its domain, got %+v", report)
+	}
+
+	stale := strings.Join(reports["stale"].Problems, "\n")
+	for _, want := range []string{"failed to fetch test page", "no author selector matches", `content selector ".old-body" matches none`, `author selector ".byline" matches none`} {
+		if !strings.Contains(stale, want) {
+			t.Errorf("Expected stale rule problem %q, got:\n%s", want, stale)
+		}
+	}
+}
+
+func writeRuleFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write rule file: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// maxRecentTestPages caps the recent posts a rule without test URLs is validated against
+const maxRecentTestPages = 3
+
+// SiteRuleReport is the outcome of validating one site rule
+type SiteRuleReport struct {
+	Name         string
+	Source       string // "built-in", "cms" or the rule file
+	PagesChecked int    // test pages fetched and checked
+	Problems     []string
+}
+
+// OK reports whether the rule passed validation
+func (r SiteRuleReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// ValidateSiteRules checks every loaded site rule, including rules overridden by another
+// source. A rule's selectors must compile, and on each of its test pages its content selectors
+// must find article text and its image, date and author selectors must find an element.
+// Selectors matching none of the test pages are reported as no longer matching. Rules without
+// test URLs are checked against the recent post URLs on their domains, and only for syntax when
+// there are none.
+func (ce *ContentExtractor) ValidateSiteRules(ctx context.Context, recentURLs []string) []SiteRuleReport {
+	var reports []SiteRuleReport
+	for _, loaded := range ce.siteRules.all() {
+		if ctx.Err() != nil {
+			break
+		}
+		reports = append(reports, ce.validateSiteRule(ctx, loaded, recentURLs))
+	}
+	return reports
+}
+
+// validateSiteRule checks one rule against its test pages
+func (ce *ContentExtractor) validateSiteRule(ctx context.Context, loaded loadedRule, recentURLs []string) SiteRuleReport {
+	rule := loaded.rule
+	report := SiteRuleReport{Name: rule.Name, Source: loaded.source, Problems: append([]string(nil), loaded.problems...)}
+	if len(report.Problems) > 0 {
+		return report // selectors that do not compile cannot be checked against pages
+	}
+
+	testURLs := rule.TestURLs
+	if len(testURLs) == 0 {
+		testURLs = ruleDomainURLs(rule.Domains, recentURLs, maxRecentTestPages)
+	}
+
+	matched := make(map[string]bool) // field and selector -> matched on any test page
+	for _, testURL := range testURLs {
+		doc, err := ce.fetchPage(ctx, testURL)
+		if err != nil {
+			report.Problems = append(report.Problems, fmt.Sprintf("failed to fetch test page %s: %v", testURL, err))
+			continue
+		}
+		report.PagesChecked++
+
+		// Remove selectors are optional per page, clutter such as ads is not on every article
+		for _, selector := range rule.Remove {
+			if ce.findElementBySelector(doc, selector) != nil {
+				matched["remove "+selector] = true
+			}
+		}
+		ce.removeElements(doc, rule.Remove)
+
+		for _, field := range siteRuleFields(rule) {
+			if field.name == "remove" || len(field.selectors) == 0 {
+				continue
+			}
+			found := false
+			for _, selector := range field.selectors {
+				if ce.findElementBySelector(doc, selector) == nil {
+					continue
+				}
+				matched[field.name+" "+selector] = true
+				if field.name == "content" && !ce.isGoodContent(ce.extractHTMLFromSelector(doc, selector)) {
+					continue
+				}
+				found = true
+			}
+			if !found {
+				report.Problems = append(report.Problems, fmt.Sprintf("no %s selector matches %s", field.name, testURL))
+			}
+		}
+	}
+
+	if report.PagesChecked == 0 {
+		return report
+	}
+	for _, field := range siteRuleFields(rule) {
+		for _, selector := range field.selectors {
+			if !matched[field.name+" "+selector] {
+				report.Problems = append(report.Problems, fmt.Sprintf("%s selector %q matches none of the test pages", field.name, selector))
+			}
+		}
+	}
+	return report
+}
+
+// ruleDomainURLs returns up to limit of the URLs whose host is one of the domains or a subdomain
+func ruleDomainURLs(domains, urls []string, limit int) []string {
+	var matches []string
+	for _, rawURL := range urls {
+		parsedURL, err := url.Parse(rawURL)
+		if err != nil {
+			continue
+		}
+		hostname := strings.TrimSuffix(strings.ToLower(parsedURL.Hostname()), ".")
+		for _, domain := range domains {
+			domain = normalizeRuleDomain(domain)
+			if hostname == domain || strings.HasSuffix(hostname, "."+domain) {
+				matches = append(matches, rawURL)
+				break
+			}
+		}
+		if len(matches) == limit {
+			break
+		}
+	}
+	return matches
+}
@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"strandnerd-crawler/internal/models"
+)
+
+const (
+	// maxSitemapEntries bounds how many of a sitemap's most recent URLs become feed items, so a
+	// site's full archive is not posted on the first crawl
+	maxSitemapEntries = 100
+	// maxChildSitemaps bounds how many of a sitemap index's most recent sitemaps are fetched
+	maxChildSitemaps = 3
+	// maxSitemapSize bounds a decompressed sitemap, the protocol allows 50MB
+	maxSitemapSize = 50 << 20
+)
+
+const sitemapAcceptHeader = "application/xml, text/xml;q=0.9, */*;q=0.1"
+
+// ParseSitemap fetches a sitemap.xml, Google News sitemap or sitemap index and turns its most
+// recent URLs into feed items. News sitemaps provide titles, dates and keywords; entries of plain
+// sitemaps get a placeholder title from their URL until the article page is extracted.
+func (p *RSSParser) ParseSitemap(ctx context.Context, sitemapURL string) (*models.RSSFeed, error) {
+	urls, err := p.fetchSitemap(ctx, sitemapURL, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest first, undated entries keep their document order after the dated ones
+	dates := make([]time.Time, len(urls))
+	for i, entry := range urls {
+		dates[i], _ = ParseDate(sitemapEntryDate(entry))
+	}
+	sort.Stable(newestFirst[models.SitemapURL]{urls, dates})
+	if len(urls) > maxSitemapEntries {
+		urls = urls[:maxSitemapEntries]
+	}
+
+	feed := &models.RSSFeed{Title: sitemapURL, Link: sitemapURL}
+	seen := make(map[string]bool)
+	for _, entry := range urls {
+		link := strings.TrimSpace(entry.Loc)
+		if link == "" || seen[link] {
+			continue
+		}
+		seen[link] = true
+		feed.Items = append(feed.Items, sitemapItem(entry, link))
+	}
+
+	return feed, nil
+}
+
+// fetchSitemap fetches a sitemap's URL entries, following a sitemap index one level deep
+func (p *RSSParser) fetchSitemap(ctx context.Context, sitemapURL string, followIndex bool) ([]models.SitemapURL, error) {
+	body, _, _, err := p.fetchDocument(ctx, sitemapURL, sitemapAcceptHeader, maxSitemapSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if body, err = gunzipSitemap(body); err != nil {
+		return nil, fmt.Errorf("failed to decompress sitemap %s: %w", sitemapURL, err)
+	}
+	body = sanitizeXML(decodeCharset("", body))
+
+	switch root := sniffXMLRoot(body); root {
+	case "urlset":
+		urlSet, err := decodeXML[models.SitemapURLSet](body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse sitemap %s: %w", sitemapURL, err)
+		}
+		return urlSet.URLs, nil
+
+	case "sitemapindex":
+		if !followIndex {
+			return nil, fmt.Errorf("sitemap index %s is nested in another sitemap index", sitemapURL)
+		}
+		index, err := decodeXML[models.SitemapIndex](body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse sitemap index %s: %w", sitemapURL, err)
+		}
+		return p.fetchChildSitemaps(ctx, index.Sitemaps)
+
+	default:
+		return nil, fmt.Errorf("%s is not a sitemap (root element %q)", sitemapURL, root)
+	}
+}
+
+// fetchChildSitemaps fetches the most recently modified sitemaps of a sitemap index
+func (p *RSSParser) fetchChildSitemaps(ctx context.Context, sitemaps []models.SitemapRef) ([]models.SitemapURL, error) {
+	dates := make([]time.Time, len(sitemaps))
+	for i, sitemap := range sitemaps {
+		dates[i], _ = ParseDate(sitemap.LastMod)
+	}
+	sort.Stable(newestFirst[models.SitemapRef]{sitemaps, dates})
+	if len(sitemaps) > maxChildSitemaps {
+		sitemaps = sitemaps[:maxChildSitemaps]
+	}
+
+	var urls []models.SitemapURL
+	var lastErr error
+	for _, sitemap := range sitemaps {
+		childURLs, err := p.fetchSitemap(ctx, strings.TrimSpace(sitemap.Loc), false)
+		if err != nil {
+			log.Printf("Warning: skipping sitemap %s: %v", sitemap.Loc, err)
+			lastErr = err
+			continue
+		}
+		urls = append(urls, childURLs...)
+	}
+
+	if len(urls) == 0 && lastErr != nil {
+		return nil, fmt.Errorf("failed to fetch any sitemap of the index: %w", lastErr)
+	}
+	return urls, nil
+}
+
+// gunzipSitemap decompresses gzipped sitemaps (sitemap.xml.gz), leaving other bodies unchanged
+func gunzipSitemap(body []byte) ([]byte, error) {
+	if !bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		return body, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(io.LimitReader(reader, maxSitemapSize))
+}
+
+// sitemapEntryDate returns the publication date of a news sitemap entry, or its last modification
+func sitemapEntryDate(entry models.SitemapURL) string {
+	if entry.News != nil && strings.TrimSpace(entry.News.PublicationDate) != "" {
+		return entry.News.PublicationDate
+	}
+	return entry.LastMod
+}
+
+// sitemapItem converts a sitemap entry into a feed item
+func sitemapItem(entry models.SitemapURL, link string) models.RSSItem {
+	item := models.RSSItem{Link: link, GUID: link}
+
+	// A plain sitemap's lastmod changes with every edit and is no publish date, the article
+	// page's own date is used for those instead
+	if entry.News != nil {
+		item.Title = strings.TrimSpace(entry.News.Title)
+		item.PubDate = strings.TrimSpace(entry.News.PublicationDate)
+		item.Categories = strings.Split(entry.News.Keywords, ",")
+	}
+	if item.Title == "" {
+		item.Title = titleFromURL(link)
+	}
+
+	for _, image := range entry.Images {
+		item.Media = append(item.Media, models.MediaContent{URL: strings.TrimSpace(image.Loc), Medium: "image"})
+	}
+
+	return item
+}
+
+// newestFirst sorts sitemap entries by their parsed dates, newest first, with undated entries
+// (zero dates) after the dated ones
+type newestFirst[T any] struct {
+	entries []T
+	dates   []time.Time
+}
+
+func (s newestFirst[T]) Len() int { return len(s.entries) }
+
+func (s newestFirst[T]) Less(i, j int) bool { return s.dates[i].After(s.dates[j]) }
+
+func (s newestFirst[T]) Swap(i, j int) {
+	s.entries[i], s.entries[j] = s.entries[j], s.entries[i]
+	s.dates[i], s.dates[j] = s.dates[j], s.dates[i]
+}
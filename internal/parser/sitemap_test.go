@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"strandnerd-crawler/internal/config"
+)
+
+const testNewsSitemap = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
+	xmlns:news="http://www.google.com/schemas/sitemap-news/0.9"
+	xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+	<url>
+		<loc>https://example.com/older-story</loc>
+		<news:news>
+			<news:publication><news:name>Example</news:name><news:language>en</news:language></news:publication>
+			<news:publication_date>2024-03-04T08:00:00+00:00</news:publication_date>
+			<news:title>Older story</news:title>
+		</news:news>
+	</url>
+	<url>
+		<loc>https://example.com/newer-story</loc>
+		<news:news>
+			<news:publication_date>2024-03-05T08:00:00+00:00</news:publication_date>
+			<news:title>Newer story</news:title>
+			<news:keywords>Politics, Elections</news:keywords>
+		</news:news>
+		<image:image><image:loc>https://example.com/newer.jpg</image:loc></image:image>
+	</url>
+</urlset>`
+
+const testPlainSitemap = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>https://example.com/archive/budget-vote-delayed</loc><lastmod>2024-03-01</lastmod></url>
+</urlset>`
+
+func TestParseSitemap(t *testing.T) {
+	var gzipped bytes.Buffer
+	writer := gzip.NewWriter(&gzipped)
+	writer.Write([]byte(testPlainSitemap))
+	writer.Close()
+
+	var serverURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap_index.xml":
+			w.Write([]byte(strings.NewReplacer("{base}", serverURL).Replace(`<?xml version="1.0"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>{base}/archive.xml.gz</loc><lastmod>2023-01-01</lastmod></sitemap>
+	<sitemap><loc>{base}/news.xml</loc><lastmod>2024-03-05</lastmod></sitemap>
+</sitemapindex>`)))
+		case "/news.xml":
+			w.Write([]byte(testNewsSitemap))
+		case "/archive.xml.gz":
+			w.Write(gzipped.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	serverURL = server.URL
+
+	p := NewRSSParser(&http.Client{Timeout: 5 * time.Second}, &config.Config{UserAgent: "test"})
+
+	feed, err := p.ParseSitemap(context.Background(), server.URL+"/sitemap_index.xml")
+	if err != nil {
+		t.Fatalf("ParseSitemap failed: %v", err)
+	}
+	if len(feed.Items) != 3 {
+		t.Fatalf("Expected 3 items, got %d: %+v", len(feed.Items), feed.Items)
+	}
+
+	newest := feed.Items[0]
+	if newest.Title != "Newer story" || newest.PubDate != "2024-03-05T08:00:00+00:00" {
+		t.Errorf("Expected the newest news entry first, got %+v", newest)
+	}
+	if len(newest.Categories) != 2 || len(newest.Media) != 1 || newest.Media[0].URL != "https://example.com/newer.jpg" {
+		t.Errorf("Expected keywords and image of the news entry, got %+v", newest)
+	}
+
+	archived := feed.Items[2]
+	if archived.Title != "Budget vote delayed" || archived.PubDate != "" {
+		t.Errorf("Expected a placeholder title and no publish date for the plain sitemap entry, got %+v", archived)
+	}
+	if !isPlaceholderTitle(archived.Title, archived.Link) {
+		t.Error("Title derived from the URL should be a placeholder")
+	}
+}
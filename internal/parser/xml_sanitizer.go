@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"log"
+	"strconv"
+	"unicode/utf8"
+)
+
+// xmlEntities are the entities predefined by XML itself
+var xmlEntities = map[string]bool{"amp": true, "lt": true, "gt": true, "quot": true, "apos": true}
+
+// sanitizeXML fixes the breakage most often seen in real-world feeds before they reach the
+// XML decoder. The body must already be UTF-8.
+//   - whitespace before the XML declaration is removed
+//   - characters that are not allowed in XML 1.0, such as stray control characters, are dropped
+//   - HTML entities like &nbsp; are replaced by numeric character references
+//   - bare ampersands that do not start a valid reference are escaped
+//
+// CDATA sections and comments are copied unchanged apart from the invalid characters.
+func sanitizeXML(body []byte) []byte {
+	body = bytes.TrimLeft(body, " \t\r\n")
+
+	var buf bytes.Buffer
+	buf.Grow(len(body))
+
+	for len(body) > 0 {
+		switch {
+		case bytes.HasPrefix(body, []byte("<![CDATA[")):
+			body = copyUntil(&buf, body, "]]>")
+			continue
+		case bytes.HasPrefix(body, []byte("<!--")):
+			body = copyUntil(&buf, body, "-->")
+			continue
+		case body[0] == '&':
+			replacement, size := sanitizeReference(body)
+			buf.WriteString(replacement)
+			body = body[size:]
+			continue
+		}
+
+		r, size := utf8.DecodeRune(body)
+		if isXMLChar(r) {
+			buf.Write(body[:size])
+		}
+		body = body[size:]
+	}
+
+	return buf.Bytes()
+}
+
+// copyUntil copies body up to and including the terminator, dropping invalid characters,
+// and returns the remainder. An unterminated section is copied to the end.
+func copyUntil(buf *bytes.Buffer, body []byte, terminator string) []byte {
+	end := bytes.Index(body, []byte(terminator))
+	if end == -1 {
+		end = len(body)
+	} else {
+		end += len(terminator)
+	}
+
+	for section := body[:end]; len(section) > 0; {
+		r, size := utf8.DecodeRune(section)
+		if isXMLChar(r) {
+			buf.Write(section[:size])
+		}
+		section = section[size:]
+	}
+
+	return body[end:]
+}
+
+// sanitizeReference returns the replacement for the reference starting at the '&' at the
+// beginning of body and how many bytes of body it replaces
+func sanitizeReference(body []byte) (string, int) {
+	end := bytes.IndexByte(body, ';')
+	if end == -1 || end > 32 {
+		return "&amp;", 1
+	}
+	name := string(body[1:end])
+
+	if len(name) > 1 && name[0] == '#' {
+		if isValidCharRef(name[1:]) {
+			return string(body[:end+1]), end + 1
+		}
+		return "&amp;", 1
+	}
+
+	if xmlEntities[name] {
+		return string(body[:end+1]), end + 1
+	}
+
+	if value, ok := xml.HTMLEntity[name]; ok {
+		var ref bytes.Buffer
+		for _, r := range value {
+			ref.WriteString("&#" + strconv.Itoa(int(r)) + ";")
+		}
+		return ref.String(), end + 1
+	}
+
+	return "&amp;", 1
+}
+
+// isValidCharRef reports whether a numeric character reference (without "&#" and ";") is well-formed
+func isValidCharRef(ref string) bool {
+	base := 10
+	if ref != "" && (ref[0] == 'x' || ref[0] == 'X') {
+		base, ref = 16, ref[1:]
+	}
+	n, err := strconv.ParseUint(ref, base, 32)
+	return err == nil && isXMLChar(rune(n))
+}
+
+// isXMLChar reports whether r is allowed in an XML 1.0 document
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		(r >= 0x20 && r <= 0xD7FF) ||
+		(r >= 0xE000 && r <= 0xFFFD) ||
+		(r >= 0x10000 && r <= 0x10FFFF)
+}
+
+// feedAutoClose are the HTML void elements closed automatically by lenient decoders. <link> is
+// left out since RSS uses it with content.
+var feedAutoClose = func() []string {
+	var names []string
+	for _, name := range xml.HTMLAutoClose {
+		if name != "link" {
+			names = append(names, name)
+		}
+	}
+	return names
+}()
+
+// newXMLDecoder creates a decoder for a UTF-8 body. Lenient decoders accept unclosed HTML tags,
+// undeclared entities and unquoted attributes.
+func newXMLDecoder(body []byte, lenient bool) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	// The body has already been converted to UTF-8, whatever the prolog says
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	if lenient {
+		decoder.Strict = false
+		decoder.AutoClose = feedAutoClose
+		decoder.Entity = xml.HTMLEntity
+	}
+	return decoder
+}
+
+// decodeXML decodes a feed document, retrying with a lenient decoder when it is not well-formed
+func decodeXML[T any](body []byte) (*T, error) {
+	var strict T
+	err := newXMLDecoder(body, false).Decode(&strict)
+	if err == nil {
+		return &strict, nil
+	}
+
+	var lenient T
+	if lenientErr := newXMLDecoder(body, true).Decode(&lenient); lenientErr != nil {
+		return nil, err
+	}
+
+	log.Printf("Warning: feed is not well-formed XML, parsed leniently: %v", err)
+	return &lenient, nil
+}
@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Fixtures in testdata/feeds reproduce broken feeds seen in production
+func TestParseBrokenFeedFixtures(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		contentType string
+		title       string
+		description string
+		link        string
+	}{
+		{"latin1_prolog.xml", "text/xml", "Große Änderungen für Bürger", "Café à la carte", ""},
+		{"windows1252_header.xml", "application/rss+xml; charset=windows-1252", "“Quoted” – costs 5€", "It’s … fine", ""},
+		{"mixed_utf8_windows1252.xml", "application/rss+xml; charset=utf-8", "Crème brûlée “recipe”", "", ""},
+		{"utf16_bom.xml", "text/xml", "Ünïcödé title", "", ""},
+		{"stray_ampersands.xml", "text/xml", "Salt & pepper & more © — & done", "Fish &amp; chips & <b>peas</b>", "https://example.com/search?q=salt&page=2"},
+		{"html_entities.xml", "text/xml", "Café culture — …", "", ""},
+		{"control_characters.xml", "text/xml", "Broken vertical tab", "", ""},
+		{"unclosed_html.xml", "text/xml", "Line breaks", "First line\nSecond line\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", "feeds", tt.fixture))
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+
+			feed, err := parseFeedBody(tt.contentType, body)
+			if err != nil {
+				t.Fatalf("Failed to parse feed: %v", err)
+			}
+			if len(feed.Items) != 1 {
+				t.Fatalf("Expected 1 item, got %d", len(feed.Items))
+			}
+
+			item := feed.Items[0]
+			if item.Title != tt.title {
+				t.Errorf("Expected title %q, got %q", tt.title, item.Title)
+			}
+			if tt.description != "" && item.Description != tt.description {
+				t.Errorf("Expected description %q, got %q", tt.description, item.Description)
+			}
+			if tt.link != "" && item.Link != tt.link {
+				t.Errorf("Expected link %q, got %q", tt.link, item.Link)
+			}
+		})
+	}
+}
+
+func TestDetectCharset(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		expected    string
+	}{
+		{"text/xml; charset=ISO-8859-1", `<?xml version="1.0" encoding="UTF-8"?>`, "windows-1252"},
+		{"text/xml", `<?xml version="1.0" encoding='iso-8859-15'?>`, "iso-8859-15"},
+		{"text/xml; charset=utf-8", "\xff\xfe<", "utf-16le"},
+		{"", `<rss version="2.0">`, "utf-8"},
+		{"text/xml", `<?xml version="1.0" encoding="KOI8-R"?>`, "unknown"},
+	}
+
+	for _, tt := range tests {
+		if got := detectCharset(tt.contentType, []byte(tt.body)); got != tt.expected {
+			t.Errorf("detectCharset(%q, %q) = %q, expected %q", tt.contentType, tt.body, got, tt.expected)
+		}
+	}
+}
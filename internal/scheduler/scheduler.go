@@ -0,0 +1,305 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"strandnerd-crawler/internal/models"
+)
+
+const (
+	// jitterFraction spreads each feed's next run by up to ±10% of its interval
+	jitterFraction = 0.1
+	// refreshInterval is how often the feed lists are re-read from the crawlers' caches
+	refreshInterval = time.Minute
+)
+
+// FeedCrawler is the part of crawler.Service the scheduler needs
+type FeedCrawler interface {
+	Feeds(ctx context.Context) ([]models.InspirationFeed, error)
+	CrawlScheduledFeed(ctx context.Context, feed *models.InspirationFeed) *models.CrawlResult
+}
+
+// Tenant is a crawler to schedule together with its minimum crawl interval
+type Tenant struct {
+	ID          string
+	Crawler     FeedCrawler
+	MinInterval time.Duration
+}
+
+// Scheduler keeps every feed of every tenant in a priority queue ordered by next run time
+// and sleeps until the earliest one is due
+type Scheduler struct {
+	tenants  map[string]Tenant
+	feedID   string
+	queue    feedQueue
+	entries  map[string]*scheduledFeed
+	done     chan crawlDone
+	running  int
+	now      func() time.Time
+	random   func() float64
+	OnResult func(tenantID string, result *models.CrawlResult)
+}
+
+// scheduledFeed is a single feed's entry in the queue
+type scheduledFeed struct {
+	key        string
+	tenantID   string
+	feed       models.InspirationFeed
+	nextRun    time.Time
+	lastRun    time.Time
+	retryAfter time.Time // earliest next run requested by the last crawl of a failing feed
+	running    bool
+	removed    bool
+	index      int // position in the heap, -1 while running
+}
+
+// crawlDone reports a finished crawl back to the Run loop, which owns all scheduledFeed fields
+type crawlDone struct {
+	entry      *scheduledFeed
+	feedURL    string    // URL that was crawled
+	retryAfter time.Time // earliest next run requested by the crawl
+}
+
+// New creates a scheduler for the given tenants. A non-empty feedID restricts scheduling to that feed.
+func New(tenants []Tenant, feedID string) *Scheduler {
+	s := &Scheduler{
+		tenants: make(map[string]Tenant),
+		feedID:  feedID,
+		entries: make(map[string]*scheduledFeed),
+		done:    make(chan crawlDone),
+		now:     time.Now,
+		random:  rand.Float64,
+	}
+	for _, tenant := range tenants {
+		s.tenants[tenant.ID] = tenant
+	}
+	return s
+}
+
+// Run schedules feeds until ctx is cancelled, then waits for in-flight crawls to finish.
+// Crawls run with workCtx, which the caller cancels when the drain deadline expires.
+func (s *Scheduler) Run(ctx, workCtx context.Context) {
+	log.Printf("Starting feed scheduler for %d tenant(s)", len(s.tenants))
+
+	s.refresh(ctx)
+
+	refreshTicker := time.NewTicker(refreshInterval)
+	defer refreshTicker.Stop()
+
+	for ctx.Err() == nil {
+		timer := time.NewTimer(s.untilNextRun())
+
+		select {
+		case <-timer.C:
+			s.dispatchDue(workCtx)
+		case done := <-s.done:
+			s.reschedule(done)
+		case <-refreshTicker.C:
+			s.refresh(ctx)
+		case <-ctx.Done():
+		}
+
+		timer.Stop()
+	}
+
+	if s.running > 0 {
+		log.Printf("Scheduler stopped, waiting for %d in-flight crawl(s)", s.running)
+	}
+	for s.running > 0 {
+		<-s.done
+		s.running--
+	}
+	log.Printf("Scheduler drained")
+}
+
+// untilNextRun returns how long to sleep until the earliest feed is due
+func (s *Scheduler) untilNextRun() time.Duration {
+	if s.queue.Len() == 0 {
+		return refreshInterval
+	}
+	return max(s.queue[0].nextRun.Sub(s.now()), 0)
+}
+
+// dispatchDue starts a crawl for every feed whose next run time has passed
+func (s *Scheduler) dispatchDue(ctx context.Context) {
+	now := s.now()
+	for s.queue.Len() > 0 && !s.queue[0].nextRun.After(now) {
+		entry := heap.Pop(&s.queue).(*scheduledFeed)
+		entry.running = true
+		s.running++
+
+		tenant := s.tenants[entry.tenantID]
+		feed := entry.feed
+
+		go func() {
+			result := tenant.Crawler.CrawlScheduledFeed(ctx, &feed)
+			if s.OnResult != nil {
+				s.OnResult(tenant.ID, result)
+			}
+			s.done <- crawlDone{entry: entry, feedURL: feed.URL, retryAfter: result.RetryAfter}
+		}()
+	}
+}
+
+// reschedule puts a feed back into the queue after its crawl finished.
+// A failing feed waits for its backoff when that is later than the regular interval, unless its
+// URL changed while the crawl was running.
+func (s *Scheduler) reschedule(done crawlDone) {
+	entry := done.entry
+	if done.feedURL == entry.feed.URL {
+		entry.retryAfter = done.retryAfter
+	}
+	entry.running = false
+	entry.lastRun = s.now()
+	s.running--
+
+	if entry.removed {
+		return
+	}
+
+	entry.nextRun = s.jittered(entry.lastRun, s.interval(entry))
+	if entry.retryAfter.After(entry.nextRun) {
+		entry.nextRun = entry.retryAfter
+	}
+	heap.Push(&s.queue, entry)
+}
+
+// refresh syncs the queue with the tenants' current feed lists
+func (s *Scheduler) refresh(ctx context.Context) {
+	seen := make(map[string]bool)
+
+	for _, tenant := range s.tenants {
+		feeds, err := tenant.Crawler.Feeds(ctx)
+		if err != nil {
+			log.Printf("Scheduler: failed to get feeds for tenant %s: %v", tenant.ID, err)
+			// Keep the tenant's existing entries until the CMS is reachable again
+			for key, entry := range s.entries {
+				if entry.tenantID == tenant.ID {
+					seen[key] = true
+				}
+			}
+			continue
+		}
+
+		for _, feed := range feeds {
+			if !feed.IsActive || (s.feedID != "" && feed.ID != s.feedID) {
+				continue
+			}
+
+			key := tenant.ID + "/" + feed.ID
+			seen[key] = true
+
+			if entry, ok := s.entries[key]; ok {
+				s.update(entry, feed)
+				continue
+			}
+
+			entry := &scheduledFeed{
+				key:      key,
+				tenantID: tenant.ID,
+				feed:     feed,
+			}
+			entry.nextRun = s.initialRun(entry)
+			s.entries[key] = entry
+			heap.Push(&s.queue, entry)
+		}
+	}
+
+	// Drop feeds that were deleted or deactivated in the CMS
+	for key, entry := range s.entries {
+		if seen[key] {
+			continue
+		}
+		delete(s.entries, key)
+		entry.removed = true
+		if !entry.running {
+			heap.Remove(&s.queue, entry.index)
+		}
+	}
+}
+
+// update applies changed feed settings to an existing entry.
+// A changed URL drops any failure backoff and crawls the feed again soon.
+func (s *Scheduler) update(entry *scheduledFeed, feed models.InspirationFeed) {
+	intervalChanged := feed.CrawlIntervalMinutes != entry.feed.CrawlIntervalMinutes
+	urlChanged := feed.URL != entry.feed.URL
+	entry.feed = feed
+
+	if urlChanged {
+		entry.retryAfter = time.Time{}
+	}
+
+	if (!intervalChanged && !urlChanged) || entry.running {
+		return
+	}
+
+	if entry.lastRun.IsZero() || urlChanged {
+		entry.nextRun = s.initialRun(entry)
+	} else {
+		entry.nextRun = s.jittered(entry.lastRun, s.interval(entry))
+	}
+	heap.Fix(&s.queue, entry.index)
+}
+
+// initialRun computes the first run time from the CMS's last crawl timestamp.
+// Overdue feeds are spread over the jitter window instead of all firing at once.
+func (s *Scheduler) initialRun(entry *scheduledFeed) time.Time {
+	now := s.now()
+	interval := s.interval(entry)
+
+	if entry.feed.LastCrawledAt != nil {
+		if lastCrawled, err := time.Parse(time.RFC3339, *entry.feed.LastCrawledAt); err == nil {
+			if next := s.jittered(lastCrawled, interval); next.After(now) {
+				return next
+			}
+		}
+	}
+
+	window := time.Duration(float64(interval) * jitterFraction)
+	return now.Add(time.Duration(s.random() * float64(window)))
+}
+
+// interval returns the feed's crawl interval, never shorter than the tenant's minimum
+func (s *Scheduler) interval(entry *scheduledFeed) time.Duration {
+	interval := time.Duration(entry.feed.CrawlIntervalMinutes) * time.Minute
+	return max(interval, s.tenants[entry.tenantID].MinInterval, time.Minute)
+}
+
+// jittered returns from+interval shifted by up to ±jitterFraction of the interval
+func (s *Scheduler) jittered(from time.Time, interval time.Duration) time.Time {
+	jitter := (s.random()*2 - 1) * jitterFraction * float64(interval)
+	return from.Add(interval + time.Duration(jitter))
+}
+
+// feedQueue is a min-heap of scheduled feeds ordered by next run time
+type feedQueue []*scheduledFeed
+
+func (q feedQueue) Len() int { return len(q) }
+
+func (q feedQueue) Less(i, j int) bool { return q[i].nextRun.Before(q[j].nextRun) }
+
+func (q feedQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *feedQueue) Push(x any) {
+	entry := x.(*scheduledFeed)
+	entry.index = len(*q)
+	*q = append(*q, entry)
+}
+
+func (q *feedQueue) Pop() any {
+	old := *q
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	entry.index = -1
+	*q = old[:n-1]
+	return entry
+}
@@ -0,0 +1,115 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"strandnerd-crawler/internal/models"
+)
+
+type fakeCrawler struct {
+	feeds []models.InspirationFeed
+}
+
+func (f *fakeCrawler) Feeds(ctx context.Context) ([]models.InspirationFeed, error) {
+	return f.feeds, nil
+}
+
+func (f *fakeCrawler) CrawlScheduledFeed(ctx context.Context, feed *models.InspirationFeed) *models.CrawlResult {
+	return &models.CrawlResult{FeedID: feed.ID, Success: true}
+}
+
+func TestSchedulerOrdersFeedsByNextRun(t *testing.T) {
+	now := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	recent := now.Add(-10 * time.Minute).Format(time.RFC3339)
+
+	crawler := &fakeCrawler{feeds: []models.InspirationFeed{
+		{ID: "recent", IsActive: true, CrawlIntervalMinutes: 30, LastCrawledAt: &recent},
+		{ID: "never", IsActive: true, CrawlIntervalMinutes: 60},
+		{ID: "inactive", IsActive: false, CrawlIntervalMinutes: 60},
+	}}
+
+	s := New([]Tenant{{ID: "t1", Crawler: crawler, MinInterval: 5 * time.Minute}}, "")
+	s.now = func() time.Time { return now }
+	s.random = func() float64 { return 0.5 } // No jitter in either direction
+
+	s.refresh(context.Background())
+
+	if s.queue.Len() != 2 {
+		t.Fatalf("Expected 2 active feeds in queue, got %d", s.queue.Len())
+	}
+
+	// Overdue feed is spread over the first 10% of its interval
+	if first := s.queue[0]; first.feed.ID != "never" || !first.nextRun.Equal(now.Add(3*time.Minute)) {
+		t.Errorf("Expected 'never' first at +3m, got %s at %v", first.feed.ID, first.nextRun.Sub(now))
+	}
+
+	entry := s.entries["t1/recent"]
+	if !entry.nextRun.Equal(now.Add(20 * time.Minute)) {
+		t.Errorf("Expected 'recent' to run 30m after its last crawl, got +%v", entry.nextRun.Sub(now))
+	}
+
+	// Removing a feed in the CMS drops it from the queue
+	crawler.feeds = crawler.feeds[1:]
+	s.refresh(context.Background())
+	if _, ok := s.entries["t1/recent"]; ok || s.queue.Len() != 1 {
+		t.Errorf("Expected removed feed to leave the queue, queue has %d entries", s.queue.Len())
+	}
+}
+
+// blockingCrawler fails every crawl with a backoff once release is closed
+type blockingCrawler struct {
+	fakeCrawler
+	release    chan struct{}
+	retryAfter time.Time
+}
+
+func (b *blockingCrawler) CrawlScheduledFeed(ctx context.Context, feed *models.InspirationFeed) *models.CrawlResult {
+	<-b.release
+	return &models.CrawlResult{FeedID: feed.ID, RetryAfter: b.retryAfter}
+}
+
+func TestSchedulerURLChangeDuringCrawlDropsBackoff(t *testing.T) {
+	now := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	crawler := &blockingCrawler{
+		fakeCrawler: fakeCrawler{feeds: []models.InspirationFeed{{ID: "f1", URL: "https://old.example.com/rss", IsActive: true, CrawlIntervalMinutes: 30}}},
+		release:     make(chan struct{}),
+		retryAfter:  now.Add(6 * time.Hour),
+	}
+
+	s := New([]Tenant{{ID: "t1", Crawler: crawler}}, "")
+	clock := now
+	s.now = func() time.Time { return clock }
+	s.random = func() float64 { return 0.5 }
+
+	s.refresh(context.Background())
+	clock = now.Add(time.Hour)
+	s.dispatchDue(context.Background())
+
+	// The feed's URL is corrected in the CMS while the failing crawl of the old URL is running
+	crawler.feeds = []models.InspirationFeed{{ID: "f1", URL: "https://new.example.com/rss", IsActive: true, CrawlIntervalMinutes: 30}}
+	s.refresh(context.Background())
+	close(crawler.release)
+	s.reschedule(<-s.done)
+
+	entry := s.entries["t1/f1"]
+	if !entry.retryAfter.IsZero() || !entry.nextRun.Equal(now.Add(90*time.Minute)) {
+		t.Errorf("Expected the old URL's backoff to be dropped, got retry after %v and next run +%v",
+			entry.retryAfter, entry.nextRun.Sub(now))
+	}
+}
+
+func TestSchedulerIntervalRespectsTenantMinimum(t *testing.T) {
+	s := New([]Tenant{{ID: "t1", Crawler: &fakeCrawler{}, MinInterval: 15 * time.Minute}}, "")
+
+	entry := &scheduledFeed{tenantID: "t1", feed: models.InspirationFeed{CrawlIntervalMinutes: 5}}
+	if got := s.interval(entry); got != 15*time.Minute {
+		t.Errorf("Expected tenant minimum of 15m, got %v", got)
+	}
+
+	entry.feed.CrawlIntervalMinutes = 60
+	if got := s.interval(entry); got != 60*time.Minute {
+		t.Errorf("Expected feed interval of 60m, got %v", got)
+	}
+}
@@ -0,0 +1,354 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// seenRetention is how long a seen GUID/URL is remembered after it was last observed in a feed
+const seenRetention = 180 * 24 * time.Hour
+
+// Store persists per-feed crawl state so duplicate detection and conditional
+// requests keep working across restarts and CMS outages
+type Store interface {
+	// HasSeen reports whether a GUID or URL was already processed for the feed
+	HasSeen(feedID, key string) bool
+	// MarkSeen records GUIDs or URLs as processed for the feed
+	MarkSeen(feedID string, keys ...string) error
+	// GetValidators returns the HTTP cache validators stored for the feed URL
+	GetValidators(feedID, feedURL string) (Validators, bool)
+	// SetValidators stores the HTTP cache validators for the feed URL
+	SetValidators(feedID, feedURL string, v Validators) error
+	// GetDiscoveredFeed returns the feed URL discovered on the web page configured as the feed's URL
+	GetDiscoveredFeed(feedID, pageURL string) (string, bool)
+	// SetDiscoveredFeed stores the feed URL discovered on the web page, an empty URL removes it
+	SetDiscoveredFeed(feedID, pageURL, discoveredURL string) error
+	// RecordCrawl stores the outcome of the latest crawl of the feed
+	RecordCrawl(feedID string, outcome CrawlOutcome) error
+	// LastCrawl returns the outcome of the latest crawl of the feed
+	LastCrawl(feedID string) (CrawlOutcome, bool)
+	// GetFailureState returns the feed's consecutive failure tracking, zero if it is healthy
+	GetFailureState(feedID string) FailureState
+	// SetFailureState stores the feed's consecutive failure tracking
+	SetFailureState(feedID string, failures FailureState) error
+	// Flush writes pending changes to disk, dropping seen entries past the retention window
+	Flush() error
+}
+
+// Validators holds the HTTP cache validators of a feed response
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// DiscoveredFeed is the feed linked from the web page configured as a feed's URL
+type DiscoveredFeed struct {
+	PageURL string `json:"page_url"`
+	FeedURL string `json:"feed_url"`
+}
+
+// CrawlOutcome summarises a single crawl of a feed
+type CrawlOutcome struct {
+	At           time.Time `json:"at"`
+	Success      bool      `json:"success"`
+	NotModified  bool      `json:"not_modified,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	PostsFound   int       `json:"posts_found"`
+	PostsAdded   int       `json:"posts_added"`
+	PostsSkipped int       `json:"posts_skipped"`
+}
+
+// FailureState tracks consecutive crawl failures of a feed for backoff and circuit breaking
+type FailureState struct {
+	FeedURL             string    `json:"feed_url,omitempty"` // URL that was failing, a changed URL resets the state
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastFailureAt       time.Time `json:"last_failure_at,omitempty"`
+	RetryAfter          time.Time `json:"retry_after,omitempty"`
+	Parked              bool      `json:"parked,omitempty"` // circuit open, only probed occasionally
+}
+
+// FeedState is everything the store remembers about one feed
+type FeedState struct {
+	FeedURL    string               `json:"feed_url,omitempty"` // URL the validators belong to
+	Validators Validators           `json:"validators"`
+	Discovered *DiscoveredFeed      `json:"discovered,omitempty"`
+	Seen       map[string]time.Time `json:"seen,omitempty"`
+	LastCrawl  *CrawlOutcome        `json:"last_crawl,omitempty"`
+	Failures   *FailureState        `json:"failures,omitempty"`
+}
+
+// localStore keeps feed state in memory and optionally mirrors it to a JSON file. Changes are
+// only written by Flush, so a crawl rewrites the file once rather than once per item.
+type localStore struct {
+	path  string
+	feeds map[string]*FeedState
+	dirty bool
+	mutex sync.RWMutex
+}
+
+// NewMemoryStore creates a store that only lives for the lifetime of the process
+func NewMemoryStore() Store {
+	return &localStore{
+		feeds: make(map[string]*FeedState),
+	}
+}
+
+// NewFileStore creates a store backed by a JSON file, loading any existing state
+func NewFileStore(path string) (Store, error) {
+	s := &localStore{
+		path:  path,
+		feeds: make(map[string]*FeedState),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &s.feeds); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+
+	s.prune(time.Now())
+	return s, nil
+}
+
+// HasSeen reports whether a GUID or URL was already processed for the feed
+func (s *localStore) HasSeen(feedID, key string) bool {
+	if key == "" {
+		return false
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	fs, ok := s.feeds[feedID]
+	if !ok {
+		return false
+	}
+	_, seen := fs.Seen[key]
+	return seen
+}
+
+// MarkSeen records GUIDs or URLs as processed for the feed
+func (s *localStore) MarkSeen(feedID string, keys ...string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fs := s.feedState(feedID)
+	if fs.Seen == nil {
+		fs.Seen = make(map[string]time.Time)
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		if key != "" {
+			fs.Seen[key] = now
+		}
+	}
+
+	s.dirty = true
+	return nil
+}
+
+// GetValidators returns the HTTP cache validators stored for the feed URL
+func (s *localStore) GetValidators(feedID, feedURL string) (Validators, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	fs, ok := s.feeds[feedID]
+	if !ok || fs.FeedURL != feedURL {
+		return Validators{}, false
+	}
+	if fs.Validators.ETag == "" && fs.Validators.LastModified == "" {
+		return Validators{}, false
+	}
+	return fs.Validators, true
+}
+
+// SetValidators stores the HTTP cache validators for the feed URL
+func (s *localStore) SetValidators(feedID, feedURL string, v Validators) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fs := s.feedState(feedID)
+	if fs.FeedURL == feedURL && fs.Validators == v {
+		return nil
+	}
+	fs.FeedURL = feedURL
+	fs.Validators = v
+
+	s.dirty = true
+	return nil
+}
+
+// GetDiscoveredFeed returns the feed URL discovered on the web page configured as the feed's URL
+func (s *localStore) GetDiscoveredFeed(feedID, pageURL string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	fs, ok := s.feeds[feedID]
+	if !ok || fs.Discovered == nil || fs.Discovered.PageURL != pageURL {
+		return "", false
+	}
+	return fs.Discovered.FeedURL, true
+}
+
+// SetDiscoveredFeed stores the feed URL discovered on the web page, an empty URL removes it
+func (s *localStore) SetDiscoveredFeed(feedID, pageURL, discoveredURL string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fs := s.feedState(feedID)
+	switch {
+	case discoveredURL == "" && fs.Discovered == nil:
+		return nil
+	case discoveredURL == "":
+		fs.Discovered = nil
+	case fs.Discovered != nil && *fs.Discovered == DiscoveredFeed{PageURL: pageURL, FeedURL: discoveredURL}:
+		return nil
+	default:
+		fs.Discovered = &DiscoveredFeed{PageURL: pageURL, FeedURL: discoveredURL}
+	}
+
+	s.dirty = true
+	return nil
+}
+
+// RecordCrawl stores the outcome of the latest crawl of the feed
+func (s *localStore) RecordCrawl(feedID string, outcome CrawlOutcome) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fs := s.feedState(feedID)
+	fs.LastCrawl = &outcome
+
+	s.dirty = true
+	return nil
+}
+
+// LastCrawl returns the outcome of the latest crawl of the feed
+func (s *localStore) LastCrawl(feedID string) (CrawlOutcome, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	fs, ok := s.feeds[feedID]
+	if !ok || fs.LastCrawl == nil {
+		return CrawlOutcome{}, false
+	}
+	return *fs.LastCrawl, true
+}
+
+// GetFailureState returns the feed's consecutive failure tracking, zero if it is healthy
+func (s *localStore) GetFailureState(feedID string) FailureState {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	fs, ok := s.feeds[feedID]
+	if !ok || fs.Failures == nil {
+		return FailureState{}
+	}
+	return *fs.Failures
+}
+
+// SetFailureState stores the feed's consecutive failure tracking
+func (s *localStore) SetFailureState(feedID string, failures FailureState) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fs := s.feedState(feedID)
+	if failures.ConsecutiveFailures == 0 {
+		if fs.Failures == nil {
+			return nil
+		}
+		fs.Failures = nil
+	} else {
+		fs.Failures = &failures
+	}
+
+	s.dirty = true
+	return nil
+}
+
+// Flush writes pending changes to disk, dropping seen entries past the retention window
+func (s *localStore) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+
+	s.prune(time.Now())
+	if err := s.save(); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// feedState returns the state for a feed, creating it if needed. Caller must hold the write lock.
+func (s *localStore) feedState(feedID string) *FeedState {
+	fs, ok := s.feeds[feedID]
+	if !ok {
+		fs = &FeedState{}
+		s.feeds[feedID] = fs
+	}
+	return fs
+}
+
+// prune drops seen entries that have not been observed within the retention window
+func (s *localStore) prune(now time.Time) {
+	for _, fs := range s.feeds {
+		for key, lastSeen := range fs.Seen {
+			if now.Sub(lastSeen) > seenRetention {
+				delete(fs.Seen, key)
+			}
+		}
+	}
+}
+
+// save writes the state file atomically. Caller must hold the write lock.
+func (s *localStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.feeds)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// Write to a temporary file and rename so a crash never leaves a truncated state file
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+
+	return nil
+}
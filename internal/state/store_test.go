@@ -0,0 +1,91 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStorePersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenant.json")
+
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	if err := store.MarkSeen("feed-1", "guid-1", "https://example.com/a"); err != nil {
+		t.Fatalf("MarkSeen failed: %v", err)
+	}
+	if err := store.SetValidators("feed-1", "https://example.com/rss", Validators{ETag: `"v1"`}); err != nil {
+		t.Fatalf("SetValidators failed: %v", err)
+	}
+	if err := store.RecordCrawl("feed-1", CrawlOutcome{At: time.Now(), Success: true, PostsAdded: 2}); err != nil {
+		t.Fatalf("RecordCrawl failed: %v", err)
+	}
+
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+
+	if !reopened.HasSeen("feed-1", "guid-1") || !reopened.HasSeen("feed-1", "https://example.com/a") {
+		t.Errorf("Expected seen keys to survive reopen")
+	}
+	if reopened.HasSeen("feed-2", "guid-1") {
+		t.Errorf("Seen keys must be scoped per feed")
+	}
+
+	if v, ok := reopened.GetValidators("feed-1", "https://example.com/rss"); !ok || v.ETag != `"v1"` {
+		t.Errorf("Expected stored ETag, got %+v (ok=%v)", v, ok)
+	}
+	if _, ok := reopened.GetValidators("feed-1", "https://example.com/other"); ok {
+		t.Errorf("Validators must not be returned after the feed URL changes")
+	}
+
+	outcome, ok := reopened.LastCrawl("feed-1")
+	if !ok || !outcome.Success || outcome.PostsAdded != 2 {
+		t.Errorf("Expected last crawl outcome to survive reopen, got %+v (ok=%v)", outcome, ok)
+	}
+}
+
+func TestFileStoreWritesOnFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenant.json")
+
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		store.MarkSeen("feed-1", fmt.Sprintf("guid-%d", i))
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected no state file before Flush, got %v", err)
+	}
+
+	// Entries past the retention window are dropped when the state is saved
+	local := store.(*localStore)
+	local.feeds["feed-1"].Seen["expired"] = time.Now().Add(-seenRetention - time.Hour)
+
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if store.HasSeen("feed-1", "expired") {
+		t.Error("Expected an expired entry to be pruned on Flush")
+	}
+
+	reopened, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	if !reopened.HasSeen("feed-1", "guid-99") {
+		t.Error("Expected flushed keys to survive reopen")
+	}
+}